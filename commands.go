@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/term"
+
+	"github.com/owhelm/helm-kustomize/internal/render"
+)
+
+// command is a helm-kustomize subcommand
+type command struct {
+	name  string
+	usage string
+	short string
+	run   func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+// defaultCommand runs when no subcommand is named, so the binary keeps working as a
+// plain post-renderer that only receives flags from --post-renderer-args
+const defaultCommand = "post-render"
+
+// commands returns the subcommands in the order they are listed in the help output
+func commands() []command {
+	return []command{
+		{name: "post-render", usage: "[flags]", short: "Apply the embedded kustomization to manifests on stdin (default)", run: runPostRender},
+		{name: "render", usage: "[flags] [chart | manifest]", short: "Render a chart, or a rendered manifest file, through the embedded kustomization", run: runRender},
+		{name: "validate", usage: "[manifest]", short: "Check that the KustomizePluginData resource is well-formed", run: runValidate},
+		{name: "schema", usage: "", short: "Print the JSON Schema of the KustomizePluginData resource", run: runSchema},
+		{name: "lint", usage: "[manifest]", short: "Check the embedded kustomization for common mistakes", run: runLint},
+		{name: "explain", usage: "[flags] kind/name [chart | manifest]", short: "Show which transformations changed a resource and how", run: runExplain},
+		{name: "diff-to-patch", usage: "[flags] desired [chart | manifest]", short: "Add patches turning the rendered output into a hand-edited manifest", run: runDiffToPatch},
+		{name: "inspect", usage: "[flags] [chart | manifest]", short: "List, print or extract the files embedded in a chart or manifest", run: runInspect},
+		{name: "eject", usage: "-o dir [flags] [chart | manifest]", short: "Write a standalone kustomization that builds without the plugin", run: runEject},
+		{name: "test", usage: "[-expected dir] [flags] [chart | manifest]", short: "Run a chart's test specs, or compare the output with expected manifests", run: runTest},
+		{name: "verify", usage: "--values-matrix dir [flags] chart", short: "Render a chart with every values file of a directory and report the failures", run: runVerify},
+		{name: "doctor", usage: "[flags]", short: "Check the runtime environment", run: runDoctor},
+		{name: "init", usage: "[--force] [chart]", short: "Add a kustomization folder and the template embedding it to a chart", run: runInit},
+		{name: "import", usage: "[--force] dir [chart]", short: "Copy a standalone kustomization into a chart", run: runImport},
+		{name: "fix", usage: "[-w] [dir]", short: "Migrate deprecated fields in kustomization files", run: runFix},
+		{name: "version", usage: "", short: "Print the plugin version", run: runVersion},
+	}
+}
+
+// run dispatches args to a subcommand and returns the process exit code.
+// Arguments that don't start with a command name are passed to the default command.
+// Without arguments and manifests piped to stdin, as when run as 'helm kustomize', it
+// prints the usage instead of waiting for input.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 && isTerminal(stdin) {
+		printUsage(stderr)
+		return 2
+	}
+
+	name := defaultCommand
+	if len(args) > 0 {
+		switch {
+		case args[0] == "help" || args[0] == "-h" || args[0] == "--help":
+			printUsage(stdout)
+			return 0
+		case !strings.HasPrefix(args[0], "-"):
+			name, args = args[0], args[1:]
+		}
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd.run(args, stdin, stdout, stderr)
+		}
+	}
+
+	fmt.Fprintf(stderr, "Error: unknown command %q\n\n", name)
+	printUsage(stderr)
+	return 2
+}
+
+// isTerminal reports whether r is an interactive terminal rather than piped input
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// printUsage prints the list of subcommands
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: helm-kustomize [command] [flags]\n\n")
+	fmt.Fprintf(w, "Applies kustomizations embedded in Helm charts. Without a command, it runs as a\n")
+	fmt.Fprintf(w, "Helm post-renderer (%s).\n\n", defaultCommand)
+	fmt.Fprintf(w, "Commands:\n")
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands() {
+		fmt.Fprintf(table, "  %s\t%s\n", cmd.name, cmd.short)
+	}
+	_ = table.Flush()
+	fmt.Fprintf(w, "\nRun 'helm-kustomize <command> -h' for the flags of a command.\n")
+}
+
+// newFlagSet creates the flag set of a subcommand, printing its usage to stderr
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		for _, cmd := range commands() {
+			if cmd.name == name {
+				fmt.Fprintf(stderr, "Usage: helm-kustomize %s %s\n\n%s.\n", name, cmd.usage, cmd.short)
+			}
+		}
+		if hasFlags(flags) {
+			fmt.Fprintf(stderr, "\nFlags:\n")
+			flags.PrintDefaults()
+		}
+	}
+	return flags
+}
+
+// hasFlags reports whether any flag is defined on flags
+func hasFlags(flags *flag.FlagSet) bool {
+	found := false
+	flags.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+// parseFlags parses args and checks that at most maxArgs positional arguments remain.
+// Flags can come after positional arguments, as in Helm, unless "--" ends them.
+// It returns false after printing the usage when the arguments are invalid.
+func parseFlags(flags *flag.FlagSet, args []string, maxArgs int) bool {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return false
+		}
+		rest := flags.Args()
+		if len(rest) == 0 || (len(rest) < len(args) && args[len(args)-len(rest)-1] == "--") {
+			positional = append(positional, rest...)
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	// Leaves only the positional arguments in flags.Args
+	if err := flags.Parse(append([]string{"--"}, positional...)); err != nil {
+		return false
+	}
+	if flags.NArg() > maxArgs {
+		fmt.Fprintf(flags.Output(), "Error: unexpected argument %q\n\n", flags.Arg(maxArgs))
+		flags.Usage()
+		return false
+	}
+	return true
+}
+
+// readManifests reads rendered manifests from the file named by the first positional
+// argument of flags, or from stdin when there is none or it is "-"
+func readManifests(flags *flag.FlagSet, stdin io.Reader) (*bytes.Buffer, error) {
+	return readManifestsFrom(flags.Arg(0), stdin)
+}
+
+// readManifestsFrom reads rendered manifests from the file name, or from stdin when it's
+// empty or "-"
+func readManifestsFrom(name string, stdin io.Reader) (*bytes.Buffer, error) {
+	input := &bytes.Buffer{}
+
+	if name == "" || name == "-" {
+		if _, err := io.Copy(input, stdin); err != nil {
+			return nil, fmt.Errorf("failed to read input: %w", err)
+		}
+		return input, nil
+	}
+
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	input.Write(content)
+	return input, nil
+}
+
+// readChartOrManifests renders the chart at name without post-renderer, or reads rendered
+// manifests like readManifestsFrom otherwise
+func readChartOrManifests(name string, stdin io.Reader, opts render.Options) ([]byte, error) {
+	if render.IsChart(name) {
+		return render.Chart(context.Background(), name, opts)
+	}
+
+	input, err := readManifestsFrom(name, stdin)
+	if err != nil {
+		return nil, err
+	}
+	return input.Bytes(), nil
+}
@@ -0,0 +1,1135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/owhelm/helm-kustomize/internal/config"
+	"github.com/owhelm/helm-kustomize/internal/kustomize"
+	"github.com/owhelm/helm-kustomize/internal/lint"
+	"github.com/owhelm/helm-kustomize/internal/parser"
+	"github.com/owhelm/helm-kustomize/internal/render"
+)
+
+// testManifest is a rendered chart with an embedded kustomization
+const testManifest = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test-configmap
+data:
+  key: value
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+    namespace: test-namespace
+`
+
+func runCommand(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Dispatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{name: "help", args: []string{"help"}, wantCode: 0, wantStdout: "Commands:"},
+		{name: "help flag", args: []string{"--help"}, wantCode: 0, wantStdout: "post-render"},
+		{name: "version", args: []string{"version"}, wantCode: 0, wantStdout: "helm-kustomize " + version},
+		{name: "unknown command", args: []string{"bogus"}, wantCode: 2, wantStderr: `unknown command "bogus"`},
+		{name: "unexpected argument", args: []string{"version", "extra"}, wantCode: 2, wantStderr: `unexpected argument "extra"`},
+		{name: "unknown flag", args: []string{"validate", "--bogus"}, wantCode: 2, wantStderr: "flag provided but not defined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := runCommand(t, "", tt.args...)
+			if code != tt.wantCode {
+				t.Errorf("run() = %d, want %d; stderr: %s", code, tt.wantCode, stderr)
+			}
+			if !strings.Contains(stdout, tt.wantStdout) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout, tt.wantStdout)
+			}
+			if !strings.Contains(stderr, tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestPrintUsage_Aligned(t *testing.T) {
+	var buf bytes.Buffer
+	printUsage(&buf)
+
+	// The descriptions start in the same column, after the longest command name
+	column := -1
+	for _, cmd := range commands() {
+		i := strings.Index(buf.String(), "  "+cmd.name+" ")
+		if i < 0 {
+			t.Fatalf("Expected %s in the usage, got:\n%s", cmd.name, buf.String())
+		}
+		line := buf.String()[i:]
+		line = line[:strings.Index(line, "\n")]
+		start := strings.Index(line, cmd.short)
+		if column < 0 {
+			column = start
+		}
+		if start != column || start <= len(cmd.name)+2 {
+			t.Errorf("Expected the description of %s at column %d, got:\n%s", cmd.name, column, line)
+		}
+	}
+}
+
+func TestRun_DefaultsToPostRender(t *testing.T) {
+	// Helm invokes the post-renderer with only the --post-renderer-args flags
+	code, stdout, stderr := runCommand(t, testManifest, "--enable-component=missing")
+	if code != 1 {
+		t.Fatalf("run() = %d, want 1", code)
+	}
+	if stdout != "" || !strings.Contains(stderr, `unknown component "missing"`) {
+		t.Errorf("Expected unknown component error, got stdout %q, stderr %q", stdout, stderr)
+	}
+
+	code, stdout, stderr = runCommand(t, testManifest)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "namespace: test-namespace") {
+		t.Errorf("Expected kustomized output, got:\n%s", stdout)
+	}
+}
+
+func TestRun_RequiresPluginVersion(t *testing.T) {
+	// The CLI plugin gets its version from plugin.yaml when built
+	cli, err := os.ReadFile(filepath.Join("cli", "plugin.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(cli), "\nversion:") {
+		t.Errorf("cli/plugin.yaml declares a version, which must only be declared in plugin.yaml")
+	}
+
+	manifest := testManifest + "requires:\n  pluginVersion: \">=99.0.0\"\n"
+	code, _, stderr := runCommand(t, manifest)
+	want := "the chart requires helm-kustomize >=99.0.0, but version " + version + " is installed"
+	if code != 1 || !strings.Contains(stderr, want) {
+		t.Errorf("run() = %d, stderr %q; want %q", code, stderr, want)
+	}
+
+	manifest = testManifest + "requires:\n  pluginVersion: \">=" + version + "\"\n"
+	if code, _, stderr := runCommand(t, manifest); code != 0 {
+		t.Errorf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+}
+
+func TestRun_Render(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	if err := os.WriteFile(path, []byte(testManifest), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	code, stdout, stderr := runCommand(t, "", "render", path)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "namespace: test-namespace") {
+		t.Errorf("Expected kustomized output, got:\n%s", stdout)
+	}
+
+	code, _, stderr = runCommand(t, "", "render", filepath.Join(t.TempDir(), "missing.yaml"))
+	if code != 1 || !strings.Contains(stderr, "failed to read input") {
+		t.Errorf("run() = %d, stderr %q; want read error", code, stderr)
+	}
+}
+
+func TestRun_RenderChart(t *testing.T) {
+	chart := filepath.Join("examples", "simple-app")
+
+	code, stdout, stderr := runCommand(t, "", "render", "--set", "image.tag=1.27", "--name", "demo", chart)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	for _, want := range []string{"environment: production", "image: nginx:1.27", "# Source: simple-app/templates/deployment.yaml"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected rendered and kustomized output to contain %q, got:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, "KustomizePluginData") {
+		t.Errorf("Expected KustomizePluginData to be removed, got:\n%s", stdout)
+	}
+
+	code, stdout, stderr = runCommand(t, "", "render", "--output=json", chart)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, `"kind": "List"`) {
+		t.Errorf("Expected a JSON List, got:\n%s", stdout)
+	}
+
+	code, _, stderr = runCommand(t, "", "render", "-f", filepath.Join(t.TempDir(), "missing.yaml"), chart)
+	if code != 1 || !strings.Contains(stderr, "failed to read values") {
+		t.Errorf("run() = %d, stderr %q; want values error", code, stderr)
+	}
+
+	// Flags can follow the chart, as in helm template
+	code, stdout, stderr = runCommand(t, "", "render", chart, "-f", filepath.Join(chart, "values.yaml"), "--set", "image.tag=1.28")
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "image: nginx:1.28") {
+		t.Errorf("Expected the flags after the chart to apply, got:\n%s", stdout)
+	}
+}
+
+func TestRun_RenderChartOutputOption(t *testing.T) {
+	chart := t.TempDir()
+	files := map[string]string{
+		"Chart.yaml":              "apiVersion: v2\nname: demo\nversion: 0.1.0\n",
+		"templates/settings.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n",
+		"templates/kustomize.yaml": `apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+options:
+  output: json
+files:
+  kustomization.yaml: "resources: [all.yaml]"
+`,
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(chart, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(chart, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The chart's output option applies like for post-render
+	code, stdout, stderr := runCommand(t, "", "render", "--backend=builtin", chart)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, `"kind": "List"`) {
+		t.Errorf("Expected a JSON List, got:\n%s", stdout)
+	}
+
+	// and the user's takes precedence
+	code, stdout, stderr = runCommand(t, "", "render", "--backend=builtin", "--output=yaml", chart)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "kind: ConfigMap") {
+		t.Errorf("Expected YAML, got:\n%s", stdout)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantArg []string
+		wantF   string
+		wantOK  bool
+	}{
+		{name: "flags first", args: []string{"-f", "v.yaml", "chart"}, wantArg: []string{"chart"}, wantF: "v.yaml", wantOK: true},
+		{name: "flags after", args: []string{"chart", "-f", "v.yaml"}, wantArg: []string{"chart"}, wantF: "v.yaml", wantOK: true},
+		{name: "between", args: []string{"a", "-f", "v.yaml", "b"}, wantArg: []string{"a", "b"}, wantF: "v.yaml", wantOK: true},
+		{name: "stdin", args: []string{"-", "-f", "v.yaml"}, wantArg: []string{"-"}, wantF: "v.yaml", wantOK: true},
+		{name: "after --", args: []string{"chart", "--", "-f"}, wantArg: []string{"chart", "-f"}, wantOK: true},
+		{name: "too many", args: []string{"a", "-f", "v.yaml", "b", "c"}, wantOK: false},
+		{name: "unknown flag", args: []string{"chart", "--unknown"}, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := newFlagSet("test", io.Discard)
+			f := flags.String("f", "", "")
+			if ok := parseFlags(flags, tt.args, 2); ok != tt.wantOK {
+				t.Fatalf("parseFlags() = %t, want %t", ok, tt.wantOK)
+			}
+			if !tt.wantOK {
+				return
+			}
+			if !slices.Equal(flags.Args(), tt.wantArg) || *f != tt.wantF {
+				t.Errorf("parseFlags() args %q, -f %q; want %q, %q", flags.Args(), *f, tt.wantArg, tt.wantF)
+			}
+		})
+	}
+}
+
+func TestRun_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantCode   int
+		wantOutput string
+	}{
+		{
+			name:       "valid",
+			input:      testManifest,
+			wantCode:   0,
+			wantOutput: "1 embedded files, 1 resources",
+		},
+		{
+			name:       "no plugin data",
+			input:      "apiVersion: v1\nkind: ConfigMap\n",
+			wantCode:   1,
+			wantOutput: "no KustomizePluginData resource found",
+		},
+		{
+			name: "conventional layout",
+			input: `apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  patches/replicas.yaml: "[{op: remove, path: /spec/replicas}]"
+`,
+			wantCode:   1,
+			wantOutput: "patches/replicas.yaml: JSON 6902 patches need a target: name the file Kind.yaml or Kind_name.yaml",
+		},
+		{
+			name: "reserved file",
+			input: `apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  all.yaml: ""
+`,
+			wantCode:   1,
+			wantOutput: "reserved",
+		},
+		{
+			name: "invalid nested kustomization",
+			input: `apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: ""
+  overlay/kustomization.yaml: "resources: all.yaml"
+`,
+			wantCode:   1,
+			wantOutput: "invalid overlay/kustomization.yaml",
+		},
+		{
+			name:       "invalid YAML",
+			input:      "invalid: yaml: structure:",
+			wantCode:   1,
+			wantOutput: "failed to decode YAML document",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := runCommand(t, tt.input, "validate")
+			if code != tt.wantCode {
+				t.Errorf("run() = %d, want %d", code, tt.wantCode)
+			}
+			if !strings.Contains(stdout+stderr, tt.wantOutput) {
+				t.Errorf("Expected output containing %q, got stdout %q, stderr %q", tt.wantOutput, stdout, stderr)
+			}
+		})
+	}
+}
+
+func TestRun_Schema(t *testing.T) {
+	code, stdout, stderr := runCommand(t, "", "schema")
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(stdout), &schema); err != nil {
+		t.Fatalf("Expected a JSON Schema: %v", err)
+	}
+	if schema["$id"] != parser.SchemaID || schema["title"] != parser.Kind {
+		t.Errorf("Expected the KustomizePluginData schema, got $id %v, title %v", schema["$id"], schema["title"])
+	}
+}
+
+func TestRun_Lint(t *testing.T) {
+	code, stdout, _ := runCommand(t, testManifest, "lint")
+	if code != 0 || !strings.Contains(stdout, "No issues found") {
+		t.Errorf("run() = %d, stdout %q; want no issues", code, stdout)
+	}
+
+	input := `apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    patches:
+      - path: patches/missing.yaml
+`
+	code, stdout, _ = runCommand(t, input, "lint")
+	if code != 1 {
+		t.Errorf("run() = %d, want 1", code)
+	}
+	if !strings.Contains(stdout, `error: kustomization.yaml: references "patches/missing.yaml", which is not in files`) {
+		t.Errorf("Expected missing reference error, got:\n%s", stdout)
+	}
+
+	code, _, stderr := runCommand(t, "apiVersion: v1\nkind: ConfigMap\n", "lint")
+	if code != 1 || !strings.Contains(stderr, "no KustomizePluginData resource found") {
+		t.Errorf("run() = %d, stderr %q; want missing resource error", code, stderr)
+	}
+
+	code, stdout, _ = runCommand(t, testManifest, "lint", "--namespace", "prod", "--format", "json")
+	if code != 0 {
+		t.Errorf("run() = %d, want 0 for warnings", code)
+	}
+	var issues []lint.Issue
+	if err := json.Unmarshal([]byte(stdout), &issues); err != nil {
+		t.Fatalf("Expected JSON output: %v\n%s", err, stdout)
+	}
+	if len(issues) != 1 || issues[0].Rule != lint.RuleNamespaceConflict {
+		t.Errorf("Expected a namespace conflict, got %+v", issues)
+	}
+
+	code, _, stderr = runCommand(t, testManifest, "lint", "--format", "xml")
+	if code != 2 || !strings.Contains(stderr, `invalid format "xml"`) {
+		t.Errorf("run() = %d, stderr %q; want format error", code, stderr)
+	}
+}
+
+func TestRun_LintExclude(t *testing.T) {
+	input := `---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-test
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+exclude:
+  - kind: Pod
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+    patches:
+      - target: {kind: Pod, name: web-test}
+        patch: '[{"op": "add", "path": "/metadata/labels", "value": {}}]'
+`
+	code, stdout, _ := runCommand(t, input, "lint")
+	if code != 0 || !strings.Contains(stdout, "selects kind=Pod name=web-test, which matches no resource") {
+		t.Errorf("run() = %d, stdout %q; want the patch of the excluded Pod reported as unmatched", code, stdout)
+	}
+}
+
+func TestRun_LintChart(t *testing.T) {
+	chart := filepath.Join("examples", "simple-app")
+
+	code, stdout, stderr := runCommand(t, "", "lint", "--format", "sarif", chart)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s\n%s", code, stderr, stdout)
+	}
+	if !strings.Contains(stdout, `"version": "2.1.0"`) || !strings.Contains(stdout, `"results": []`) {
+		t.Errorf("Expected an empty SARIF log, got:\n%s", stdout)
+	}
+}
+
+// explainManifest is a rendered chart whose kustomization transforms the resources in several steps
+const explainManifest = `---
+# Source: demo/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.25
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    resources:
+    - all.yaml
+    - base
+    namePrefix: pre-
+    labels:
+    - pairs:
+        app: demo
+    patches:
+    - path: patch.yaml
+    images:
+    - name: nginx
+      newTag: "1.27"
+    configMapGenerator:
+    - name: settings
+      literals: [a=b]
+  patch.yaml: |
+    apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+    spec:
+      replicas: 3
+  base/kustomization.yaml: |
+    resources:
+    - cm.yaml
+    commonAnnotations:
+      team: platform
+  base/cm.yaml: |
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: cfg
+`
+
+func TestRun_Explain(t *testing.T) {
+	code, stdout, stderr := runCommand(t, explainManifest, "explain", "--backend", "builtin", "Deployment/pre-web")
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	for _, want := range []string{
+		"Helm template: demo/templates/deployment.yaml",
+		"kustomization.yaml: patches[0] (PatchTransformer)\n  ~ spec.replicas: 1 -> 3\n",
+		"kustomization.yaml: namePrefix (PrefixTransformer)\n  ~ metadata.name: \"web\" -> \"pre-web\"\n",
+		`~ spec.template.spec.containers[name=web].image: "nginx:1.25" -> "nginx:1.27"`,
+		"4 of 5 transformations changed the resource",
+		"Result:\napiVersion: apps/v1\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected output containing %q, got:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, "config.kubernetes.io") || strings.Contains(stdout, "explain-id") {
+		t.Errorf("Expected the build metadata to be stripped, got:\n%s", stdout)
+	}
+
+	code, stdout, stderr = runCommand(t, explainManifest, "explain", "--backend", "builtin", "ConfigMap/pre-cfg")
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	for _, want := range []string{"Origin: base/cm.yaml", "base/kustomization.yaml: commonAnnotations (AnnotationsTransformer)"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected output containing %q, got:\n%s", want, stdout)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStderr string
+	}{
+		{name: "missing resource", args: []string{"explain"}, wantCode: 2, wantStderr: "the resource to explain is required"},
+		{name: "invalid resource", args: []string{"explain", "Deployment"}, wantCode: 2, wantStderr: "must be kind/name"},
+		{name: "unknown resource", args: []string{"explain", "--backend", "builtin", "Deployment/web"}, wantCode: 1, wantStderr: "Deployment/web not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := runCommand(t, explainManifest, tt.args...)
+			if code != tt.wantCode || !strings.Contains(stderr, tt.wantStderr) {
+				t.Errorf("run() = %d, stderr %q; want %d and %q", code, stderr, tt.wantCode, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRun_Inspect(t *testing.T) {
+	code, stdout, stderr := runCommand(t, testManifest, "inspect", "-")
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte("resources:\n  - all.yaml\nnamespace: test-namespace\n")))
+	for _, want := range []string{"SIZE  SHA256", hash + "  kustomization.yaml", "1 embedded files, 1 resources"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected output containing %q, got:\n%s", want, stdout)
+		}
+	}
+
+	code, stdout, stderr = runCommand(t, testManifest, "inspect", "--file", "kustomization.yaml")
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	if stdout != "resources:\n  - all.yaml\nnamespace: test-namespace\n" {
+		t.Errorf("Expected the file content, got:\n%s", stdout)
+	}
+
+	code, _, stderr = runCommand(t, testManifest, "inspect", "--file", "missing.yaml")
+	if code != 1 || !strings.Contains(stderr, "missing.yaml is not embedded") {
+		t.Errorf("run() = %d, stderr %q; want missing file error", code, stderr)
+	}
+
+	code, _, stderr = runCommand(t, testManifest, "inspect", "--file", "a", "--extract", t.TempDir())
+	if code != 2 || !strings.Contains(stderr, "can't be used together") {
+		t.Errorf("run() = %d, stderr %q; want usage error", code, stderr)
+	}
+}
+
+func TestRun_InspectChart(t *testing.T) {
+	chart := filepath.Join("examples", "simple-app")
+
+	code, stdout, stderr := runCommand(t, "", "inspect", chart)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "kustomization.yaml") {
+		t.Errorf("Expected the embedded files to be listed, got:\n%s", stdout)
+	}
+
+	dir := filepath.Join(t.TempDir(), "tree")
+	code, stdout, stderr = runCommand(t, "", "inspect", "--set", "image.tag=1.27", "--extract", dir, chart)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "kubectl kustomize "+dir) {
+		t.Errorf("Expected a build hint, got:\n%s", stdout)
+	}
+
+	allYAML, err := os.ReadFile(filepath.Join(dir, "all.yaml"))
+	if err != nil {
+		t.Fatalf("Expected all.yaml to be extracted: %v", err)
+	}
+	if !strings.Contains(string(allYAML), "nginx:1.27") || strings.Contains(string(allYAML), "KustomizePluginData") {
+		t.Errorf("Expected all.yaml to hold the rendered manifests, got:\n%s", allYAML)
+	}
+
+	output, _, err := kustomize.BuildWith(dir, kustomize.BuildOptions{Backend: kustomize.Builtin})
+	if err != nil {
+		t.Fatalf("Expected the extracted kustomization to build: %v", err)
+	}
+	if !strings.Contains(string(output), "environment: production") {
+		t.Errorf("Expected the kustomization to be applied, got:\n%s", output)
+	}
+}
+
+func TestRun_Eject(t *testing.T) {
+	chart := filepath.Join("examples", "simple-app")
+	manifests, err := render.Chart(context.Background(), chart, render.Options{})
+	if err != nil {
+		t.Fatalf("Chart() error = %v", err)
+	}
+	backend := kustomize.Builtin
+	renderer := &KustomizePostRenderer{Config: config.Layer{Backend: &backend}}
+	want, err := renderer.Run(bytes.NewBuffer(manifests))
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for _, split := range []bool{false, true} {
+		t.Run(fmt.Sprintf("split=%t", split), func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "ejected")
+			args := []string{"eject", "-o", dir, chart}
+			if split {
+				args = append(args[:1], append([]string{"--split-templates"}, args[1:]...)...)
+			}
+			code, stdout, stderr := runCommand(t, "", args...)
+			if code != 0 {
+				t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+			}
+			if !strings.Contains(stdout, "Ejected the kustomization to "+dir) {
+				t.Errorf("Unexpected output:\n%s", stdout)
+			}
+
+			_, err := os.Stat(filepath.Join(dir, "all.yaml"))
+			if split != os.IsNotExist(err) {
+				t.Errorf("all.yaml exists = %t, want %t", err == nil, !split)
+			}
+			if split {
+				if _, err := os.Stat(filepath.Join(dir, "helm", "simple-app", "templates", "deployment.yaml")); err != nil {
+					t.Errorf("Expected a file per template: %v", err)
+				}
+			}
+
+			// The ejected directory builds to the post-renderer's output
+			output, _, err := kustomize.BuildWith(dir, kustomize.BuildOptions{Backend: kustomize.Builtin})
+			if err != nil {
+				t.Fatalf("Expected the ejected kustomization to build: %v", err)
+			}
+			if string(output) != want.String() {
+				t.Errorf("Build output =\n%s\nwant\n%s", output, want)
+			}
+		})
+	}
+
+	if code, _, stderr := runCommand(t, "", "eject", chart); code != 2 || !strings.Contains(stderr, "the output directory is required") {
+		t.Errorf("run() without -o = %d, stderr: %s", code, stderr)
+	}
+}
+
+func TestRun_EjectExclude(t *testing.T) {
+	manifest := `---
+# Source: app/templates/pod.yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-test
+---
+# Source: app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+exclude:
+  - kind: Pod
+files:
+  kustomization.yaml: "resources: []"
+`
+	backend := kustomize.Builtin
+	renderer := &KustomizePostRenderer{Config: config.Layer{Backend: &backend}}
+	want, err := renderer.Run(bytes.NewBufferString(manifest))
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "ejected")
+	if code, _, stderr := runCommand(t, manifest, "eject", "--split-templates", "-o", dir); code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "helm", "app", "templates", "pod.yaml")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file for the excluded Pod, got err %v", err)
+	}
+	output, _, err := kustomize.BuildWith(dir, kustomize.BuildOptions{Backend: kustomize.Builtin})
+	if err != nil {
+		t.Fatalf("Expected the ejected kustomization to build: %v", err)
+	}
+	if string(output) != want.String() {
+		t.Errorf("Build output =\n%s\nwant\n%s", output, want)
+	}
+}
+
+func TestRun_DiffToPatch(t *testing.T) {
+	chart := t.TempDir()
+	if err := os.CopyFS(chart, os.DirFS(filepath.Join("examples", "simple-app"))); err != nil {
+		t.Fatal(err)
+	}
+
+	code, current, stderr := runCommand(t, "", "render", "--backend=builtin", chart)
+	if code != 0 {
+		t.Fatalf("render = %d, want 0; stderr: %s", code, stderr)
+	}
+	desired := filepath.Join(t.TempDir(), "desired.yaml")
+	edited := strings.NewReplacer("replicas: 3", "replicas: 5", "type: ClusterIP", "type: NodePort").Replace(current)
+	if err := os.WriteFile(desired, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runCommand(t, "", "diff-to-patch", "--backend=builtin", desired, chart)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "Added 2 patches to ") || stderr != "" {
+		t.Errorf("Unexpected output:\nstdout: %s\nstderr: %s", stdout, stderr)
+	}
+	kustomization, err := os.ReadFile(filepath.Join(chart, "kustomization", "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(kustomization), "path: patches/deployment-simple-app.yaml") {
+		t.Errorf("Expected the patch to be registered, got:\n%s", kustomization)
+	}
+
+	// The chart now renders the desired manifest
+	code, rendered, stderr := runCommand(t, "", "render", "--backend=builtin", chart)
+	if code != 0 || rendered != edited {
+		t.Errorf("render = %d, stderr %s, output:\n%s\nwant:\n%s", code, stderr, rendered, edited)
+	}
+
+	code, stdout, _ = runCommand(t, "", "diff-to-patch", "--backend=builtin", desired, chart)
+	if code != 0 || !strings.Contains(stdout, "No changes") {
+		t.Errorf("run() = %d, stdout %q; want no changes", code, stdout)
+	}
+}
+
+func TestRun_DiffToPatch_JSON6902(t *testing.T) {
+	manifest := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  key: value
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+    namePrefix: prod-
+`
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("namePrefix: prod-\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	desired := filepath.Join(t.TempDir(), "desired.yaml")
+	if err := os.WriteFile(desired, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: prod-settings\ndata:\n  key: other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCommand(t, manifest, "diff-to-patch", "--backend=builtin", "--type=json6902", "--dir", dir, desired)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+
+	patch, err := os.ReadFile(filepath.Join(dir, "patches", "configmap-settings.yaml"))
+	if err != nil {
+		t.Fatalf("Expected the patch to be written: %v", err)
+	}
+	if want := "- op: replace\n  path: /data/key\n  value: other\n"; string(patch) != want {
+		t.Errorf("patch =\n%s\nwant\n%s", patch, want)
+	}
+	kustomization, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The patch applies before namePrefix, so it targets the name in the Helm output
+	if !strings.Contains(string(kustomization), "name: settings") {
+		t.Errorf("Expected the patch to target settings, got:\n%s", kustomization)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		code    int
+		wantErr string
+	}{
+		{name: "missing desired manifest", args: []string{"--dir", dir}, code: 2, wantErr: "the desired manifest is required"},
+		{name: "invalid type", args: []string{"--type=merge", "--dir", dir, desired}, code: 2, wantErr: `invalid type "merge"`},
+		{name: "missing dir", args: []string{desired}, code: 2, wantErr: "--dir is required"},
+		{name: "both from stdin", args: []string{"--dir", dir, "-"}, code: 2, wantErr: "can't both be read from stdin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := runCommand(t, manifest, append([]string{"diff-to-patch"}, tt.args...)...)
+			if code != tt.code || !strings.Contains(stderr, tt.wantErr) {
+				t.Errorf("run() = %d, stderr %q; want %d and %q", code, stderr, tt.code, tt.wantErr)
+			}
+		})
+	}
+
+	unknown := filepath.Join(t.TempDir(), "unknown.yaml")
+	if err := os.WriteFile(unknown, []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: token\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr = runCommand(t, manifest, "diff-to-patch", "--backend=builtin", "--dir", dir, unknown)
+	if code != 1 || !strings.Contains(stderr, "Secret/token is not in the rendered output") {
+		t.Errorf("run() = %d, stderr %q; want an unknown resource error", code, stderr)
+	}
+}
+
+func TestRun_DiffToPatch_ChartOverlay(t *testing.T) {
+	manifest := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  key: value
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+options:
+  overlay: overlays/prod
+files:
+  kustomization.yaml: "resources: []"
+  overlays/prod/kustomization.yaml: "resources: []"
+`
+	dir := t.TempDir()
+	for _, name := range []string{"kustomization.yaml", filepath.Join("overlays", "prod", "kustomization.yaml")} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("resources: []\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	desired := filepath.Join(t.TempDir(), "desired.yaml")
+	if err := os.WriteFile(desired, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\ndata:\n  key: other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCommand(t, manifest, "diff-to-patch", "--backend=builtin", "--dir", dir, desired)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+
+	// The patch goes to the overlay the chart builds, not to the root
+	if _, err := os.Stat(filepath.Join(dir, "overlays", "prod", "patches", "configmap-settings.yaml")); err != nil {
+		t.Errorf("Expected the patch in the chart's overlay: %v", err)
+	}
+	root, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(root) != "resources: []\n" {
+		t.Errorf("Expected the root kustomization unchanged, got:\n%s", root)
+	}
+}
+
+func TestRun_DiffToPatch_Convention(t *testing.T) {
+	manifest := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  key: value
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  resources/extra.yaml: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: extra\n"
+`
+	desired := filepath.Join(t.TempDir(), "desired.yaml")
+	if err := os.WriteFile(desired, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\ndata:\n  key: other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		patchType string
+		file      string
+	}{
+		{patchType: patchStrategic, file: "configmap-settings.yaml"},
+		{patchType: patchJSON6902, file: "ConfigMap_settings.yaml"},
+	} {
+		t.Run(tt.patchType, func(t *testing.T) {
+			dir := t.TempDir()
+			code, _, stderr := runCommand(t, manifest, "diff-to-patch", "--backend=builtin", "--type", tt.patchType, "--dir", dir, desired)
+			if code != 0 {
+				t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+			}
+
+			// The generated kustomization picks up the patches by their directory and name
+			if _, err := os.Stat(filepath.Join(dir, "patches", tt.file)); err != nil {
+				t.Errorf("Expected the patch in patches/: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "kustomization.yaml")); !os.IsNotExist(err) {
+				t.Errorf("Expected no kustomization.yaml to be written, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRun_Test(t *testing.T) {
+	expectedDir := t.TempDir()
+	expected := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test-configmap
+  namespace: test-namespace
+data:
+  key: value
+`
+	if err := os.WriteFile(filepath.Join(expectedDir, "configmap.yaml"), []byte(expected), 0644); err != nil {
+		t.Fatalf("Failed to write expected output: %v", err)
+	}
+
+	code, stdout, stderr := runCommand(t, testManifest, "test", "-expected", expectedDir)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stdout: %s, stderr: %s", code, stdout, stderr)
+	}
+	if !strings.Contains(stdout, "PASS 1 resources match") {
+		t.Errorf("Expected PASS, got:\n%s", stdout)
+	}
+
+	changed := strings.Replace(testManifest, "key: value", "key: other", 1)
+	code, stdout, _ = runCommand(t, changed, "test", "-expected", expectedDir)
+	if code != 1 || !strings.Contains(stdout, "FAIL ConfigMap/test-namespace/test-configmap: differs from expected output") {
+		t.Errorf("run() = %d, stdout %q; want difference reported", code, stdout)
+	}
+
+	renamed := strings.Replace(testManifest, "name: test-configmap", "name: renamed", 1)
+	code, stdout, _ = runCommand(t, renamed, "test", "-expected", expectedDir)
+	if code != 1 ||
+		!strings.Contains(stdout, "FAIL ConfigMap/test-namespace/renamed: not in expected output") ||
+		!strings.Contains(stdout, "FAIL ConfigMap/test-namespace/test-configmap: missing from output") {
+		t.Errorf("run() = %d, stdout %q; want missing and unexpected resources reported", code, stdout)
+	}
+
+	code, _, stderr = runCommand(t, testManifest, "test")
+	if code != 2 || !strings.Contains(stderr, "-expected is required") {
+		t.Errorf("run() = %d, stderr %q; want usage error", code, stderr)
+	}
+
+	code, stdout, _ = runCommand(t, changed, "test", "-expected", expectedDir)
+	if code != 1 || !strings.Contains(stdout, "-  key: value\n+  key: other") {
+		t.Errorf("run() = %d, stdout %q; want a diff of the resource", code, stdout)
+	}
+
+	code, stdout, stderr = runCommand(t, changed, "test", "-expected", expectedDir, "--update-snapshots")
+	if code != 0 || !strings.Contains(stdout, "UPDATED 1 resources written to ") {
+		t.Fatalf("run() = %d, stdout %q, stderr %q; want the snapshot updated", code, stdout, stderr)
+	}
+	if code, _, _ := runCommand(t, changed, "test", "-expected", expectedDir); code != 0 {
+		t.Errorf("run() = %d after updating the snapshot, want 0", code)
+	}
+}
+
+func TestRun_TestSpecs(t *testing.T) {
+	chart := t.TempDir()
+	if err := os.CopyFS(chart, os.DirFS(filepath.Join("examples", "simple-app"))); err != nil {
+		t.Fatal(err)
+	}
+
+	// Specs don't depend on the environment of the command
+	t.Setenv(config.EnvName("overlay"), "overlays/missing")
+
+	code, stdout, stderr := runCommand(t, "", "test", "--backend=builtin", chart)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stdout: %s, stderr: %s", code, stdout, stderr)
+	}
+	for _, want := range []string{"PASS default (2 resources match expected-output, 3 assertions)", "PASS nodeport (4 assertions)", "2 tests, 0 failed"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q, got:\n%s", want, stdout)
+		}
+	}
+
+	failing := "set:\n  image.tag: latest\nexpected: expected-latest\nasserts:\n- resource: Deployment/simple-app\n  path: .spec.template.spec.containers[0].image\n  equal: nginx:1.21\n"
+	if err := os.WriteFile(filepath.Join(chart, "kustomize-tests", "latest.yaml"), []byte(failing), 0644); err != nil {
+		t.Fatal(err)
+	}
+	code, stdout, _ = runCommand(t, "", "test", "--backend=builtin", chart)
+	if code != 1 || !strings.Contains(stdout, "FAIL latest\n") || !strings.Contains(stdout, "3 tests, 1 failed") {
+		t.Errorf("run() = %d, want the failing spec reported; stdout:\n%s", code, stdout)
+	}
+
+	// Updating creates the missing snapshot, but assertions still run
+	code, stdout, _ = runCommand(t, "", "test", "--backend=builtin", "--update-snapshots", chart)
+	if code != 1 || !strings.Contains(stdout, `asserts[0] Deployment/simple-app .spec.template.spec.containers[0].image: got "nginx:latest", want "nginx:1.21"`) {
+		t.Errorf("run() = %d, stdout:\n%s", code, stdout)
+	}
+	if _, err := os.Stat(filepath.Join(chart, "expected-latest", "deployment-simple-app.yaml")); err != nil {
+		t.Errorf("Expected the snapshot to be created: %v", err)
+	}
+
+	code, _, stderr = runCommand(t, "", "test", t.TempDir())
+	if code != 2 || !strings.Contains(stderr, "-expected is required unless a chart") {
+		t.Errorf("run() = %d, stderr %q; want usage error for a directory that isn't a chart", code, stderr)
+	}
+}
+
+func TestRun_Verify(t *testing.T) {
+	chart := t.TempDir()
+	if err := os.CopyFS(chart, os.DirFS(filepath.Join("examples", "simple-app"))); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"templates/ingress.yaml":             "{{- if .Values.ingress }}\napiVersion: networking.k8s.io/v1\nkind: Ingress\nmetadata:\n  name: web\n{{- end }}\n",
+		"templates/extra.yaml":               "{{- if .Values.duplicate }}\napiVersion: v1\nkind: Service\nmetadata:\n  name: simple-app\n{{- end }}\n",
+		"kustomization/patches/ingress.yaml": "apiVersion: networking.k8s.io/v1\nkind: Ingress\nmetadata:\n  name: web\n  annotations:\n    team: web\n",
+		"kustomization/kustomization.yaml":   "resources:\n- all.yaml\npatches:\n- path: patches/deployment-replicas.yaml\n- path: patches/add-label.yaml\n- path: patches/ingress.yaml\n",
+		"ci/ingress.yaml":                    "ingress: true\n",
+		"ci/no-ingress.yaml":                 "ingress: false\n",
+		"ci/duplicate.yaml":                  "ingress: true\nduplicate: true\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(chart, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, stdout, stderr := runCommand(t, "", "verify", "--backend=builtin", "--values-matrix", filepath.Join(chart, "ci"), chart)
+	if code != 1 {
+		t.Fatalf("run() = %d, want 1; stdout: %s, stderr: %s", code, stdout, stderr)
+	}
+	for _, want := range []string{
+		"VALUES           BUILD   DUPLICATES  UNMATCHED  RESULT\n",
+		"duplicate.yaml   failed  1           0          FAIL\n",
+		"ingress.yaml     ok      0           0          PASS\n",
+		"no-ingress.yaml  failed  0           1          FAIL\n",
+		"  duplicate resource Service/simple-app\n",
+		"  kustomization.yaml: patches[2] selects group=networking.k8s.io version=v1 kind=Ingress name=web, which matches no resource\n",
+		"3 combinations, 2 failed\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q, got:\n%s", want, stdout)
+		}
+	}
+
+	code, stdout, _ = runCommand(t, "", "verify", "--backend=builtin", "--values-matrix", filepath.Join(chart, "ci"), "-f", filepath.Join(chart, "ci", "ingress.yaml"), "--set", "duplicate=false", chart)
+	if code != 1 || !strings.Contains(stdout, "duplicate.yaml   ok      0           0          PASS\n") {
+		t.Errorf("run() = %d, want values given by flags to apply first; stdout:\n%s", code, stdout)
+	}
+
+	code, _, stderr = runCommand(t, "", "verify", chart)
+	if code != 2 || !strings.Contains(stderr, "--values-matrix is required") {
+		t.Errorf("run() = %d, stderr %q; want usage error", code, stderr)
+	}
+}
+
+func TestRun_Doctor(t *testing.T) {
+	code, stdout, _ := runCommand(t, "", "doctor")
+	for _, want := range []string{"temp directory", "plugin directory", "✓ build with builtin"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q, got:\n%s", want, stdout)
+		}
+	}
+	if code == 0 && strings.Contains(stdout, "✗") {
+		t.Errorf("run() = 0 with failed checks:\n%s", stdout)
+	}
+}
+
+func TestRun_InitAndImport(t *testing.T) {
+	chart := t.TempDir()
+	if err := os.WriteFile(filepath.Join(chart, "Chart.yaml"), []byte("apiVersion: v2\nname: demo\nversion: 0.1.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runCommand(t, "", "init", chart)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	for _, want := range []string{"Created kustomization/kustomization.yaml", "Created templates/kustomize-files.yaml"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stdout = %q, want it to contain %q", stdout, want)
+		}
+	}
+
+	overlay := t.TempDir()
+	if err := os.WriteFile(filepath.Join(overlay, "kustomization.yaml"), []byte("resources:\n  - ../base\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr = runCommand(t, "", "import", overlay, chart)
+	if code != 1 || !strings.Contains(stderr, "use --force to replace it") {
+		t.Errorf("run() = %d, stderr %q; want existing folder error", code, stderr)
+	}
+
+	code, stdout, stderr = runCommand(t, "", "import", "--force", overlay, chart)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0; stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "Kept existing templates/kustomize-files.yaml") {
+		t.Errorf("stdout = %q, want the template to be kept", stdout)
+	}
+	if !strings.Contains(stderr, `Warning: kustomization.yaml references "../base"`) {
+		t.Errorf("stderr = %q, want a warning about ../base", stderr)
+	}
+
+	code, _, stderr = runCommand(t, "", "import")
+	if code != 2 || !strings.Contains(stderr, "the directory to import is required") {
+		t.Errorf("run() = %d, stderr %q; want usage error", code, stderr)
+	}
+}
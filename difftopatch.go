@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+
+	"github.com/owhelm/helm-kustomize/internal/kustomize"
+	"github.com/owhelm/helm-kustomize/internal/parser"
+	"github.com/owhelm/helm-kustomize/internal/patch"
+	"github.com/owhelm/helm-kustomize/internal/render"
+	"github.com/owhelm/helm-kustomize/internal/report"
+)
+
+// Patch types of diff-to-patch
+const (
+	patchStrategic = "strategic"
+	patchJSON6902  = "json6902"
+)
+
+// runDiffToPatch implements the diff-to-patch command. It renders a chart, or a rendered
+// manifest, through the embedded kustomization, compares the output with a hand-edited
+// desired manifest and writes a patch per changed resource to the kustomization folder.
+func runDiffToPatch(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("diff-to-patch", stderr)
+	renderer, err := postRendererFlags(flags, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	opts := chartFlags(flags)
+	patchType := flags.String("type", patchStrategic, "patch type: strategic or json6902")
+	dir := flags.String("dir", "", "kustomization folder to add the patches to (default the chart's kustomization/)")
+	if !parseFlags(flags, args, 2) {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "Error: the desired manifest is required")
+		return 2
+	}
+	if *patchType != patchStrategic && *patchType != patchJSON6902 {
+		fmt.Fprintf(stderr, "Error: invalid type %q, must be %s or %s\n", *patchType, patchStrategic, patchJSON6902)
+		return 2
+	}
+	if *dir == "" {
+		*dir = embeddedDir(flags.Args()[1:])
+	}
+	if *dir == "" {
+		fmt.Fprintln(stderr, "Error: --dir is required unless a chart with a kustomization/ folder is given")
+		return 2
+	}
+	if flags.Arg(0) == "-" && (flags.Arg(1) == "" || flags.Arg(1) == "-") {
+		fmt.Fprintln(stderr, "Error: the desired and rendered manifests can't both be read from stdin")
+		return 2
+	}
+	renderer.Stderr = stderr
+
+	desired, err := readManifestsFrom(flags.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	desiredResult, err := parser.ParseManifests(desired.Bytes())
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to parse %s: %v\n", flags.Arg(0), err)
+		return 1
+	}
+
+	manifests, err := readChartOrManifests(flags.Arg(1), stdin, *opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	result, current, err := renderResources(renderer, manifests)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	// The patches go to the overlay that is built, which the chart can set
+	renderOpts, _, err := renderer.options(result.KustomizePluginData)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	written, err := writePatches(stdout, filepath.Join(*dir, filepath.FromSlash(renderOpts.Overlay)), *patchType, result.KustomizePluginData.Conventional, result.OtherResources, current, desiredResult.OtherResources)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if written == 0 {
+		fmt.Fprintln(stdout, "No changes, the rendered output already matches")
+		return 0
+	}
+
+	// Fields set by transformations running after the patches, such as images, override them
+	if flags.NArg() == 2 && render.IsChart(flags.Arg(1)) {
+		manifests, err := readChartOrManifests(flags.Arg(1), nil, *opts)
+		if err == nil {
+			_, current, err = renderResources(renderer, manifests)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: failed to check the patched chart: %v\n", err)
+			return 1
+		}
+		for _, key := range differing(current, desiredResult.OtherResources) {
+			fmt.Fprintf(stderr, "Warning: %s still differs from the desired manifest, check the transformations running after the patches\n", key)
+		}
+	}
+	return 0
+}
+
+// renderResources returns the parsed Helm output, with the resources rendered by Helm in
+// OtherResources, and the output of the embedded kustomization for them
+func renderResources(renderer *KustomizePostRenderer, manifests []byte) (*parser.ParseResult, []map[string]any, error) {
+	result, err := parseManifests(manifests)
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.KustomizePluginData == nil {
+		return nil, nil, fmt.Errorf("no %s resource found", parser.Kind)
+	}
+
+	rendered, err := renderer.Run(bytes.NewBuffer(manifests))
+	if err != nil {
+		return nil, nil, err
+	}
+	outputResult, err := parser.ParseManifests(rendered.Bytes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse kustomize output: %w", err)
+	}
+	return result, outputResult.OtherResources, nil
+}
+
+// writePatches writes a patch turning each resource of current into its version in
+// desired to the patches directory of the kustomization in dir, and registers it.
+// Patches target the resources by their name in the Helm output, since they apply before
+// kustomize renames them. When the files follow the conventional layout, the generated
+// kustomization picks the patches up, and JSON 6902 patches are named after their target.
+// It returns the number of patches written.
+func writePatches(w io.Writer, dir, patchType string, conventional bool, helm, current, desired []map[string]any) (int, error) {
+	kustomizationPath := filepath.Join(dir, "kustomization.yaml")
+	var k *kustomize.Kustomization
+	if !conventional {
+		content, err := os.ReadFile(kustomizationPath)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read kustomization: %w", err)
+		}
+		if k, err = kustomize.ParseKustomization(content); err != nil {
+			return 0, fmt.Errorf("%s: %w", kustomizationPath, err)
+		}
+	}
+
+	currentByKey := map[parser.ResourceKey]map[string]any{}
+	for _, resource := range current {
+		currentByKey[parser.KeyOf(resource)] = resource
+	}
+	helmByKey := map[parser.ResourceKey]map[string]any{}
+	for _, resource := range helm {
+		helmByKey[parser.KeyOf(resource)] = resource
+	}
+	previous := map[parser.ResourceKey]parser.ResourceKey{}
+	for _, rename := range report.Renames(helm, current) {
+		previous[rename.Resource] = rename.Previous
+	}
+
+	// Compute every patch before writing any, so errors leave the folder unchanged
+	type patchFile struct {
+		name     string
+		selector kustomize.Selector
+		body     any
+		key      parser.ResourceKey
+	}
+	var patches []patchFile
+	taken := map[string]bool{}
+	for _, resource := range desired {
+		key := parser.KeyOf(resource)
+		before, ok := currentByKey[key]
+		if !ok {
+			return 0, fmt.Errorf("%s is not in the rendered output, patches can only change existing resources", key)
+		}
+
+		// Target the resource by its name in the Helm output
+		target := key
+		if name, ok := previous[key]; ok {
+			target = name
+		}
+
+		var body any
+		if patchType == patchJSON6902 {
+			if ops := patch.JSON6902(before, resource); len(ops) > 0 {
+				body = ops
+			}
+		} else {
+			smp, err := patch.Strategic(before, resource)
+			if err != nil {
+				return 0, fmt.Errorf("%s: %w", key, err)
+			}
+			if smp != nil {
+				if original, ok := helmByKey[target]; ok {
+					setName(smp, original)
+				}
+				body = smp
+			}
+		}
+		if body == nil {
+			continue
+		}
+
+		var name string
+		var err error
+		if conventional && patchType == patchJSON6902 {
+			name, err = targetFileName(dir, target, taken)
+		} else {
+			name, err = patchFileName(dir, target, taken)
+		}
+		if err != nil {
+			return 0, err
+		}
+		taken[name] = true
+		selector := kustomize.Selector{Kind: target.Kind, Name: target.Name, Namespace: target.Namespace}
+		patches = append(patches, patchFile{name: name, selector: selector, body: body, key: key})
+	}
+	if len(patches) == 0 {
+		return 0, nil
+	}
+
+	for _, p := range patches {
+		file := filepath.Join(dir, filepath.FromSlash(p.name))
+		if err := writeYAML(file, p.body); err != nil {
+			return 0, err
+		}
+		if k != nil {
+			k.AddPatch(p.name, p.selector)
+		}
+		fmt.Fprintf(w, "Wrote %s for %s\n", file, p.key)
+	}
+	if conventional {
+		fmt.Fprintf(w, "The generated kustomization applies the %d patches in %s\n", len(patches), filepath.Join(dir, parser.PatchesDir))
+		return len(patches), nil
+	}
+
+	updated, err := k.Marshal()
+	if err != nil {
+		return 0, fmt.Errorf("failed to update %s: %w", kustomizationPath, err)
+	}
+	if err := os.WriteFile(kustomizationPath, updated, 0644); err != nil {
+		return 0, fmt.Errorf("failed to update %s: %w", kustomizationPath, err)
+	}
+	fmt.Fprintf(w, "Added %d patches to %s\n", len(patches), kustomizationPath)
+	return len(patches), nil
+}
+
+// setName names the resource of a strategic merge patch as in the Helm output
+func setName(smp, original map[string]any) {
+	metadata, _ := smp["metadata"].(map[string]any)
+	originalMetadata, _ := original["metadata"].(map[string]any)
+	for _, field := range []string{"name", "namespace"} {
+		if value, ok := originalMetadata[field]; ok {
+			metadata[field] = value
+		} else {
+			delete(metadata, field)
+		}
+	}
+}
+
+// patchFileName returns a path for the patch of a resource, relative to dir, that is
+// neither taken nor an existing file, e.g. patches/deployment-web.yaml
+func patchFileName(dir string, key parser.ResourceKey, taken map[string]bool) (string, error) {
+	base := strings.ToLower(key.Kind + "-" + key.Name)
+	for i := 1; ; i++ {
+		name := path.Join("patches", base+".yaml")
+		if i > 1 {
+			name = path.Join("patches", base+"-"+strconv.Itoa(i)+".yaml")
+		}
+		if taken[name] {
+			continue
+		}
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if errors.Is(err, fs.ErrNotExist) {
+			return name, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to check %s: %w", name, err)
+		}
+	}
+}
+
+// targetFileName returns the name of the JSON 6902 patch of key in the conventional layout,
+// Kind_name.yaml, which the generated kustomization targets the resource by
+func targetFileName(dir string, key parser.ResourceKey, taken map[string]bool) (string, error) {
+	name := path.Join(parser.PatchesDir, key.Kind+"_"+key.Name+".yaml")
+	_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+	if taken[name] || err == nil {
+		return "", fmt.Errorf("%s already exists, edit it or pass --type=%s", name, patchStrategic)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("failed to check %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// writeYAML writes value as YAML to the file name, creating its directory
+func writeYAML(name string, value any) error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// differing returns the resources of desired that differ from their version in output
+func differing(output, desired []map[string]any) []parser.ResourceKey {
+	outputByKey := map[parser.ResourceKey]map[string]any{}
+	for _, resource := range output {
+		outputByKey[parser.KeyOf(resource)] = resource
+	}
+
+	var keys []parser.ResourceKey
+	for _, resource := range desired {
+		key := parser.KeyOf(resource)
+		if !reflect.DeepEqual(outputByKey[key], resource) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
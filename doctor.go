@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"go.yaml.in/yaml/v4"
+
+	"github.com/owhelm/helm-kustomize/internal/config"
+	"github.com/owhelm/helm-kustomize/internal/extractor"
+	"github.com/owhelm/helm-kustomize/internal/kustomize"
+	"github.com/owhelm/helm-kustomize/internal/parser"
+)
+
+// minKustomizeVersion is the oldest supported kustomize release, bundled since kubectl 1.27
+var minKustomizeVersion = semver.MustParse("5.0.0")
+
+// doctorCheck is a single check run by the doctor command
+type doctorCheck struct {
+	name string
+	run  func() (string, error)
+}
+
+// doctorIssue is a failed check with a hint on how to fix it
+type doctorIssue struct {
+	message string
+	hint    string
+	// warning is set for problems that don't affect the configured backend
+	warning bool
+}
+
+func (i *doctorIssue) Error() string {
+	return i.message
+}
+
+// runDoctor implements the doctor command. It checks that the environment can run the
+// post-renderer with the configured backend, and builds a small kustomization with every
+// available backend.
+func runDoctor(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("doctor", stderr)
+	renderer, err := postRendererFlags(flags, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if !parseFlags(flags, args, 0) {
+		return 2
+	}
+	backend := config.Resolve(renderer.Config).Backend
+
+	checks := []doctorCheck{
+		{name: "kubectl", run: func() (string, error) { return checkKubectl(backend == kustomize.Kubectl) }},
+		{name: "kustomize", run: func() (string, error) { return checkKustomize(backend == kustomize.Kustomize) }},
+		{name: "builtin", run: checkBuiltin},
+		{name: "temp directory", run: checkTempDir},
+		{name: "plugin directory", run: func() (string, error) { return checkPluginDir(os.Getenv) }},
+	}
+	for _, b := range kustomize.Backends {
+		if b == kustomize.Builtin || hasBinary(b) {
+			checks = append(checks, doctorCheck{
+				name: fmt.Sprintf("build with %s", b),
+				run:  func() (string, error) { return checkBuild(b) },
+			})
+		}
+	}
+
+	failed := false
+	for _, check := range checks {
+		detail, err := check.run()
+		if err == nil {
+			fmt.Fprintf(stdout, "✓ %s: %s\n", check.name, detail)
+			continue
+		}
+
+		var issue *doctorIssue
+		if !errors.As(err, &issue) {
+			issue = &doctorIssue{message: err.Error()}
+		}
+		mark := "✗"
+		if issue.warning {
+			mark = "!"
+		} else {
+			failed = true
+		}
+		fmt.Fprintf(stdout, "%s %s: %s\n", mark, check.name, issue.message)
+		if issue.hint != "" {
+			fmt.Fprintf(stdout, "  fix: %s\n", issue.hint)
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// hasBinary reports whether the executable of an external backend is installed
+func hasBinary(backend kustomize.Backend) bool {
+	_, err := exec.LookPath(string(backend))
+	return err == nil
+}
+
+// checkKubectl checks that kubectl is installed and bundles a supported kustomize. Problems
+// are only errors when kubectl is the configured backend.
+func checkKubectl(required bool) (string, error) {
+	const upgrade = "install kubectl 1.27 or later, or use --backend=builtin"
+	path, err := exec.LookPath("kubectl")
+	if err != nil {
+		return "", &doctorIssue{message: "not found in PATH", hint: upgrade, warning: !required}
+	}
+
+	output, err := exec.Command(path, "version", "--client", "-o", "json").Output()
+	if err != nil {
+		return "", &doctorIssue{message: fmt.Sprintf("%s version failed: %v", path, err), hint: upgrade, warning: !required}
+	}
+	var versions struct {
+		ClientVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"clientVersion"`
+		KustomizeVersion string `json:"kustomizeVersion"`
+	}
+	if err := json.Unmarshal(output, &versions); err != nil {
+		return "", &doctorIssue{message: fmt.Sprintf("unexpected output of %s version: %v", path, err), hint: upgrade, warning: !required}
+	}
+
+	// kubectl only reports the version of its kustomize since 1.24
+	if err := checkKustomizeVersion(versions.KustomizeVersion); err != nil {
+		message := fmt.Sprintf("%s %s: %v", path, versions.ClientVersion.GitVersion, err)
+		return "", &doctorIssue{message: message, hint: upgrade, warning: !required}
+	}
+	return fmt.Sprintf("%s %s (kustomize %s)", path, versions.ClientVersion.GitVersion, versions.KustomizeVersion), nil
+}
+
+// checkKustomize checks that the standalone kustomize binary is installed and supported.
+// Problems are only errors when kustomize is the configured backend.
+func checkKustomize(required bool) (string, error) {
+	const upgrade = "install kustomize 5.0.0 or later from https://kubectl.docs.kubernetes.io/installation/kustomize/"
+	path, err := exec.LookPath("kustomize")
+	if err != nil {
+		hint := upgrade
+		if !required {
+			hint = "only needed for --backend=kustomize"
+		}
+		return "", &doctorIssue{message: "not found in PATH", hint: hint, warning: !required}
+	}
+
+	output, err := exec.Command(path, "version").Output()
+	if err != nil {
+		return "", &doctorIssue{message: fmt.Sprintf("%s version failed: %v", path, err), hint: upgrade, warning: !required}
+	}
+	// Older releases print {Version:kustomize/v4.5.7 GitCommit:...}
+	found := regexp.MustCompile(`v\d+\.\d+\.\d+`).FindString(string(output))
+
+	if err := checkKustomizeVersion(found); err != nil {
+		return "", &doctorIssue{message: fmt.Sprintf("%s: %v", path, err), hint: upgrade, warning: !required}
+	}
+	return fmt.Sprintf("%s (%s)", path, found), nil
+}
+
+// checkKustomizeVersion checks a kustomize version against the minimum supported one
+func checkKustomizeVersion(v string) error {
+	if v == "" {
+		return fmt.Errorf("unknown kustomize version, %s or later is required", minKustomizeVersion)
+	}
+	parsed, err := semver.NewVersion(v)
+	if err != nil {
+		return fmt.Errorf("invalid kustomize version %q", v)
+	}
+	if parsed.LessThan(minKustomizeVersion) {
+		return fmt.Errorf("kustomize %s is older than the minimum supported %s", v, minKustomizeVersion)
+	}
+	return nil
+}
+
+// checkBuiltin reports the version of the kustomize library built into the binary
+func checkBuiltin() (string, error) {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == "sigs.k8s.io/kustomize/api" {
+				return "kustomize/api " + dep.Version, nil
+			}
+		}
+	}
+	return "kustomize library built in", nil
+}
+
+// checkTempDir checks that the post-renderer can write its temporary files
+func checkTempDir() (string, error) {
+	tempDir, err := extractor.NewTempDir()
+	if err != nil {
+		return "", &doctorIssue{message: err.Error(), hint: "set TMPDIR to a writable directory"}
+	}
+	defer tempDir.Cleanup()
+
+	if err := tempDir.WriteFile("kustomization.yaml", []byte("resources: []\n")); err != nil {
+		return "", &doctorIssue{message: err.Error(), hint: "set TMPDIR to a writable directory"}
+	}
+
+	return tempDir.Path + " is writable", nil
+}
+
+// checkPluginDir checks that, when run by Helm, the installed plugin is this binary and
+// has the same version
+func checkPluginDir(getenv func(string) string) (string, error) {
+	const reinstall = "reinstall the plugin with helm plugin uninstall and helm plugin install"
+	dir := getenv("HELM_PLUGIN_DIR")
+	if dir == "" {
+		return "not run by Helm, HELM_PLUGIN_DIR is not set", nil
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "plugin.yaml"))
+	if err != nil {
+		return "", &doctorIssue{message: fmt.Sprintf("HELM_PLUGIN_DIR %s has no plugin.yaml", dir), hint: reinstall}
+	}
+	var metadata struct {
+		Version string `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(content, &metadata); err != nil {
+		return "", &doctorIssue{message: fmt.Sprintf("invalid %s: %v", filepath.Join(dir, "plugin.yaml"), err), hint: reinstall}
+	}
+	if metadata.Version != version {
+		return "", &doctorIssue{
+			message: fmt.Sprintf("plugin.yaml in %s is version %s, but the binary is %s", dir, metadata.Version, version),
+			hint:    reinstall,
+		}
+	}
+
+	executable, err := os.Executable()
+	if err == nil {
+		executable, err = filepath.EvalSymlinks(executable)
+	}
+	pluginDir, dirErr := filepath.EvalSymlinks(dir)
+	if err == nil && dirErr == nil && filepath.Dir(executable) != pluginDir {
+		return "", &doctorIssue{
+			message: fmt.Sprintf("running %s, which isn't the binary of the plugin in %s", executable, dir),
+			hint:    reinstall + ", or run the binary of the plugin",
+		}
+	}
+	return fmt.Sprintf("%s (version %s)", dir, version), nil
+}
+
+// Smoke kustomization built by every backend, with the output it must produce
+var (
+	smokeFiles = map[string]string{
+		"kustomization.yaml": `resources:
+- configmap.yaml
+namePrefix: smoke-
+labels:
+- pairs:
+    app: smoke
+patches:
+- path: patch.yaml
+`,
+		"configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  greeting: hello
+`,
+		"patch.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  greeting: patched
+`,
+	}
+	smokeOutput = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: smoke-settings
+  labels:
+    app: smoke
+data:
+  greeting: patched
+`
+)
+
+// checkBuild builds the smoke kustomization with backend and compares the output
+func checkBuild(backend kustomize.Backend) (string, error) {
+	tempDir, err := extractor.NewTempDir()
+	if err != nil {
+		return "", &doctorIssue{message: err.Error(), hint: "set TMPDIR to a writable directory"}
+	}
+	defer tempDir.Cleanup()
+	if err := tempDir.ExtractFiles(smokeFiles); err != nil {
+		return "", &doctorIssue{message: err.Error(), hint: "set TMPDIR to a writable directory"}
+	}
+
+	hint := fmt.Sprintf("check the %s installation, or use another backend", backend)
+	output, _, err := kustomize.BuildWith(tempDir.Path, kustomize.BuildOptions{Backend: backend, Timeout: 30 * time.Second})
+	if err != nil {
+		return "", &doctorIssue{message: strings.TrimSpace(err.Error()), hint: hint}
+	}
+
+	got, err := parser.ParseManifests(output)
+	if err != nil {
+		return "", &doctorIssue{message: fmt.Sprintf("invalid output: %v", err), hint: hint}
+	}
+	want, err := parser.ParseManifests([]byte(smokeOutput))
+	if err != nil {
+		return "", err
+	}
+	if !reflect.DeepEqual(got.OtherResources, want.OtherResources) {
+		return "", &doctorIssue{message: fmt.Sprintf("unexpected output:\n%s", output), hint: hint}
+	}
+	return "smoke kustomization built as expected", nil
+}
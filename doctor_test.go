@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/owhelm/helm-kustomize/internal/kustomize"
+)
+
+func TestCheckKustomizeVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		wantErr string
+	}{
+		{version: "v5.7.1"},
+		{version: "v5.0.0"},
+		{version: "v4.5.7", wantErr: "kustomize v4.5.7 is older than the minimum supported 5.0.0"},
+		{version: "", wantErr: "unknown kustomize version"},
+		{version: "devel", wantErr: `invalid kustomize version "devel"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			err := checkKustomizeVersion(tt.version)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkKustomizeVersion() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkKustomizeVersion() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckPluginDir(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := t.TempDir()
+	if err := os.WriteFile(filepath.Join(other, "plugin.yaml"), []byte("name: helm-kustomize\nversion: "+version+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := t.TempDir()
+	if err := os.WriteFile(filepath.Join(old, "plugin.yaml"), []byte("name: helm-kustomize\nversion: 0.0.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		dir     string
+		want    string
+		wantErr string
+	}{
+		{name: "not run by Helm", dir: "", want: "HELM_PLUGIN_DIR is not set"},
+		{name: "no plugin.yaml", dir: t.TempDir(), wantErr: "has no plugin.yaml"},
+		{name: "other version", dir: old, wantErr: "is version 0.0.1, but the binary is " + version},
+		{name: "other binary", dir: other, wantErr: "running " + executable + ", which isn't the binary of the plugin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkPluginDir(func(string) string { return tt.dir })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("checkPluginDir() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !strings.Contains(got, tt.want) {
+				t.Errorf("checkPluginDir() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckBuild(t *testing.T) {
+	got, err := checkBuild(kustomize.Builtin)
+	if err != nil {
+		t.Fatalf("checkBuild() error = %v", err)
+	}
+	if got != "smoke kustomization built as expected" {
+		t.Errorf("checkBuild() = %q", got)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/owhelm/helm-kustomize/internal/config"
+	"github.com/owhelm/helm-kustomize/internal/extractor"
+	"github.com/owhelm/helm-kustomize/internal/kustomize"
+	"github.com/owhelm/helm-kustomize/internal/parser"
+)
+
+// templatesDir is the directory of the overlay --split-templates writes the Helm output to
+const templatesDir = "helm"
+
+// skippedFile is the file eject writes the resources that bypass kustomize to
+const skippedFile = "skipped.yaml"
+
+// runEject implements the eject command. It renders a chart, or reads a rendered manifest,
+// and writes the kustomization the post-renderer would build to a directory, so it can be
+// built with plain kustomize without the plugin.
+func runEject(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("eject", stderr)
+	renderer, err := postRendererFlags(flags, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	opts := chartFlags(flags)
+	output := flags.String("o", "", "directory to write the kustomization to, which must be empty")
+	split := flags.Bool("split-templates", false, "write the Helm output to one file per template instead of all.yaml")
+	if !parseFlags(flags, args, 1) {
+		return 2
+	}
+	if *output == "" {
+		fmt.Fprintln(stderr, "Error: the output directory is required, e.g. -o ejected/")
+		return 2
+	}
+	renderer.Stderr = stderr
+
+	manifests, err := readChartOrManifests(flags.Arg(0), stdin, *opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	result, err := parseManifests(manifests)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if result.KustomizePluginData == nil {
+		fmt.Fprintf(stderr, "Error: no %s resource found\n", parser.Kind)
+		return 1
+	}
+
+	var sources map[parser.ResourceKey]string
+	if *split {
+		sources = parser.Sources(manifests)
+	}
+	if err := eject(stdout, renderer, manifests, result, *output, sources); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// eject writes the kustomization the post-renderer would build to dir, for result parsed
+// from manifests. When sources is set, the Helm output is split into one file per template
+// instead of all.yaml.
+func eject(w io.Writer, renderer *KustomizePostRenderer, manifests []byte, result *parser.ParseResult, dir string, sources map[parser.ResourceKey]string) error {
+	opts, log, err := renderer.options(result.KustomizePluginData)
+	if err != nil {
+		return err
+	}
+
+	target, err := extractor.NewDir(dir)
+	if err != nil {
+		return err
+	}
+	defer target.Cleanup()
+	excludeResources(result, manifests, log)
+	if err := renderer.prepare(target, result, opts, log); err != nil {
+		return err
+	}
+	if sources != nil {
+		if err := splitTemplates(target, result.KustomizePluginData, result.OtherResources, sources, opts.Overlay); err != nil {
+			return err
+		}
+	}
+
+	if len(result.Skipped) > 0 {
+		if result.KustomizePluginData.HasFile(skippedFile) {
+			return fmt.Errorf("can't write the skipped resources to %s, which is an embedded file", skippedFile)
+		}
+		content, err := parser.MarshalResources(result.Skipped)
+		if err != nil {
+			return fmt.Errorf("failed to marshal resources for %s: %w", skippedFile, err)
+		}
+		if err := target.WriteFile(skippedFile, content); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "Ejected the kustomization to %s\n", dir)
+	printBuildCommand(w, dir, opts)
+	if len(result.Skipped) > 0 {
+		fmt.Fprintf(w, "%d resources bypass kustomize: add %s to its output\n", len(result.Skipped), filepath.Join(dir, skippedFile))
+	}
+	return nil
+}
+
+// splitTemplates replaces all.yaml in the overlay with one file per template the resources
+// come from, in the templates directory. Resources without a known template stay in all.yaml.
+func splitTemplates(dir *extractor.TempDir, data *parser.KustomizePluginData, resources []map[string]any, sources map[parser.ResourceKey]string, overlay string) error {
+	base := path.Join(overlay, templatesDir)
+	for _, name := range data.FileNames() {
+		if strings.HasPrefix(name, base+"/") {
+			return fmt.Errorf("can't split the templates into %s, which contains embedded files", base)
+		}
+	}
+
+	kustomizationPath := path.Join(overlay, "kustomization.yaml")
+	content, err := dir.ReadFile(kustomizationPath)
+	if err != nil {
+		return fmt.Errorf("splitting the templates requires a kustomization.yaml in the overlay: %w", err)
+	}
+	k, err := kustomize.ParseKustomization(content)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", kustomizationPath, err)
+	}
+
+	// Group the resources by template, in the order Helm rendered them
+	var files []string
+	byFile := map[string][]map[string]any{}
+	var remaining []map[string]any
+	for _, resource := range resources {
+		source := sources[parser.KeyOf(resource)]
+		if source == "" {
+			remaining = append(remaining, resource)
+			continue
+		}
+		name := path.Join(templatesDir, path.Clean(source))
+		if _, ok := byFile[name]; !ok {
+			files = append(files, name)
+		}
+		byFile[name] = append(byFile[name], resource)
+	}
+
+	for _, name := range files {
+		content, err := parser.MarshalResources(byFile[name])
+		if err != nil {
+			return fmt.Errorf("failed to marshal resources for %s: %w", name, err)
+		}
+		if err := dir.WriteFile(path.Join(overlay, name), content); err != nil {
+			return err
+		}
+	}
+
+	if len(remaining) > 0 {
+		content, err := parser.MarshalResources(remaining)
+		if err != nil {
+			return fmt.Errorf("failed to marshal resources for all.yaml: %w", err)
+		}
+		if err := dir.WriteFile(path.Join(overlay, "all.yaml"), content); err != nil {
+			return err
+		}
+		files = append(files, "all.yaml")
+	} else if err := dir.Remove(path.Join(overlay, "all.yaml")); err != nil {
+		return err
+	}
+
+	k.ReplaceResource("all.yaml", files...)
+	updated, err := k.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", kustomizationPath, err)
+	}
+	return dir.WriteFile(kustomizationPath, updated)
+}
+
+// printBuildCommand prints the command building the kustomization written to dir
+func printBuildCommand(w io.Writer, dir string, opts config.Options) {
+	buildDir := filepath.Join(dir, filepath.FromSlash(opts.Overlay))
+	switch opts.Backend {
+	case kustomize.Kustomize:
+		fmt.Fprintf(w, "Build it with: kustomize build %s\n", buildDir)
+	default:
+		fmt.Fprintf(w, "Build it with: kubectl kustomize %s\n", buildDir)
+	}
+}
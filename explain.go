@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+
+	"github.com/owhelm/helm-kustomize/internal/diff"
+	"github.com/owhelm/helm-kustomize/internal/extractor"
+	"github.com/owhelm/helm-kustomize/internal/kustomize"
+	"github.com/owhelm/helm-kustomize/internal/parser"
+)
+
+// Annotations added to the resources while explaining them, and removed from the output
+const (
+	originAnnotation          = "config.kubernetes.io/origin"
+	transformationsAnnotation = "alpha.config.kubernetes.io/transformations"
+	// trackingAnnotation identifies a resource across the builds of an explanation
+	trackingAnnotation = "helm-kustomize.owhelm.io/explain-id"
+)
+
+// explanation describes how the kustomization produced a resource
+type explanation struct {
+	key parser.ResourceKey
+	// origin is the embedded file the resource comes from, or the generator creating it
+	origin string
+	// template is the Helm template that rendered the resource, if any
+	template string
+	// steps lists the transformations that changed the resource, with their changes
+	steps []explainedStep
+	// transformations lists the transformations kustomize recorded for a resource that
+	// can't be followed across builds, such as a generated one
+	transformations []string
+	// total is the number of transformations of the build
+	total    int
+	resource map[string]any
+}
+
+// explainedStep is a transformation and the changes it made to a resource
+type explainedStep struct {
+	step    kustomize.Step
+	changes []diff.Change
+}
+
+// runExplain implements the explain command. It builds the embedded kustomization once per
+// transformation, with kustomize's build metadata enabled, and reports for one resource the
+// Helm template it comes from and the fields every transformation changed.
+func runExplain(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("explain", stderr)
+	renderer, err := postRendererFlags(flags, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	opts := chartFlags(flags)
+	if !parseFlags(flags, args, 2) {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "Error: the resource to explain is required, e.g. Deployment/web")
+		return 2
+	}
+	key, err := parser.ParseKey(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+	renderer.Stderr = stderr
+
+	manifests, err := readChartOrManifests(flags.Arg(1), stdin, *opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	e, err := renderer.explain(manifests, key)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := e.write(stdout); err != nil {
+		fmt.Fprintf(stderr, "Error: failed to write output: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// explain runs the post-renderer on manifests step by step to explain the resource with the given key
+func (k *KustomizePostRenderer) explain(manifests []byte, key parser.ResourceKey) (*explanation, error) {
+	result, err := parseManifests(manifests)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse input: %w", err)
+	}
+	if result.KustomizePluginData == nil {
+		return nil, fmt.Errorf("no %s resource found", parser.Kind)
+	}
+
+	opts, log, err := k.options(result.KustomizePluginData)
+	if err != nil {
+		return nil, err
+	}
+
+	tempDir, err := extractor.NewTempDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer tempDir.Cleanup()
+
+	excludeResources(result, manifests, log)
+	if err := k.prepare(tempDir, result, opts, log); err != nil {
+		return nil, err
+	}
+	pipeline, err := preparePipeline(tempDir, opts.Overlay)
+	if err != nil {
+		return nil, err
+	}
+
+	build := func(steps int) ([]map[string]any, []byte, error) {
+		files, err := pipeline.Truncate(steps)
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, content := range files {
+			if err := tempDir.WriteFile(name, content); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		buildDir := filepath.Join(tempDir.Path, filepath.FromSlash(opts.Overlay))
+		output, warnings, err := kustomize.BuildWith(buildDir, kustomize.BuildOptions{Backend: opts.Backend, Timeout: opts.Timeout})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to run kustomize: %w", err)
+		}
+		built, err := parser.ParseManifests(output)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse kustomize output: %w", err)
+		}
+		return built.OtherResources, warnings, nil
+	}
+
+	// The complete build finds the resource and what kustomize recorded about it
+	resources, warnings, err := build(len(pipeline.Steps))
+	if err != nil {
+		return nil, err
+	}
+	log.kustomizeWarnings(warnings)
+	final, err := findResource(resources, key)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &explanation{
+		key:      parser.KeyOf(final),
+		origin:   originOf(final, opts.Overlay),
+		total:    len(pipeline.Steps),
+		resource: stripBuildMetadata(final),
+	}
+
+	id := annotation(final, trackingAnnotation)
+	if id == "" {
+		e.transformations = transformationsOf(final, opts.Overlay)
+		return e, nil
+	}
+
+	// Rebuild with one more transformation at a time to find which ones changed the resource
+	var previous map[string]any
+	for steps := 0; steps <= len(pipeline.Steps); steps++ {
+		current := final
+		if steps < len(pipeline.Steps) {
+			if resources, _, err = build(steps); err != nil {
+				return nil, fmt.Errorf("failed to build the first %d transformations: %w", steps, err)
+			}
+			if current = findTracked(resources, id); current == nil {
+				return nil, fmt.Errorf("%s is missing after the first %d transformations", key, steps)
+			}
+		}
+		current = stripBuildMetadata(current)
+
+		if steps == 0 {
+			if e.origin == path.Join(opts.Overlay, "all.yaml") {
+				e.template = parser.Sources(manifests)[parser.KeyOf(current)]
+			}
+		} else if changes := diff.Fields(previous, current); len(changes) > 0 {
+			e.steps = append(e.steps, explainedStep{step: pipeline.Steps[steps-1], changes: changes})
+		}
+		previous = current
+	}
+
+	return e, nil
+}
+
+// preparePipeline enables the build metadata in the kustomization of the overlay, marks
+// every resource loaded by the build so it can be followed across builds, and returns the
+// pipeline of the build
+func preparePipeline(dir *extractor.TempDir, overlay string) (*kustomize.Pipeline, error) {
+	kustomizationPath := path.Join(overlay, "kustomization.yaml")
+	content, err := dir.ReadFile(kustomizationPath)
+	if err != nil {
+		return nil, fmt.Errorf("explain requires a kustomization.yaml in the overlay %q", overlay)
+	}
+	k, err := kustomize.ParseKustomization(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update %s: %w", kustomizationPath, err)
+	}
+	if k.AddBuildMetadata("originAnnotations", "transformerAnnotations") {
+		updated, err := k.Marshal()
+		if err != nil {
+			return nil, fmt.Errorf("failed to update %s: %w", kustomizationPath, err)
+		}
+		if err := dir.WriteFile(kustomizationPath, updated); err != nil {
+			return nil, err
+		}
+	}
+
+	pipeline, err := kustomize.NewPipeline(overlay, func(name string) ([]byte, bool) {
+		content, err := dir.ReadFile(name)
+		return content, err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range pipeline.ResourceFiles {
+		content, err := dir.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		resources, err := parser.ParseManifests(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		for i, resource := range resources.OtherResources {
+			setAnnotation(resource, trackingAnnotation, fmt.Sprintf("%s#%d", name, i))
+		}
+		marked, err := parser.MarshalResources(resources.OtherResources)
+		if err != nil {
+			return nil, err
+		}
+		if err := dir.WriteFile(name, marked); err != nil {
+			return nil, err
+		}
+	}
+
+	return pipeline, nil
+}
+
+// findResource returns the resource with the given key. Without namespace, the key must
+// match a single resource.
+func findResource(resources []map[string]any, key parser.ResourceKey) (map[string]any, error) {
+	var found []map[string]any
+	for _, resource := range resources {
+		k := parser.KeyOf(resource)
+		if k.Kind == key.Kind && k.Name == key.Name && (key.Namespace == "" || k.Namespace == key.Namespace) {
+			found = append(found, resource)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%s not found in the kustomize output", key)
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("%s matches %d resources, use kind/namespace/name", key, len(found))
+	}
+}
+
+// findTracked returns the resource with the tracking annotation id
+func findTracked(resources []map[string]any, id string) map[string]any {
+	for _, resource := range resources {
+		if annotation(resource, trackingAnnotation) == id {
+			return resource
+		}
+	}
+	return nil
+}
+
+// originOf describes where a resource comes from using its origin annotation, with paths
+// relative to the root of the embedded files
+func originOf(resource map[string]any, overlay string) string {
+	var origin struct {
+		Path         string `yaml:"path"`
+		ConfiguredIn string `yaml:"configuredIn"`
+		ConfiguredBy struct {
+			Kind string `yaml:"kind"`
+			Name string `yaml:"name"`
+		} `yaml:"configuredBy"`
+	}
+	if err := yaml.Unmarshal([]byte(annotation(resource, originAnnotation)), &origin); err != nil {
+		return ""
+	}
+
+	switch {
+	case origin.Path != "":
+		return path.Join(overlay, origin.Path)
+	case origin.ConfiguredIn != "":
+		return strings.Join(strings.Fields(fmt.Sprintf("%s %s in %s", origin.ConfiguredBy.Kind, origin.ConfiguredBy.Name, path.Join(overlay, origin.ConfiguredIn))), " ")
+	default:
+		return ""
+	}
+}
+
+// transformationsOf lists the transformations kustomize recorded in the annotations of a resource
+func transformationsOf(resource map[string]any, overlay string) []string {
+	var transformations []struct {
+		ConfiguredIn string `yaml:"configuredIn"`
+		ConfiguredBy struct {
+			Kind string `yaml:"kind"`
+		} `yaml:"configuredBy"`
+	}
+	if err := yaml.Unmarshal([]byte(annotation(resource, transformationsAnnotation)), &transformations); err != nil {
+		return nil
+	}
+
+	lines := make([]string, 0, len(transformations))
+	for _, t := range transformations {
+		lines = append(lines, fmt.Sprintf("%s (%s)", path.Join(overlay, t.ConfiguredIn), t.ConfiguredBy.Kind))
+	}
+	return lines
+}
+
+// annotation returns the value of an annotation of a resource
+func annotation(resource map[string]any, name string) string {
+	metadata, _ := resource["metadata"].(map[string]any)
+	annotations, _ := metadata["annotations"].(map[string]any)
+	value, _ := annotations[name].(string)
+	return value
+}
+
+// setAnnotation sets an annotation of a resource
+func setAnnotation(resource map[string]any, name, value string) {
+	metadata, ok := resource["metadata"].(map[string]any)
+	if !ok {
+		metadata = map[string]any{}
+		resource["metadata"] = metadata
+	}
+	annotations, ok := metadata["annotations"].(map[string]any)
+	if !ok {
+		annotations = map[string]any{}
+		metadata["annotations"] = annotations
+	}
+	annotations[name] = value
+}
+
+// stripBuildMetadata returns a copy of resource without the annotations added to explain it
+func stripBuildMetadata(resource map[string]any) map[string]any {
+	stripped := maps.Clone(resource)
+	metadata, ok := resource["metadata"].(map[string]any)
+	if !ok {
+		return stripped
+	}
+	metadata = maps.Clone(metadata)
+	stripped["metadata"] = metadata
+
+	annotations, ok := metadata["annotations"].(map[string]any)
+	if !ok {
+		return stripped
+	}
+	annotations = maps.Clone(annotations)
+	for _, name := range []string{originAnnotation, transformationsAnnotation, trackingAnnotation} {
+		delete(annotations, name)
+	}
+	if len(annotations) == 0 {
+		delete(metadata, "annotations")
+	} else {
+		metadata["annotations"] = annotations
+	}
+	return stripped
+}
+
+// write prints the explanation for humans
+func (e *explanation) write(w io.Writer) error {
+	var out bytes.Buffer
+	fmt.Fprintln(&out, e.key)
+	if e.origin != "" {
+		fmt.Fprintf(&out, "  Origin: %s\n", e.origin)
+	}
+	if e.template != "" {
+		fmt.Fprintf(&out, "  Helm template: %s\n", e.template)
+	}
+	fmt.Fprintln(&out)
+
+	if e.transformations != nil {
+		fmt.Fprintln(&out, "Transformations recorded by kustomize (changes can't be shown for generated resources):")
+		for _, t := range e.transformations {
+			fmt.Fprintf(&out, "  %s\n", t)
+		}
+	} else {
+		for _, s := range e.steps {
+			fmt.Fprintln(&out, s.step)
+			for _, change := range s.changes {
+				fmt.Fprintf(&out, "  %s\n", change)
+			}
+		}
+		fmt.Fprintf(&out, "%d of %d transformations changed the resource\n", len(e.steps), e.total)
+	}
+
+	fmt.Fprintf(&out, "\nResult:\n")
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(e.resource); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/owhelm/helm-kustomize/internal/kustomize"
+)
+
+// runFix implements the fix subcommand. It migrates deprecated fields in every kustomization
+// file under a directory, so chart authors can commit the fixed files. The fixed files are
+// printed to stdout, or written in place with -w. It returns the process exit code.
+func runFix(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("fix", stderr)
+	write := flags.Bool("w", false, "write the fixed files in place instead of printing them")
+	if !parseFlags(flags, args, 1) {
+		return 2
+	}
+
+	dir := "."
+	if flags.NArg() == 1 {
+		dir = flags.Arg(0)
+	}
+
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !kustomize.IsKustomizationFile(filePath) {
+			return nil
+		}
+
+		updated, fixed, err := fixFile(filePath, stderr)
+		if err != nil || len(fixed) == 0 {
+			return err
+		}
+
+		if !*write {
+			fmt.Fprintf(stdout, "---\n# %s\n%s", filePath, updated)
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filePath, updated, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filePath, err)
+		}
+		fmt.Fprintf(stderr, "Fixed %s: migrated %s\n", filePath, strings.Join(fixed, ", "))
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// fixFile migrates the deprecated fields of a single kustomization file and returns
+// the updated content along with the migrated fields
+func fixFile(filePath string, stderr io.Writer) ([]byte, []string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+
+	k, err := kustomize.ParseKustomization(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+
+	fixed, err := k.FixDeprecatedFields()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fix %s: %w", filePath, err)
+	}
+
+	if remaining := k.DeprecatedFields(); len(remaining) > 0 {
+		fmt.Fprintf(stderr, "Warning: %s uses deprecated fields %s, which can't be migrated automatically\n", filePath, strings.Join(remaining, ", "))
+	}
+
+	if len(fixed) == 0 {
+		return nil, nil, nil
+	}
+
+	updated, err := k.Marshal()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fix %s: %w", filePath, err)
+	}
+
+	return updated, fixed, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const deprecatedKustomization = `resources:
+- all.yaml
+commonLabels:
+  app: myapp
+vars:
+- name: SERVICE
+`
+
+func TestRunFix_Print(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "overlays", "kustomization.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(deprecatedKustomization), 0644); err != nil {
+		t.Fatalf("Failed to write kustomization.yaml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources:\n- overlays\n"), 0644); err != nil {
+		t.Fatalf("Failed to write kustomization.yaml: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runFix([]string{dir}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("runFix() = %d, want 0; stderr: %s", code, stderr.String())
+	}
+
+	out := stdout.String()
+	if !strings.Contains(out, "# "+path+"\n") {
+		t.Errorf("Expected output to name %s, got:\n%s", path, out)
+	}
+	if strings.Contains(out, "commonLabels") || !strings.Contains(out, "includeSelectors: true") {
+		t.Errorf("Expected commonLabels to be migrated, got:\n%s", out)
+	}
+	if strings.Count(out, "---\n") != 1 {
+		t.Errorf("Expected only the deprecated kustomization to be printed, got:\n%s", out)
+	}
+	if !strings.Contains(stderr.String(), "vars") {
+		t.Errorf("Expected warning about vars, got: %s", stderr.String())
+	}
+
+	// Without -w the file is left untouched
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read kustomization.yaml: %v", err)
+	}
+	if string(content) != deprecatedKustomization {
+		t.Errorf("File was modified without -w:\n%s", content)
+	}
+}
+
+func TestRunFix_Write(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kustomization.yaml")
+	if err := os.WriteFile(path, []byte(deprecatedKustomization), 0644); err != nil {
+		t.Fatalf("Failed to write kustomization.yaml: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runFix([]string{"-w", dir}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("runFix() = %d, want 0; stderr: %s", code, stderr.String())
+	}
+
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no output with -w, got:\n%s", stdout.String())
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read kustomization.yaml: %v", err)
+	}
+	if strings.Contains(string(content), "commonLabels") {
+		t.Errorf("Expected commonLabels to be migrated in place, got:\n%s", content)
+	}
+}
+
+func TestRunFix_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("bases: ../base\n"), 0644); err != nil {
+		t.Fatalf("Failed to write kustomization.yaml: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "invalid kustomization", args: []string{dir}, want: 1},
+		{name: "missing directory", args: []string{filepath.Join(dir, "missing")}, want: 1},
+		{name: "too many arguments", args: []string{dir, dir}, want: 2},
+		{name: "unknown flag", args: []string{"--unknown"}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runFix(tt.args, nil, &stdout, &stderr); code != tt.want {
+				t.Errorf("runFix() = %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/owhelm/helm-kustomize/internal/scaffold"
+)
+
+// runInit implements the init command. It adds a kustomization folder and the template
+// embedding it to a chart, which defaults to the current directory.
+func runInit(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("init", stderr)
+	force := flags.Bool("force", false, "overwrite a modified templates/kustomize-files.yaml")
+	if !parseFlags(flags, args, 1) {
+		return 2
+	}
+
+	chartDir := "."
+	if flags.NArg() == 1 {
+		chartDir = flags.Arg(0)
+	}
+
+	result, err := scaffold.Init(chartDir, scaffold.Options{Force: *force})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	printScaffoldResult(result, stdout, stderr)
+	return 0
+}
+
+// runImport implements the import command. It copies a standalone kustomization into the
+// kustomization folder of a chart, which defaults to the current directory.
+func runImport(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("import", stderr)
+	force := flags.Bool("force", false, "replace an existing kustomization folder and overwrite a modified templates/kustomize-files.yaml")
+	if !parseFlags(flags, args, 2) {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintf(stderr, "Error: the directory to import is required\n\n")
+		flags.Usage()
+		return 2
+	}
+
+	chartDir := "."
+	if flags.NArg() == 2 {
+		chartDir = flags.Arg(1)
+	}
+
+	result, err := scaffold.Import(flags.Arg(0), chartDir, scaffold.Options{Force: *force})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	printScaffoldResult(result, stdout, stderr)
+	return 0
+}
+
+// printScaffoldResult lists the files written and kept, followed by any warnings
+func printScaffoldResult(result *scaffold.Result, stdout, stderr io.Writer) {
+	for _, name := range result.Created {
+		fmt.Fprintf(stdout, "Created %s\n", name)
+	}
+	for _, name := range result.Skipped {
+		fmt.Fprintf(stdout, "Kept existing %s\n", name)
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(stderr, "Warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/owhelm/helm-kustomize/internal/extractor"
+	"github.com/owhelm/helm-kustomize/internal/parser"
+)
+
+// runInspect implements the inspect command. It lists the files embedded in the
+// KustomizePluginData resource of a rendered manifest or of a chart, which is rendered
+// without applying the kustomization. With --file it prints one of the files, and with
+// --extract it writes the kustomization the post-renderer would build to a directory.
+func runInspect(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("inspect", stderr)
+	renderer, err := postRendererFlags(flags, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	opts := chartFlags(flags)
+	file := flags.String("file", "", "print the embedded file at this path")
+	extract := flags.String("extract", "", "extract the kustomization to this directory, which must be empty")
+	if !parseFlags(flags, args, 1) {
+		return 2
+	}
+	if *file != "" && *extract != "" {
+		fmt.Fprintln(stderr, "Error: --file and --extract can't be used together")
+		return 2
+	}
+	renderer.Stderr = stderr
+
+	manifests, err := readChartOrManifests(flags.Arg(0), stdin, *opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	result, err := parseManifests(manifests)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	data := result.KustomizePluginData
+	if data == nil {
+		fmt.Fprintf(stderr, "Error: no %s resource found\n", parser.Kind)
+		return 1
+	}
+
+	switch {
+	case *file != "":
+		err = printFile(stdout, data, *file)
+	case *extract != "":
+		err = extractTree(stdout, renderer, manifests, result, *extract)
+	default:
+		err = listFiles(stdout, result)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// listFiles prints the size and SHA-256 hash of every embedded file, the components and a summary
+func listFiles(w io.Writer, result *parser.ParseResult) error {
+	data := result.KustomizePluginData
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "SIZE\tSHA256\tPATH")
+	for _, name := range data.FileNames() {
+		content, suffix := data.BinaryFiles[name], " (binary)"
+		if _, binary := data.BinaryFiles[name]; !binary {
+			content, suffix = []byte(data.Files[name]), ""
+		}
+		fmt.Fprintf(table, "%d\t%x\t%s%s\n", len(content), sha256.Sum256(content), name, suffix)
+	}
+	if err := table.Flush(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	for _, c := range data.Components {
+		fmt.Fprintf(w, "Component %s: %s (enabled by default: %t)\n", c.Name, c.Path, c.Enabled)
+	}
+	fmt.Fprintf(w, "%d embedded files, %d resources\n", len(data.FileNames()), len(result.OtherResources))
+	return nil
+}
+
+// printFile writes the content of the embedded file name
+func printFile(w io.Writer, data *parser.KustomizePluginData, name string) error {
+	content, ok := data.BinaryFiles[name]
+	if !ok {
+		text, ok := data.Files[name]
+		if !ok {
+			return fmt.Errorf("%s is not embedded in the %s resource", name, parser.Kind)
+		}
+		content = []byte(text)
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// extractTree writes the kustomization the post-renderer would build to dir, with the
+// rendered manifests in all.yaml, so it can be built by hand. result is parsed from manifests.
+func extractTree(w io.Writer, renderer *KustomizePostRenderer, manifests []byte, result *parser.ParseResult, dir string) error {
+	opts, log, err := renderer.options(result.KustomizePluginData)
+	if err != nil {
+		return err
+	}
+
+	target, err := extractor.NewDir(dir)
+	if err != nil {
+		return err
+	}
+	defer target.Cleanup()
+	excludeResources(result, manifests, log)
+	if err := renderer.prepare(target, result, opts, log); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Extracted the kustomization to %s\n", dir)
+	printBuildCommand(w, dir, opts)
+	return nil
+}
@@ -0,0 +1,407 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v4"
+
+	"github.com/owhelm/helm-kustomize/internal/kustomize"
+)
+
+const (
+	// ConfigEnv names a config file to use instead of the default location
+	ConfigEnv = "HELM_KUSTOMIZE_CONFIG"
+	// EnableComponentsEnv lists components to enable, separated by commas
+	EnableComponentsEnv = "HELM_KUSTOMIZE_ENABLE_COMPONENTS"
+	// DisableComponentsEnv lists components to disable, separated by commas
+	DisableComponentsEnv = "HELM_KUSTOMIZE_DISABLE_COMPONENTS"
+
+	// envPrefix is prepended to the upper-cased flag name of an option to get its environment variable
+	envPrefix = "HELM_KUSTOMIZE_"
+)
+
+// Output formats
+const (
+	OutputYAML = "yaml"
+	OutputJSON = "json"
+)
+
+// ReportStderr is the report destination that writes the change report to stderr
+const ReportStderr = "stderr"
+
+// Change report formats
+const (
+	ReportText = "text"
+	ReportJSON = "json"
+)
+
+// option describes a setting that can be given as a flag, environment variable or config file key
+type option struct {
+	flag   string
+	key    string
+	usage  string
+	isBool bool
+}
+
+// options lists the settings in the order their flags are registered
+var options = []option{
+	{flag: "backend", key: "backend", usage: "kustomize implementation: kubectl, kustomize or builtin (default kubectl)"},
+	{flag: "timeout", key: "timeout", usage: "maximum duration of the kustomize build, e.g. 30s (default no limit)"},
+	{flag: "overlay", key: "overlay", usage: "directory within the embedded files to build (default the root)"},
+	{flag: "warnings-as-errors", key: "warningsAsErrors", usage: "fail when warnings are reported", isBool: true},
+	{flag: "output", key: "output", usage: "output format: yaml or json (default yaml)"},
+	{flag: "debug", key: "debug", usage: "print debug information to stderr", isBool: true},
+	{flag: "fix-deprecated", key: "fixDeprecated", usage: "migrate deprecated kustomization fields before the build", isBool: true},
+	{flag: "report", key: "report", usage: "write a report of the changes made by kustomize to stderr or to a file"},
+	{flag: "report-format", key: "reportFormat", usage: "change report format: text or json (default text)"},
+	{flag: "normalize", key: "normalize", usage: "fix line endings, BOMs, tab indentation and trailing whitespace in embedded YAML and JSON files (default true)", isBool: true},
+}
+
+// Layer holds the settings given by one configuration source. Nil fields are unset and
+// fall through to the layers below it.
+type Layer struct {
+	Backend          *kustomize.Backend
+	Timeout          *time.Duration
+	Overlay          *string
+	WarningsAsErrors *bool
+	Output           *string
+	Debug            *bool
+	FixDeprecated    *bool
+	Report           *string
+	ReportFormat     *string
+	Normalize        *bool
+	// Components enables (true) or disables (false) components by name
+	Components map[string]bool
+}
+
+// Options are the effective settings once all layers are merged
+type Options struct {
+	Backend          kustomize.Backend
+	Timeout          time.Duration
+	Overlay          string
+	WarningsAsErrors bool
+	Output           string
+	Debug            bool
+	FixDeprecated    bool
+	// Report is where the change report is written: stderr, a file path, or empty for none
+	Report       string
+	ReportFormat string
+	// Normalize fixes the encoding problems of embedded files before the build
+	Normalize  bool
+	Components map[string]bool
+}
+
+// Resolve merges layers, lowest precedence first, and fills in the defaults of unset settings
+func Resolve(layers ...Layer) Options {
+	merged := Layer{}
+	for _, l := range layers {
+		merged = merged.Merge(l)
+	}
+
+	opts := Options{
+		Backend:      kustomize.Kubectl,
+		Overlay:      ".",
+		Output:       OutputYAML,
+		ReportFormat: ReportText,
+		Normalize:    true,
+		Components:   merged.Components,
+	}
+	if merged.Backend != nil {
+		opts.Backend = *merged.Backend
+	}
+	if merged.Timeout != nil {
+		opts.Timeout = *merged.Timeout
+	}
+	if merged.Overlay != nil {
+		opts.Overlay = *merged.Overlay
+	}
+	if merged.WarningsAsErrors != nil {
+		opts.WarningsAsErrors = *merged.WarningsAsErrors
+	}
+	if merged.Output != nil {
+		opts.Output = *merged.Output
+	}
+	if merged.Debug != nil {
+		opts.Debug = *merged.Debug
+	}
+	if merged.FixDeprecated != nil {
+		opts.FixDeprecated = *merged.FixDeprecated
+	}
+	if merged.Report != nil {
+		opts.Report = *merged.Report
+	}
+	if merged.ReportFormat != nil {
+		opts.ReportFormat = *merged.ReportFormat
+	}
+	if merged.Normalize != nil {
+		opts.Normalize = *merged.Normalize
+	}
+	return opts
+}
+
+// Merge returns a copy of l with every setting of over applied on top
+func (l Layer) Merge(over Layer) Layer {
+	merged := l
+	if over.Backend != nil {
+		merged.Backend = over.Backend
+	}
+	if over.Timeout != nil {
+		merged.Timeout = over.Timeout
+	}
+	if over.Overlay != nil {
+		merged.Overlay = over.Overlay
+	}
+	if over.WarningsAsErrors != nil {
+		merged.WarningsAsErrors = over.WarningsAsErrors
+	}
+	if over.Output != nil {
+		merged.Output = over.Output
+	}
+	if over.Debug != nil {
+		merged.Debug = over.Debug
+	}
+	if over.FixDeprecated != nil {
+		merged.FixDeprecated = over.FixDeprecated
+	}
+	if over.Report != nil {
+		merged.Report = over.Report
+	}
+	if over.ReportFormat != nil {
+		merged.ReportFormat = over.ReportFormat
+	}
+	if over.Normalize != nil {
+		merged.Normalize = over.Normalize
+	}
+	if len(over.Components) > 0 {
+		merged.Components = maps.Clone(l.Components)
+		if merged.Components == nil {
+			merged.Components = map[string]bool{}
+		}
+		maps.Copy(merged.Components, over.Components)
+	}
+	return merged
+}
+
+// Set parses value for the setting with the given flag name
+func (l *Layer) Set(name, value string) error {
+	switch name {
+	case "backend":
+		backend := kustomize.Backend(value)
+		if !slices.Contains(kustomize.Backends, backend) {
+			return fmt.Errorf("invalid backend %q, must be one of %s", value, joinBackends())
+		}
+		l.Backend = &backend
+	case "timeout":
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("invalid timeout %q, must be a non-negative duration such as 30s", value)
+		}
+		l.Timeout = &timeout
+	case "overlay":
+		overlay := filepath.ToSlash(filepath.Clean(value))
+		if filepath.IsAbs(value) || overlay == ".." || strings.HasPrefix(overlay, "../") {
+			return fmt.Errorf("invalid overlay %q, must be a relative path within the embedded files", value)
+		}
+		l.Overlay = &overlay
+	case "output":
+		if value != OutputYAML && value != OutputJSON {
+			return fmt.Errorf("invalid output %q, must be %s or %s", value, OutputYAML, OutputJSON)
+		}
+		l.Output = &value
+	case "report":
+		l.Report = &value
+	case "report-format":
+		if value != ReportText && value != ReportJSON {
+			return fmt.Errorf("invalid report format %q, must be %s or %s", value, ReportText, ReportJSON)
+		}
+		l.ReportFormat = &value
+	case "warnings-as-errors", "debug", "fix-deprecated", "normalize":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value %q, must be true or false", name, value)
+		}
+		switch name {
+		case "warnings-as-errors":
+			l.WarningsAsErrors = &enabled
+		case "debug":
+			l.Debug = &enabled
+		case "normalize":
+			l.Normalize = &enabled
+		default:
+			l.FixDeprecated = &enabled
+		}
+	default:
+		return fmt.Errorf("unknown option %q", name)
+	}
+	return nil
+}
+
+// SetComponents records enabled for every component in the comma-separated list
+func (l *Layer) SetComponents(list string, enabled bool) {
+	for name := range strings.SplitSeq(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			if l.Components == nil {
+				l.Components = map[string]bool{}
+			}
+			l.Components[name] = enabled
+		}
+	}
+}
+
+// RegisterFlags registers a flag for every setting on flags. Parsed flags are stored in l,
+// replacing the settings it already holds.
+func (l *Layer) RegisterFlags(flags *flag.FlagSet) {
+	for _, opt := range options {
+		set := func(value string) error { return l.Set(opt.flag, value) }
+		if opt.isBool {
+			flags.BoolFunc(opt.flag, opt.usage, set)
+		} else {
+			flags.Func(opt.flag, opt.usage, set)
+		}
+	}
+
+	flags.Func("enable-component", "enable a component (repeatable, comma-separated)", func(value string) error {
+		l.SetComponents(value, true)
+		return nil
+	})
+	flags.Func("disable-component", "disable a component (repeatable, comma-separated)", func(value string) error {
+		l.SetComponents(value, false)
+		return nil
+	})
+}
+
+// FromEnv reads the settings given as HELM_KUSTOMIZE_* environment variables.
+// HELM_DEBUG, which Helm sets for plugins when run with --debug, enables debug output.
+func FromEnv(getenv func(string) string) (Layer, error) {
+	l := Layer{}
+
+	if value := getenv("HELM_DEBUG"); value != "" {
+		if err := l.Set("debug", value); err != nil {
+			return Layer{}, fmt.Errorf("HELM_DEBUG: %w", err)
+		}
+	}
+
+	for _, opt := range options {
+		name := EnvName(opt.flag)
+		if value := getenv(name); value != "" {
+			if err := l.Set(opt.flag, value); err != nil {
+				return Layer{}, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+
+	l.SetComponents(getenv(EnableComponentsEnv), true)
+	l.SetComponents(getenv(DisableComponentsEnv), false)
+	return l, nil
+}
+
+// EnvName returns the environment variable of the setting with the given flag name
+func EnvName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Path returns the config file location: HELM_KUSTOMIZE_CONFIG if set, otherwise
+// helm-kustomize/config.yaml in $XDG_CONFIG_HOME, which defaults to ~/.config
+func Path(getenv func(string) string) string {
+	if path := getenv(ConfigEnv); path != "" {
+		return path
+	}
+
+	configHome := getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home := getenv("HOME")
+		if home == "" {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "helm-kustomize", "config.yaml")
+}
+
+// FromFile reads the config file at Path. A missing file at the default location is not an
+// error, but a file named by HELM_KUSTOMIZE_CONFIG must exist.
+func FromFile(getenv func(string) string) (Layer, error) {
+	path := Path(getenv)
+	if path == "" {
+		return Layer{}, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && getenv(ConfigEnv) == "" {
+		return Layer{}, nil
+	}
+	if err != nil {
+		return Layer{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[string]any
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return Layer{}, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	l, err := FromMap(raw)
+	if err != nil {
+		return Layer{}, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// FromMap reads settings from a map keyed by config file keys, such as the config
+// file or the options declared by a chart
+func FromMap(raw map[string]any) (Layer, error) {
+	l := Layer{}
+
+	for _, key := range slices.Sorted(maps.Keys(raw)) {
+		value := raw[key]
+
+		if key == "components" {
+			components, ok := value.(map[string]any)
+			if !ok {
+				return Layer{}, fmt.Errorf("components must be a map of component names to booleans")
+			}
+			for name, enabled := range components {
+				b, ok := enabled.(bool)
+				if !ok {
+					return Layer{}, fmt.Errorf("components.%s must be a boolean", name)
+				}
+				l.SetComponents(name, b)
+			}
+			continue
+		}
+
+		i := slices.IndexFunc(options, func(o option) bool { return o.key == key })
+		if i < 0 {
+			return Layer{}, fmt.Errorf("unknown option %q", key)
+		}
+
+		switch value.(type) {
+		case string, bool, int:
+		default:
+			return Layer{}, fmt.Errorf("%s must be a scalar, got %T", key, value)
+		}
+		if err := l.Set(options[i].flag, fmt.Sprint(value)); err != nil {
+			return Layer{}, err
+		}
+	}
+
+	return l, nil
+}
+
+// joinBackends lists the supported backends for error messages
+func joinBackends() string {
+	names := make([]string, 0, len(kustomize.Backends))
+	for _, b := range kustomize.Backends {
+		names = append(names, string(b))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owhelm/helm-kustomize/internal/kustomize"
+)
+
+func TestResolve_Defaults(t *testing.T) {
+	opts := Resolve()
+
+	if opts.Backend != kustomize.Kubectl || opts.Overlay != "." || opts.Output != OutputYAML || opts.ReportFormat != ReportText {
+		t.Errorf("Resolve() = %+v, want kubectl backend, root overlay, yaml output and text reports", opts)
+	}
+	if opts.Timeout != 0 || opts.Debug || opts.WarningsAsErrors || opts.FixDeprecated || opts.Report != "" {
+		t.Errorf("Resolve() = %+v, want everything else disabled", opts)
+	}
+	if !opts.Normalize {
+		t.Errorf("Resolve() = %+v, want embedded files normalized", opts)
+	}
+}
+
+func TestResolve_Precedence(t *testing.T) {
+	chart, err := FromMap(map[string]any{
+		"overlay":          "overlays/prod",
+		"timeout":          "10s",
+		"warningsAsErrors": true,
+		"components":       map[string]any{"istio": true, "pdb": true},
+	})
+	if err != nil {
+		t.Fatalf("FromMap() error = %v, want nil", err)
+	}
+
+	file := Layer{}
+	if err := file.Set("timeout", "20s"); err != nil {
+		t.Fatal(err)
+	}
+	file.SetComponents("istio", false)
+
+	env, err := FromEnv(func(key string) string {
+		return map[string]string{
+			"HELM_KUSTOMIZE_OUTPUT": "json",
+			"HELM_KUSTOMIZE_DEBUG":  "true",
+		}[key]
+	})
+	if err != nil {
+		t.Fatalf("FromEnv() error = %v, want nil", err)
+	}
+
+	args := Layer{}
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	args.RegisterFlags(flags)
+	if err := flags.Parse([]string{"--output=yaml", "--disable-component=pdb", "--backend", "builtin"}); err != nil {
+		t.Fatalf("Parse() error = %v, want nil", err)
+	}
+
+	opts := Resolve(chart, file, env, args)
+
+	want := Options{
+		Backend:          kustomize.Builtin,
+		Timeout:          20 * time.Second,
+		Overlay:          "overlays/prod",
+		WarningsAsErrors: true,
+		Output:           OutputYAML,
+		Debug:            true,
+	}
+	if opts.Backend != want.Backend || opts.Timeout != want.Timeout || opts.Overlay != want.Overlay ||
+		opts.WarningsAsErrors != want.WarningsAsErrors || opts.Output != want.Output || opts.Debug != want.Debug {
+		t.Errorf("Resolve() = %+v, want %+v", opts, want)
+	}
+	if opts.Components["istio"] || opts.Components["pdb"] || len(opts.Components) != 2 {
+		t.Errorf("Components = %v, want istio and pdb disabled", opts.Components)
+	}
+
+	// Merging must not modify the lower layers
+	if !chart.Components["istio"] {
+		t.Error("Resolve() modified the components of a lower layer")
+	}
+}
+
+func TestLayer_Set_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "backend", value: "helm", wantErr: `invalid backend "helm", must be one of kubectl, kustomize, builtin`},
+		{name: "timeout", value: "soon", wantErr: `invalid timeout "soon"`},
+		{name: "timeout", value: "-1s", wantErr: `invalid timeout "-1s"`},
+		{name: "timeout", value: "30", wantErr: `invalid timeout "30", must be a non-negative duration such as 30s`},
+		{name: "overlay", value: "../outside", wantErr: `invalid overlay "../outside"`},
+		{name: "overlay", value: "/abs", wantErr: `invalid overlay "/abs"`},
+		{name: "output", value: "xml", wantErr: `invalid output "xml", must be yaml or json`},
+		{name: "report-format", value: "html", wantErr: `invalid report format "html", must be text or json`},
+		{name: "debug", value: "maybe", wantErr: `invalid debug value "maybe"`},
+		{name: "color", value: "true", wantErr: `unknown option "color"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			l := Layer{}
+			err := l.Set(tt.name, tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Set() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLayer_Set_CleansOverlay(t *testing.T) {
+	l := Layer{}
+	if err := l.Set("overlay", "overlays/prod/"); err != nil {
+		t.Fatalf("Set() error = %v, want nil", err)
+	}
+	if *l.Overlay != "overlays/prod" {
+		t.Errorf("Overlay = %q, want %q", *l.Overlay, "overlays/prod")
+	}
+}
+
+func TestFromEnv_Invalid(t *testing.T) {
+	_, err := FromEnv(func(key string) string {
+		if key == "HELM_KUSTOMIZE_TIMEOUT" {
+			return "forever"
+		}
+		return ""
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "HELM_KUSTOMIZE_TIMEOUT: ") {
+		t.Errorf("FromEnv() error = %v, want error naming the variable", err)
+	}
+}
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{name: "explicit", env: map[string]string{ConfigEnv: "/etc/hk.yaml", "XDG_CONFIG_HOME": "/xdg"}, want: "/etc/hk.yaml"},
+		{name: "xdg", env: map[string]string{"XDG_CONFIG_HOME": "/xdg", "HOME": "/home/me"}, want: "/xdg/helm-kustomize/config.yaml"},
+		{name: "home", env: map[string]string{"HOME": "/home/me"}, want: "/home/me/.config/helm-kustomize/config.yaml"},
+		{name: "none", env: map[string]string{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Path(func(key string) string { return tt.env[key] }); got != tt.want {
+				t.Errorf("Path() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromFile(t *testing.T) {
+	dir := t.TempDir()
+	configDir := filepath.Join(dir, "helm-kustomize")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "backend: kustomize\ntimeout: 1m\nfixDeprecated: true\nnormalize: false\ncomponents:\n  istio: true\n"
+	if err := os.WriteFile(filepath.Join(configDir, "config.yaml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := FromFile(func(key string) string {
+		if key == "XDG_CONFIG_HOME" {
+			return dir
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatalf("FromFile() error = %v, want nil", err)
+	}
+
+	opts := Resolve(l)
+	if opts.Backend != kustomize.Kustomize || opts.Timeout != time.Minute || !opts.FixDeprecated || opts.Normalize || !opts.Components["istio"] {
+		t.Errorf("Resolve(FromFile()) = %+v, want settings from the file", opts)
+	}
+}
+
+func TestFromFile_Missing(t *testing.T) {
+	dir := t.TempDir()
+
+	// A missing file at the default location is fine
+	l, err := FromFile(func(key string) string {
+		if key == "XDG_CONFIG_HOME" {
+			return dir
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatalf("FromFile() error = %v, want nil", err)
+	}
+	if l.Backend != nil || l.Components != nil {
+		t.Errorf("FromFile() = %+v, want empty layer", l)
+	}
+
+	// A file named explicitly must exist
+	_, err = FromFile(func(key string) string {
+		if key == ConfigEnv {
+			return filepath.Join(dir, "missing.yaml")
+		}
+		return ""
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to read config file") {
+		t.Errorf("FromFile() error = %v, want read error", err)
+	}
+}
+
+func TestFromMap_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     map[string]any
+		wantErr string
+	}{
+		{name: "unknown key", raw: map[string]any{"color": true}, wantErr: `unknown option "color"`},
+		{name: "flag name instead of key", raw: map[string]any{"warnings-as-errors": true}, wantErr: `unknown option "warnings-as-errors"`},
+		{name: "non-scalar", raw: map[string]any{"overlay": []any{"a"}}, wantErr: "overlay must be a scalar"},
+		{name: "invalid value", raw: map[string]any{"debug": "often"}, wantErr: `invalid debug value "often"`},
+		{name: "timeout in seconds", raw: map[string]any{"timeout": 30}, wantErr: `invalid timeout "30"`},
+		{name: "components list", raw: map[string]any{"components": []any{"istio"}}, wantErr: "components must be a map"},
+		{name: "component value", raw: map[string]any{"components": map[string]any{"istio": "yes"}}, wantErr: "components.istio must be a boolean"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromMap(tt.raw)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("FromMap() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegisterFlags_BoolFlags(t *testing.T) {
+	l := Layer{}
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	l.RegisterFlags(flags)
+
+	if err := flags.Parse([]string{"--debug", "--warnings-as-errors=false"}); err != nil {
+		t.Fatalf("Parse() error = %v, want nil", err)
+	}
+	if l.Debug == nil || !*l.Debug {
+		t.Error("Expected --debug to enable debug output")
+	}
+	if l.WarningsAsErrors == nil || *l.WarningsAsErrors {
+		t.Error("Expected --warnings-as-errors=false to be recorded as false")
+	}
+	if l.FixDeprecated != nil {
+		t.Error("Expected unset flags to stay unset")
+	}
+}
@@ -0,0 +1,160 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Op is the kind of a Change
+type Op string
+
+// Kinds of changes
+const (
+	Added   Op = "+"
+	Removed Op = "-"
+	Changed Op = "~"
+)
+
+// Change is a field that differs between two versions of a resource
+type Change struct {
+	// Path locates the field, e.g. spec.template.spec.containers[name=web].image
+	Path   string
+	Op     Op
+	Before any
+	After  any
+}
+
+// String formats the change on one line, e.g. "~ spec.replicas: 1 -> 3"
+func (c Change) String() string {
+	switch c.Op {
+	case Added:
+		return fmt.Sprintf("+ %s: %s", c.Path, format(c.After))
+	case Removed:
+		return fmt.Sprintf("- %s: %s", c.Path, format(c.Before))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, format(c.Before), format(c.After))
+	}
+}
+
+// Fields compares two versions of a resource field by field. Elements of lists whose
+// items all have a name, such as containers, are matched by name, other lists by index.
+func Fields(before, after any) []Change {
+	var changes []Change
+	compare("", before, after, &changes)
+	return changes
+}
+
+// compare appends the changes between before and after, found at path, to changes
+func compare(path string, before, after any, changes *[]Change) {
+	switch {
+	case reflect.DeepEqual(before, after):
+		return
+	case before == nil:
+		*changes = append(*changes, Change{Path: path, Op: Added, After: after})
+		return
+	case after == nil:
+		*changes = append(*changes, Change{Path: path, Op: Removed, Before: before})
+		return
+	}
+
+	beforeMap, beforeIsMap := before.(map[string]any)
+	afterMap, afterIsMap := after.(map[string]any)
+	if beforeIsMap && afterIsMap {
+		keys := slices.Collect(maps.Keys(beforeMap))
+		for key := range afterMap {
+			if _, ok := beforeMap[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			compare(join(path, key), beforeMap[key], afterMap[key], changes)
+		}
+		return
+	}
+
+	beforeList, beforeIsList := before.([]any)
+	afterList, afterIsList := after.([]any)
+	if beforeIsList && afterIsList {
+		compareLists(path, beforeList, afterList, changes)
+		return
+	}
+
+	*changes = append(*changes, Change{Path: path, Op: Changed, Before: before, After: after})
+}
+
+// compareLists appends the changes between the elements of two lists to changes
+func compareLists(path string, before, after []any, changes *[]Change) {
+	beforeNames, beforeNamed := names(before)
+	afterNames, afterNamed := names(after)
+	if !beforeNamed || !afterNamed {
+		for i := range max(len(before), len(after)) {
+			var b, a any
+			if i < len(before) {
+				b = before[i]
+			}
+			if i < len(after) {
+				a = after[i]
+			}
+			compare(fmt.Sprintf("%s[%d]", path, i), b, a, changes)
+		}
+		return
+	}
+
+	for i, name := range beforeNames {
+		var a any
+		if j := slices.Index(afterNames, name); j >= 0 {
+			a = after[j]
+		}
+		compare(fmt.Sprintf("%s[name=%s]", path, name), before[i], a, changes)
+	}
+	for j, name := range afterNames {
+		if !slices.Contains(beforeNames, name) {
+			compare(fmt.Sprintf("%s[name=%s]", path, name), nil, after[j], changes)
+		}
+	}
+}
+
+// names returns the names of the elements of a list, and whether they all have a unique name
+func names(list []any) ([]string, bool) {
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		m, _ := item.(map[string]any)
+		name, ok := m["name"].(string)
+		if !ok || slices.Contains(result, name) {
+			return nil, false
+		}
+		result = append(result, name)
+	}
+	return result, true
+}
+
+// join appends a map key to path, quoting keys that aren't plain identifiers such as
+// app.kubernetes.io/name
+func join(path, key string) string {
+	if key == "" || strings.ContainsAny(key, `."[]/ `) {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// format formats a value of a change, nested values as compact JSON
+func format(value any) string {
+	switch value.(type) {
+	case map[string]any, []any:
+		encoded, err := json.Marshal(value)
+		if err == nil {
+			return string(encoded)
+		}
+	case string:
+		return fmt.Sprintf("%q", value)
+	}
+	return fmt.Sprint(value)
+}
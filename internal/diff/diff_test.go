@@ -0,0 +1,90 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		before any
+		after  any
+		want   []string
+	}{
+		{
+			name:   "equal",
+			before: map[string]any{"spec": map[string]any{"replicas": 1}},
+			after:  map[string]any{"spec": map[string]any{"replicas": 1}},
+			want:   nil,
+		},
+		{
+			name: "scalars and maps",
+			before: map[string]any{
+				"metadata": map[string]any{"name": "web", "annotations": map[string]any{"old": "x"}},
+				"spec":     map[string]any{"replicas": 1},
+			},
+			after: map[string]any{
+				"metadata": map[string]any{"name": "pre-web", "labels": map[string]any{"app.kubernetes.io/name": "web"}},
+				"spec":     map[string]any{"replicas": 3},
+			},
+			want: []string{
+				`- metadata.annotations: {"old":"x"}`,
+				`+ metadata.labels: {"app.kubernetes.io/name":"web"}`,
+				`~ metadata.name: "web" -> "pre-web"`,
+				`~ spec.replicas: 1 -> 3`,
+			},
+		},
+		{
+			name:   "quoted keys",
+			before: map[string]any{"metadata": map[string]any{"labels": map[string]any{"app": "web"}}},
+			after: map[string]any{"metadata": map[string]any{"labels": map[string]any{
+				"app": "web", "app.kubernetes.io/name": "web",
+			}}},
+			want: []string{`+ metadata.labels["app.kubernetes.io/name"]: "web"`},
+		},
+		{
+			name: "lists matched by name",
+			before: map[string]any{"containers": []any{
+				map[string]any{"name": "web", "image": "nginx:1.25"},
+				map[string]any{"name": "sidecar", "image": "envoy"},
+			}},
+			after: map[string]any{"containers": []any{
+				map[string]any{"name": "init", "image": "busybox"},
+				map[string]any{"name": "web", "image": "nginx:1.27"},
+			}},
+			want: []string{
+				`~ containers[name=web].image: "nginx:1.25" -> "nginx:1.27"`,
+				`- containers[name=sidecar]: {"image":"envoy","name":"sidecar"}`,
+				`+ containers[name=init]: {"image":"busybox","name":"init"}`,
+			},
+		},
+		{
+			name:   "lists matched by index",
+			before: map[string]any{"args": []any{"--a", "--b"}},
+			after:  map[string]any{"args": []any{"--a", "--c", "--d"}},
+			want: []string{
+				`~ args[1]: "--b" -> "--c"`,
+				`+ args[2]: "--d"`,
+			},
+		},
+		{
+			name:   "type change",
+			before: map[string]any{"value": "1"},
+			after:  map[string]any{"value": []any{"1"}},
+			want:   []string{`~ value: "1" -> ["1"]`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, change := range Fields(tt.before, tt.after) {
+				got = append(got, change.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Fields() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around changes in a unified diff
+const contextLines = 3
+
+// Unified returns the unified diff of two texts, labelled fromName and toName, or an
+// empty string if they're equal
+func Unified(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	a, b := splitLines(from), splitLines(to)
+	ops := lineOps(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Group the operations into hunks of changes with their context
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			start++
+			continue
+		}
+
+		first := max(start-contextLines, 0)
+		last := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				last = i
+			} else if i-last > 2*contextLines {
+				break
+			}
+		}
+		end := min(last+contextLines+1, len(ops))
+
+		fromStart, fromCount, toStart, toCount := ops[first].from, 0, ops[first].to, 0
+		for _, op := range ops[first:end] {
+			if op.kind != '+' {
+				fromCount++
+			}
+			if op.kind != '-' {
+				toCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(fromStart, fromCount), hunkRange(toStart, toCount))
+		for _, op := range ops[first:end] {
+			fmt.Fprintf(&out, "%c%s\n", op.kind, op.line)
+		}
+		start = end
+	}
+
+	return out.String()
+}
+
+// lineOp is a line of a diff: kept (' '), removed ('-') or added ('+'), with the index of
+// the next line of each text
+type lineOp struct {
+	kind     byte
+	line     string
+	from, to int
+}
+
+// maxEdits bounds the edit distance lineOps searches for. Texts differing by more lines are
+// diffed as the removal of the changed region followed by its addition, so that memory
+// stays bounded for large resources.
+const maxEdits = 1000
+
+// lineOps computes the edit script between two lists of lines. Common leading and trailing
+// lines are kept as they are, and the lines between them are compared with Myers' algorithm.
+func lineOps(a, b []string) []lineOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []lineOp
+	for i := range prefix {
+		ops = append(ops, lineOp{kind: ' ', line: a[i], from: i, to: i})
+	}
+	middle, ok := myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	if !ok {
+		middle = replace(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	}
+	for _, op := range middle {
+		op.from += prefix
+		op.to += prefix
+		ops = append(ops, op)
+	}
+	for i := range suffix {
+		from, to := len(a)-suffix+i, len(b)-suffix+i
+		ops = append(ops, lineOp{kind: ' ', line: a[from], from: from, to: to})
+	}
+	return ops
+}
+
+// myers returns the shortest edit script between a and b, or false if it needs more than
+// maxEdits removals and additions
+func myers(a, b []string) ([]lineOp, bool) {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEdits)
+	// v[offset+k] is the furthest index of a reached on diagonal k = x - y, and trace keeps
+	// its diagonals -d..d before every step d to walk the path back
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrack walks the path found by myers back from the end of a and b
+func backtrack(a, b []string, trace [][]int) []lineOp {
+	var ops []lineOp
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		// trace[d] holds diagonals -d..d
+		at := func(k int) int { return trace[d][k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, lineOp{kind: ' ', line: a[x], from: x, to: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				ops = append(ops, lineOp{kind: '+', line: b[y], from: x, to: y})
+			} else {
+				x--
+				ops = append(ops, lineOp{kind: '-', line: a[x], from: x, to: y})
+			}
+		}
+	}
+	slices.Reverse(ops)
+	return ops
+}
+
+// replace returns the edit script removing all of a, then adding all of b
+func replace(a, b []string) []lineOp {
+	ops := make([]lineOp, 0, len(a)+len(b))
+	for i, line := range a {
+		ops = append(ops, lineOp{kind: '-', line: line, from: i, to: 0})
+	}
+	for j, line := range b {
+		ops = append(ops, lineOp{kind: '+', line: line, from: len(a), to: j})
+	}
+	return ops
+}
+
+// hunkRange formats the line range of a hunk, which is 1-based except for empty ranges
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits text into lines without their line breaks
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
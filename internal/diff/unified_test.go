@@ -0,0 +1,90 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{name: "equal", from: "a\nb\n", to: "a\nb\n", want: ""},
+		{
+			name: "change in the middle",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			to:   "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			to:   "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\nthirteen\n",
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+thirteen\n",
+		},
+		{
+			name: "from empty",
+			from: "",
+			to:   "a\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1 @@\n+a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("a", "b", tt.from, tt.to); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineOps(t *testing.T) {
+	// Every edit script must turn a into b, and be minimal while within maxEdits
+	tests := []struct {
+		name     string
+		a, b     []string
+		wantDiff int
+	}{
+		{name: "interleaved", a: strings.Split("a b c a b b a", " "), b: strings.Split("c b a b a c", " "), wantDiff: 5},
+		{name: "added labels", a: lines("line", 20000), b: append([]string{"labels:", "  app: web"}, lines("line", 20000)...), wantDiff: 2},
+		{name: "over maxEdits", a: lines("a", 800), b: lines("b", 800), wantDiff: 1600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var from, to []string
+			edits := 0
+			for _, op := range lineOps(tt.a, tt.b) {
+				if op.kind != '+' {
+					from = append(from, op.line)
+				}
+				if op.kind != '-' {
+					to = append(to, op.line)
+				}
+				if op.kind != ' ' {
+					edits++
+				}
+			}
+			if !slices.Equal(from, tt.a) || !slices.Equal(to, tt.b) {
+				t.Errorf("lineOps() doesn't turn a into b")
+			}
+			if edits != tt.wantDiff {
+				t.Errorf("lineOps() has %d edits, want %d", edits, tt.wantDiff)
+			}
+		})
+	}
+}
+
+// lines returns n distinct lines starting with prefix
+func lines(prefix string, n int) []string {
+	result := make([]string, n)
+	for i := range result {
+		result[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return result
+}
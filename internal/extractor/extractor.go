@@ -10,6 +10,8 @@ import (
 type TempDir struct {
 	Path string
 	root *os.Root
+	// keep is set for directories created by NewDir, which Cleanup leaves in place
+	keep bool
 }
 
 // NewTempDir creates a new temporary directory
@@ -31,11 +33,39 @@ func NewTempDir() (*TempDir, error) {
 	return tempDir, nil
 }
 
-// Cleanup removes the temporary directory and all its contents.
-// If cleanup fails, it prints a warning to stderr but does not return an error,
-// as the OS should eventually clean up temporary files.
+// NewDir prepares the directory at path, creating it if needed, to extract files that are
+// kept once the program exits. The directory must be empty. Cleanup leaves it in place.
+func NewDir(path string) (*TempDir, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("directory %s is not empty", path)
+	}
+
+	root, err := os.OpenRoot(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open root: %w", err)
+	}
+	return &TempDir{Path: path, root: root, keep: true}, nil
+}
+
+// Cleanup closes the directory and removes it with all its contents, unless it was
+// created by NewDir. If cleanup fails, it prints a warning to stderr but does not return
+// an error, as the OS should eventually clean up temporary files.
 func (t *TempDir) Cleanup() {
-	if t.Path == "" {
+	if t.root != nil {
+		if err := t.root.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close directory %s: %v\n", t.Path, err)
+		}
+		t.root = nil
+	}
+	if t.Path == "" || t.keep {
 		return
 	}
 
@@ -55,6 +85,17 @@ func (t *TempDir) ExtractFiles(files map[string]string) error {
 	return nil
 }
 
+// ExtractBinaryFiles writes binary files from the binaryFiles map to the temporary directory
+func (t *TempDir) ExtractBinaryFiles(files map[string][]byte) error {
+	for filePath, content := range files {
+		if err := t.WriteFile(filePath, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // WriteFile writes content to a file in the temporary directory
 func (t *TempDir) WriteFile(filePath string, content []byte) error {
 	// Create directory structure if needed
@@ -71,6 +112,15 @@ func (t *TempDir) WriteFile(filePath string, content []byte) error {
 	return nil
 }
 
+// Remove deletes a file from the temporary directory
+func (t *TempDir) Remove(filePath string) error {
+	if err := t.root.Remove(filePath); err != nil {
+		return fmt.Errorf("failed to remove file %s: %w", filePath, err)
+	}
+
+	return nil
+}
+
 // ReadFile reads a file from the temporary directory
 func (t *TempDir) ReadFile(filePath string) ([]byte, error) {
 	// Read file content using root-constrained read
@@ -23,6 +23,54 @@ func TestNewTempDir(t *testing.T) {
 	}
 }
 
+func TestNewDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "extracted")
+	dir, err := NewDir(path)
+	if err != nil {
+		t.Fatalf("NewDir() error = %v, want nil", err)
+	}
+
+	if err := dir.WriteFile("base/kustomization.yaml", []byte("resources: []\n")); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	// The directory is kept, but closed
+	dir.Cleanup()
+	if _, err := os.Stat(filepath.Join(path, "base", "kustomization.yaml")); err != nil {
+		t.Errorf("Expected extracted file to be kept: %v", err)
+	}
+	if dir.root != nil {
+		t.Error("Expected Cleanup to close the root")
+	}
+
+	// A directory that isn't empty is refused
+	if _, err := NewDir(path); err == nil {
+		t.Error("NewDir() on a non-empty directory error = nil, want error")
+	}
+}
+
+func TestTempDir_Remove(t *testing.T) {
+	tempDir, err := NewTempDir()
+	if err != nil {
+		t.Fatalf("NewTempDir() error = %v", err)
+	}
+	defer tempDir.Cleanup()
+
+	if err := tempDir.WriteFile("all.yaml", []byte("kind: ConfigMap\n")); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := tempDir.Remove("all.yaml"); err != nil {
+		t.Fatalf("Remove() error = %v, want nil", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir.Path, "all.yaml")); !os.IsNotExist(err) {
+		t.Errorf("Expected all.yaml to be removed, got %v", err)
+	}
+
+	if err := tempDir.Remove("../outside.yaml"); err == nil {
+		t.Error("Remove() outside the directory error = nil, want error")
+	}
+}
+
 func TestTempDir_Cleanup(t *testing.T) {
 	tempDir, err := NewTempDir()
 	if err != nil {
@@ -103,6 +151,31 @@ func TestTempDir_ExtractFiles_InvalidPath(t *testing.T) {
 	}
 }
 
+func TestTempDir_ExtractBinaryFiles(t *testing.T) {
+	tempDir, err := NewTempDir()
+	if err != nil {
+		t.Fatalf("NewTempDir() error = %v", err)
+	}
+	defer tempDir.Cleanup()
+
+	content := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff}
+	if err := tempDir.ExtractBinaryFiles(map[string][]byte{"assets/logo.png": content}); err != nil {
+		t.Fatalf("ExtractBinaryFiles() error = %v, want nil", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(tempDir.Path, "assets", "logo.png"))
+	if err != nil {
+		t.Fatalf("Failed to read extracted file: %v", err)
+	}
+	if string(got) != string(content) {
+		t.Errorf("Extracted content = %v, want %v", got, content)
+	}
+
+	if err := tempDir.ExtractBinaryFiles(map[string][]byte{"../escape.bin": content}); err == nil {
+		t.Error("ExtractBinaryFiles() should return error for directory traversal attempt")
+	}
+}
+
 func TestTempDir_WriteFile(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,128 @@
+package extractor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+)
+
+// bom is the UTF-8 byte order mark some editors write at the start of files
+var bom = []byte("\xef\xbb\xbf")
+
+// trailingWhitespace matches the spaces and tabs at the end of a line
+var trailingWhitespace = regexp.MustCompile(`(?m)[ \t]+$`)
+
+// blockScalarHeader matches the end of a line starting a literal or folded block scalar,
+// such as "script: |" or "- >-"
+var blockScalarHeader = regexp.MustCompile(`(?:^|[:-]|^---)[ \t]+[|>][-+0-9]*[ \t]*(?:#.*)?$`)
+
+// Normalize fixes the problems that editors on other platforms introduce in YAML and JSON
+// files, which kustomize fails to parse with confusing errors: it removes the UTF-8 BOM and
+// converts CRLF line endings to LF. In YAML files, it also removes trailing whitespace when
+// that doesn't change the documents, and replaces tab indentation with two spaces per tab
+// when the file is invalid because of it, except in block scalars. Other files are returned unchanged. It returns
+// the content and the fixes applied, none if it's unchanged.
+func Normalize(name string, content []byte) ([]byte, []string) {
+	ext := strings.ToLower(path.Ext(name))
+	isYAML := ext == ".yaml" || ext == ".yml"
+	if !isYAML && ext != ".json" {
+		return content, nil
+	}
+
+	var fixes []string
+	if bytes.HasPrefix(content, bom) {
+		content = content[len(bom):]
+		fixes = append(fixes, "removed the UTF-8 BOM")
+	}
+	if bytes.Contains(content, []byte("\r\n")) {
+		content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+		fixes = append(fixes, "converted CRLF line endings to LF")
+	}
+	if !isYAML {
+		return content, fixes
+	}
+
+	docs, err := decodeYAML(content)
+	if err != nil {
+		indented := replaceTabIndentation(content)
+		if fixed, fixedErr := decodeYAML(indented); fixedErr == nil {
+			content, docs, err = indented, fixed, nil
+			fixes = append(fixes, "replaced tab indentation with spaces")
+		}
+	}
+
+	// Trailing whitespace is content in block scalars, so it's only removed when that
+	// doesn't change the documents
+	if trimmed := trailingWhitespace.ReplaceAll(content, nil); err == nil && !bytes.Equal(trimmed, content) {
+		if decoded, err := decodeYAML(trimmed); err == nil && reflect.DeepEqual(decoded, docs) {
+			content = trimmed
+			fixes = append(fixes, "removed trailing whitespace")
+		}
+	}
+
+	return content, fixes
+}
+
+// replaceTabIndentation replaces every tab of the indentation of content with two spaces,
+// except in block scalars, whose content such as Makefiles can rely on tabs
+func replaceTabIndentation(content []byte) []byte {
+	lines := bytes.Split(content, []byte("\n"))
+	// blockIndent is the indentation of the line starting the current block scalar, whose
+	// content is indented further, or -1 outside of block scalars
+	blockIndent := -1
+	for i, line := range lines {
+		trimmed := bytes.TrimLeft(line, " \t")
+		indent := bytes.ReplaceAll(line[:len(line)-len(trimmed)], []byte("\t"), []byte("  "))
+		if blockIndent >= 0 {
+			if len(trimmed) == 0 || len(indent) > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+
+		lines[i] = append(indent, trimmed...)
+		if blockScalarHeader.Match(trimmed) {
+			blockIndent = len(indent)
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// NormalizeFiles returns a copy of files with every file normalised by Normalize, and the
+// fixes applied to each file that was rewritten
+func NormalizeFiles(files map[string]string) (map[string]string, map[string][]string) {
+	normalized := maps.Clone(files)
+	fixed := map[string][]string{}
+	for name, content := range files {
+		result, fixes := Normalize(name, []byte(content))
+		if len(fixes) > 0 {
+			normalized[name] = string(result)
+			fixed[name] = fixes
+		}
+	}
+	return normalized, fixed
+}
+
+// decodeYAML decodes every document of content
+func decodeYAML(content []byte) ([]any, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	var docs []any
+	for {
+		var doc any
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+}
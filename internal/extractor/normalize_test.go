@@ -0,0 +1,115 @@
+package extractor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		content   string
+		want      string
+		wantFixes []string
+	}{
+		{
+			name:    "clean file",
+			file:    "kustomization.yaml",
+			content: "resources:\n- all.yaml\n",
+			want:    "resources:\n- all.yaml\n",
+		},
+		{
+			name:      "CRLF and BOM",
+			file:      "kustomization.yaml",
+			content:   "\xef\xbb\xbfresources:\r\n- all.yaml\r\n",
+			want:      "resources:\n- all.yaml\n",
+			wantFixes: []string{"removed the UTF-8 BOM", "converted CRLF line endings to LF"},
+		},
+		{
+			name:      "tab indentation",
+			file:      "patch.yml",
+			content:   "metadata:\n\tname: web\n\tlabels:\n\t\tapp: web\n",
+			want:      "metadata:\n  name: web\n  labels:\n    app: web\n",
+			wantFixes: []string{"replaced tab indentation with spaces"},
+		},
+		{
+			name:      "tab indentation around a block scalar",
+			file:      "configmap.yaml",
+			content:   "data:\n\tMakefile: |\n    build:\n    \tgo build\n\tname: web\n",
+			want:      "data:\n  Makefile: |\n    build:\n    \tgo build\n  name: web\n",
+			wantFixes: []string{"replaced tab indentation with spaces"},
+		},
+		{
+			name:    "tab indentation in a block scalar",
+			file:    "configmap.yaml",
+			content: "data:\n  script: |\n\t\techo ok\n",
+			want:    "data:\n  script: |\n\t\techo ok\n",
+		},
+		{
+			name:    "tabs in a valid file",
+			file:    "configmap.yaml",
+			content: "data:\n  script: |\n    if true; then\n    \techo ok\n    fi\n",
+			want:    "data:\n  script: |\n    if true; then\n    \techo ok\n    fi\n",
+		},
+		{
+			name:      "trailing whitespace",
+			file:      "kustomization.yaml",
+			content:   "namePrefix: prod- \nresources:\t\n- all.yaml\n",
+			want:      "namePrefix: prod-\nresources:\n- all.yaml\n",
+			wantFixes: []string{"removed trailing whitespace"},
+		},
+		{
+			name:    "trailing whitespace in a block scalar",
+			file:    "configmap.yaml",
+			content: "data:\n  motd: |\n    hello  \n",
+			want:    "data:\n  motd: |\n    hello  \n",
+		},
+		{
+			name:      "JSON",
+			file:      "values.json",
+			content:   "\xef\xbb\xbf{\r\n\t\"a\": 1 \r\n}",
+			want:      "{\n\t\"a\": 1 \n}",
+			wantFixes: []string{"removed the UTF-8 BOM", "converted CRLF line endings to LF"},
+		},
+		{
+			name:    "other file",
+			file:    "script.sh",
+			content: "\xef\xbb\xbfecho ok\r\n",
+			want:    "\xef\xbb\xbfecho ok\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, fixes := Normalize(tt.file, []byte(tt.content))
+			if string(got) != tt.want {
+				t.Errorf("Normalize() = %q, want %q", got, tt.want)
+			}
+			if !slices.Equal(fixes, tt.wantFixes) {
+				t.Errorf("Normalize() fixes = %v, want %v", fixes, tt.wantFixes)
+			}
+		})
+	}
+}
+
+func TestNormalizeFiles(t *testing.T) {
+	files := map[string]string{
+		"kustomization.yaml": "resources:\r\n- all.yaml\r\n",
+		"patch.yaml":         "kind: Deployment\n",
+	}
+
+	normalized, fixed := NormalizeFiles(files)
+	if got := normalized["kustomization.yaml"]; got != "resources:\n- all.yaml\n" {
+		t.Errorf("kustomization.yaml = %q", got)
+	}
+	if got := normalized["patch.yaml"]; got != "kind: Deployment\n" {
+		t.Errorf("patch.yaml = %q", got)
+	}
+	if len(fixed) != 1 || fixed["kustomization.yaml"] == nil {
+		t.Errorf("fixed = %v, want kustomization.yaml only", fixed)
+	}
+	if files["kustomization.yaml"] != "resources:\r\n- all.yaml\r\n" {
+		t.Error("NormalizeFiles() modified its argument")
+	}
+}
@@ -0,0 +1,77 @@
+package extractor
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// reference matches an escaped $${, or a ${VAR} reference with an optional :-default or
+// :?error modifier
+var reference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:-|:\?)([^}]*))?\}`)
+
+// Substitute replaces the ${VAR} references to the allowed variables in content with their
+// values from lookup. ${VAR:-default} uses default when VAR is unset or empty, and
+// ${VAR:?message} fails with message in that case. A plain ${VAR} must be set. References
+// to other variables are left as they are, and $${ is written as ${.
+func Substitute(content string, allowed []string, lookup func(string) (string, bool)) (string, error) {
+	var sb strings.Builder
+	last := 0
+	for _, match := range reference.FindAllStringSubmatchIndex(content, -1) {
+		start, end := match[0], match[1]
+		if content[start:end] == "$${" {
+			sb.WriteString(content[last:start])
+			sb.WriteString("${")
+			last = end
+			continue
+		}
+
+		name := content[match[2]:match[3]]
+		if !slices.Contains(allowed, name) {
+			continue
+		}
+		value, set := lookup(name)
+		if match[4] >= 0 {
+			modifier, argument := content[match[4]:match[5]], content[match[6]:match[7]]
+			switch {
+			case value != "":
+			case modifier == ":-":
+				value, set = argument, true
+			default:
+				if argument == "" {
+					argument = "not set"
+				}
+				return "", fmt.Errorf("line %d: %s: %s", line(content, start), name, argument)
+			}
+		}
+		if !set {
+			return "", fmt.Errorf("line %d: ${%s} is not set", line(content, start), name)
+		}
+
+		sb.WriteString(content[last:start])
+		sb.WriteString(value)
+		last = end
+	}
+	sb.WriteString(content[last:])
+	return sb.String(), nil
+}
+
+// SubstituteFiles returns a copy of files with the variables substituted by Substitute
+func SubstituteFiles(files map[string]string, allowed []string, lookup func(string) (string, bool)) (map[string]string, error) {
+	substituted := make(map[string]string, len(files))
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		content, err := Substitute(files[name], allowed, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("failed to substitute variables in %s: %w", name, err)
+		}
+		substituted[name] = content
+	}
+	return substituted, nil
+}
+
+// line returns the line number of offset in content
+func line(content string, offset int) int {
+	return strings.Count(content[:offset], "\n") + 1
+}
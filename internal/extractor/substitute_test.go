@@ -0,0 +1,61 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubstitute(t *testing.T) {
+	env := map[string]string{"REGISTRY": "mirror.example.com", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	allowed := []string{"REGISTRY", "CLUSTER", "EMPTY"}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{name: "set", content: "image: ${REGISTRY}/nginx", want: "image: mirror.example.com/nginx"},
+		{name: "set to empty", content: "suffix: '${EMPTY}'", want: "suffix: ''"},
+		{name: "default", content: "cluster: ${CLUSTER:-dev}", want: "cluster: dev"},
+		{name: "default for empty", content: "suffix: ${EMPTY:-none}", want: "suffix: none"},
+		{name: "default unused", content: "image: ${REGISTRY:-docker.io}", want: "image: mirror.example.com"},
+		{name: "error unused", content: "image: ${REGISTRY:?set a registry}", want: "image: mirror.example.com"},
+		{name: "not allowed", content: "home: ${HOME} $HOME", want: "home: ${HOME} $HOME"},
+		{name: "escaped", content: "echo $${REGISTRY}", want: "echo ${REGISTRY}"},
+		{name: "unset", content: "a: 1\ncluster: ${CLUSTER}", wantErr: "line 2: ${CLUSTER} is not set"},
+		{name: "error", content: "cluster: ${CLUSTER:?set the cluster name}", wantErr: "line 1: CLUSTER: set the cluster name"},
+		{name: "error for empty", content: "suffix: ${EMPTY:?}", wantErr: "line 1: EMPTY: not set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Substitute(tt.content, allowed, lookup)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Substitute() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Substitute() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Substitute() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubstituteFiles(t *testing.T) {
+	lookup := func(string) (string, bool) { return "", false }
+
+	_, err := SubstituteFiles(map[string]string{"patch.yaml": "name: ${NAME}"}, []string{"NAME"}, lookup)
+	if err == nil || err.Error() != "failed to substitute variables in patch.yaml: line 1: ${NAME} is not set" {
+		t.Errorf("SubstituteFiles() error = %v", err)
+	}
+}
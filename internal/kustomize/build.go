@@ -0,0 +1,104 @@
+package kustomize
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// Backend is the kustomize implementation used to build a kustomization
+type Backend string
+
+const (
+	// Kubectl runs the kustomize version bundled with kubectl
+	Kubectl Backend = "kubectl"
+	// Kustomize runs the standalone kustomize binary
+	Kustomize Backend = "kustomize"
+	// Builtin builds in-process with the kustomize library, without any external binary
+	Builtin Backend = "builtin"
+)
+
+// Backends lists the supported backends
+var Backends = []Backend{Kubectl, Kustomize, Builtin}
+
+// BuildOptions configures BuildWith
+type BuildOptions struct {
+	// Backend defaults to Kubectl
+	Backend Backend
+	// Timeout limits the duration of the build; zero means no limit
+	Timeout time.Duration
+}
+
+// BuildWith builds the kustomization in dir with the configured backend. It returns the
+// built manifests and, separately, the warnings kustomize printed to stderr.
+func BuildWith(dir string, opts BuildOptions) ([]byte, []byte, error) {
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	switch opts.Backend {
+	case "", Kubectl:
+		return buildCommand(ctx, opts.Timeout, "kubectl", "kustomize", dir)
+	case Kustomize:
+		return buildCommand(ctx, opts.Timeout, "kustomize", "build", dir)
+	case Builtin:
+		output, err := buildBuiltin(ctx, opts.Timeout, dir)
+		return output, nil, err
+	default:
+		return nil, nil, fmt.Errorf("unknown kustomize backend %q", opts.Backend)
+	}
+}
+
+// buildCommand runs an external kustomize command and separates its output from its warnings
+func buildCommand(ctx context.Context, timeout time.Duration, name string, args ...string) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, nil, fmt.Errorf("%s %s timed out after %s", name, args[0], timeout)
+		}
+		return nil, nil, fmt.Errorf("%s %s failed: %w\nOutput: %s", name, args[0], err, stderr.String())
+	}
+	return stdout.Bytes(), stderr.Bytes(), nil
+}
+
+// buildBuiltin builds with the kustomize library, using the same defaults as kubectl kustomize
+func buildBuiltin(ctx context.Context, timeout time.Duration, dir string) ([]byte, error) {
+	type result struct {
+		output []byte
+		err    error
+	}
+	done := make(chan result, 1)
+
+	// krusty can't be cancelled, so on timeout the build is abandoned rather than stopped
+	go func() {
+		resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(filesys.MakeFsOnDisk(), dir)
+		if err != nil {
+			done <- result{err: fmt.Errorf("kustomize build failed: %w", err)}
+			return
+		}
+		output, err := resources.AsYaml()
+		if err != nil {
+			err = fmt.Errorf("failed to marshal kustomize output: %w", err)
+		}
+		done <- result{output: output, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.output, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("kustomize build timed out after %s", timeout)
+	}
+}
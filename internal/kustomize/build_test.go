@@ -0,0 +1,111 @@
+package kustomize
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeBuildFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"kustomization.yaml": "resources:\n- cm.yaml\nnamePrefix: app-\n",
+		"cm.yaml":            "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestBuildWith_Backends(t *testing.T) {
+	dir := writeBuildFixture(t)
+	expected := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app-config\n"
+
+	for _, backend := range Backends {
+		t.Run(string(backend), func(t *testing.T) {
+			if backend != Builtin {
+				if _, err := exec.LookPath(string(backend)); err != nil {
+					t.Skipf("%s not found in PATH", backend)
+				}
+			}
+
+			output, _, err := BuildWith(dir, BuildOptions{Backend: backend})
+			if err != nil {
+				t.Fatalf("BuildWith() error = %v, want nil", err)
+			}
+			if string(output) != expected {
+				t.Errorf("BuildWith() output = %q, want %q", output, expected)
+			}
+		})
+	}
+}
+
+func TestBuildWith_BuiltinError(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources:\n- missing.yaml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := BuildWith(dir, BuildOptions{Backend: Builtin})
+	if err == nil || !strings.Contains(err.Error(), "kustomize build failed") {
+		t.Errorf("BuildWith() error = %v, want kustomize build failed", err)
+	}
+}
+
+func TestBuildWith_UnknownBackend(t *testing.T) {
+	_, _, err := BuildWith(t.TempDir(), BuildOptions{Backend: "helm"})
+	if err == nil || !strings.Contains(err.Error(), `unknown kustomize backend "helm"`) {
+		t.Errorf("BuildWith() error = %v, want unknown backend", err)
+	}
+}
+
+func TestBuildWith_Timeout(t *testing.T) {
+	// Replace kubectl with a script that never finishes in time
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not found in PATH")
+	}
+	bin := t.TempDir()
+	script := "#!/bin/sh\nexec " + sleep + " 5\n"
+	if err := os.WriteFile(filepath.Join(bin, "kubectl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	start := time.Now()
+	_, _, err = BuildWith(t.TempDir(), BuildOptions{Timeout: 100 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "kubectl kustomize timed out after 100ms") {
+		t.Errorf("BuildWith() error = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("BuildWith() took %s, want it to stop at the timeout", elapsed)
+	}
+}
+
+func TestBuildWith_Warnings(t *testing.T) {
+	// kustomize warnings on stderr are returned separately from the manifests
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho '# Warning: something is deprecated' >&2\necho 'kind: ConfigMap'\n"
+	if err := os.WriteFile(filepath.Join(bin, "kubectl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	output, warnings, err := BuildWith(t.TempDir(), BuildOptions{Backend: Kubectl})
+	if err != nil {
+		t.Fatalf("BuildWith() error = %v, want nil", err)
+	}
+	if string(output) != "kind: ConfigMap\n" {
+		t.Errorf("BuildWith() output = %q, want only stdout", output)
+	}
+	if string(warnings) != "# Warning: something is deprecated\n" {
+		t.Errorf("BuildWith() warnings = %q, want stderr", warnings)
+	}
+}
@@ -0,0 +1,154 @@
+package kustomize
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// deprecatedFields lists the deprecated kustomization fields in the order they are reported
+var deprecatedFields = []string{"bases", "patchesStrategicMerge", "patchesJson6902", "commonLabels", "vars"}
+
+// IsKustomizationFile reports whether filePath names a kustomization file
+func IsKustomizationFile(filePath string) bool {
+	switch path.Base(filePath) {
+	case "kustomization.yaml", "kustomization.yml", "Kustomization":
+		return true
+	}
+	return false
+}
+
+// DeprecatedFields returns the deprecated fields used by the kustomization
+func (k *Kustomization) DeprecatedFields() []string {
+	var found []string
+	for _, field := range deprecatedFields {
+		if _, ok := k.RawContent[field]; ok {
+			found = append(found, field)
+		}
+	}
+	return found
+}
+
+// FixDeprecatedFields rewrites deprecated fields to their modern forms, as `kustomize edit fix` does:
+//   - bases are appended to resources
+//   - patchesStrategicMerge and patchesJson6902 entries become patches entries, keeping the
+//     order in which kustomize applied them
+//   - commonLabels become a labels entry that includes selectors
+//
+// It returns the fields that were migrated. vars are left untouched, since migrating them to
+// replacements requires knowing where each variable is referenced.
+func (k *Kustomization) FixDeprecatedFields() ([]string, error) {
+	var fixed []string
+
+	if _, ok := k.RawContent["bases"]; ok {
+		bases, err := parseStringList(k.RawContent, "bases")
+		if err != nil {
+			return nil, err
+		}
+		for _, base := range bases {
+			k.AddResource(base)
+		}
+		delete(k.RawContent, "bases")
+		fixed = append(fixed, "bases")
+	}
+
+	smp, err := strategicMergePatches(k.RawContent)
+	if err != nil {
+		return nil, err
+	}
+	json6902, err := json6902Patches(k.RawContent)
+	if err != nil {
+		return nil, err
+	}
+	if smp != nil || json6902 != nil {
+		existing, ok := k.RawContent["patches"].([]any)
+		if _, present := k.RawContent["patches"]; present && !ok {
+			return nil, fmt.Errorf("patches field must be an array")
+		}
+
+		// kustomize applied strategic merge patches before patches and JSON 6902 patches after them
+		patches := slices.Concat(smp, existing, json6902)
+		k.RawContent["patches"] = patches
+		if smp != nil {
+			delete(k.RawContent, "patchesStrategicMerge")
+			fixed = append(fixed, "patchesStrategicMerge")
+		}
+		if json6902 != nil {
+			delete(k.RawContent, "patchesJson6902")
+			fixed = append(fixed, "patchesJson6902")
+		}
+	}
+
+	if raw, ok := k.RawContent["commonLabels"]; ok {
+		pairs, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("commonLabels field must be a map")
+		}
+		labels, ok := k.RawContent["labels"].([]any)
+		if _, present := k.RawContent["labels"]; present && !ok {
+			return nil, fmt.Errorf("labels field must be an array")
+		}
+		k.RawContent["labels"] = append(labels, map[string]any{
+			"pairs":            pairs,
+			"includeSelectors": true,
+		})
+		delete(k.RawContent, "commonLabels")
+		fixed = append(fixed, "commonLabels")
+	}
+
+	return fixed, nil
+}
+
+// strategicMergePatches converts patchesStrategicMerge entries to patches entries.
+// Entries spanning multiple lines are inline patches, anything else is a file path.
+func strategicMergePatches(raw map[string]any) ([]any, error) {
+	if _, ok := raw["patchesStrategicMerge"]; !ok {
+		return nil, nil
+	}
+
+	entries, err := parseStringList(raw, "patchesStrategicMerge")
+	if err != nil {
+		return nil, err
+	}
+
+	patches := make([]any, 0, len(entries))
+	for _, entry := range entries {
+		if strings.Contains(strings.TrimSpace(entry), "\n") {
+			patches = append(patches, map[string]any{"patch": entry})
+		} else {
+			patches = append(patches, map[string]any{"path": entry})
+		}
+	}
+	return patches, nil
+}
+
+// json6902Patches converts patchesJson6902 entries to patches entries
+func json6902Patches(raw map[string]any) ([]any, error) {
+	entriesRaw, ok := raw["patchesJson6902"]
+	if !ok {
+		return nil, nil
+	}
+
+	entries, ok := entriesRaw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("patchesJson6902 field must be an array")
+	}
+
+	patches := make([]any, 0, len(entries))
+	for i, e := range entries {
+		entry, ok := e.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("patchesJson6902[%d] must be a map, got %T", i, e)
+		}
+
+		patch := map[string]any{}
+		for _, field := range []string{"path", "patch", "target"} {
+			if v, ok := entry[field]; ok {
+				patch[field] = v
+			}
+		}
+		patches = append(patches, patch)
+	}
+	return patches, nil
+}
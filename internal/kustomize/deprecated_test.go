@@ -0,0 +1,167 @@
+package kustomize
+
+import (
+	"slices"
+	"testing"
+
+	"go.yaml.in/yaml/v4"
+)
+
+func TestIsKustomizationFile(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"kustomization.yaml", true},
+		{"overlays/prod/kustomization.yml", true},
+		{"components/pdb/Kustomization", true},
+		{"patches/kustomization-patch.yaml", false},
+		{"all.yaml", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsKustomizationFile(tt.path); got != tt.want {
+			t.Errorf("IsKustomizationFile(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestKustomization_DeprecatedFields(t *testing.T) {
+	k, err := ParseKustomization([]byte(`vars: []
+commonLabels:
+  app: myapp
+bases:
+- ../base
+resources:
+- all.yaml
+`))
+	if err != nil {
+		t.Fatalf("ParseKustomization() error = %v", err)
+	}
+
+	want := []string{"bases", "commonLabels", "vars"}
+	if got := k.DeprecatedFields(); !slices.Equal(got, want) {
+		t.Errorf("DeprecatedFields() = %v, want %v", got, want)
+	}
+}
+
+func TestKustomization_FixDeprecatedFields(t *testing.T) {
+	input := `resources:
+- all.yaml
+bases:
+- ../base
+patchesStrategicMerge:
+- patch.yaml
+- |-
+  apiVersion: v1
+  kind: Service
+  metadata:
+    name: svc
+patches:
+- path: existing.yaml
+patchesJson6902:
+- path: json-patch.yaml
+  target:
+    kind: Deployment
+    name: app
+commonLabels:
+  app: myapp
+labels:
+- pairs:
+    team: platform
+vars:
+- name: SERVICE
+`
+
+	want := `resources:
+- all.yaml
+- ../base
+patches:
+- path: patch.yaml
+- patch: |-
+    apiVersion: v1
+    kind: Service
+    metadata:
+      name: svc
+- path: existing.yaml
+- path: json-patch.yaml
+  target:
+    kind: Deployment
+    name: app
+labels:
+- pairs:
+    team: platform
+- includeSelectors: true
+  pairs:
+    app: myapp
+vars:
+- name: SERVICE
+`
+
+	k, err := ParseKustomization([]byte(input))
+	if err != nil {
+		t.Fatalf("ParseKustomization() error = %v", err)
+	}
+
+	fixed, err := k.FixDeprecatedFields()
+	if err != nil {
+		t.Fatalf("FixDeprecatedFields() error = %v, want nil", err)
+	}
+
+	wantFixed := []string{"bases", "patchesStrategicMerge", "patchesJson6902", "commonLabels"}
+	if !slices.Equal(fixed, wantFixed) {
+		t.Errorf("FixDeprecatedFields() fixed = %v, want %v", fixed, wantFixed)
+	}
+
+	if remaining := k.DeprecatedFields(); !slices.Equal(remaining, []string{"vars"}) {
+		t.Errorf("DeprecatedFields() after fix = %v, want [vars]", remaining)
+	}
+
+	data, err := k.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var got, expected map[string]any
+	if err := yaml.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Failed to unmarshal output: %v", err)
+	}
+	if err := yaml.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatalf("Failed to unmarshal expected: %v", err)
+	}
+
+	gotYAML, _ := yaml.Marshal(got)
+	expectedYAML, _ := yaml.Marshal(expected)
+
+	if string(gotYAML) != string(expectedYAML) {
+		t.Errorf("FixDeprecatedFields() output =\n%s\nwant =\n%s", string(gotYAML), string(expectedYAML))
+	}
+}
+
+func TestKustomization_FixDeprecatedFields_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "bases not an array", input: `bases: ../base`},
+		{name: "patchesStrategicMerge not an array", input: `patchesStrategicMerge: patch.yaml`},
+		{name: "patchesJson6902 not an array", input: `patchesJson6902: patch.yaml`},
+		{name: "patchesJson6902 entry not a map", input: "patchesJson6902:\n- patch.yaml"},
+		{name: "patches not an array", input: "patches: patch.yaml\npatchesStrategicMerge:\n- patch.yaml"},
+		{name: "commonLabels not a map", input: `commonLabels: app`},
+		{name: "labels not an array", input: "labels: app\ncommonLabels:\n  app: myapp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := ParseKustomization([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("ParseKustomization() error = %v", err)
+			}
+
+			if _, err := k.FixDeprecatedFields(); err == nil {
+				t.Error("FixDeprecatedFields() should return error")
+			}
+		})
+	}
+}
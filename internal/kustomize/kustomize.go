@@ -3,7 +3,6 @@ package kustomize
 import (
 	"bytes"
 	"fmt"
-	"os/exec"
 	"slices"
 
 	"go.yaml.in/yaml/v4"
@@ -11,8 +10,9 @@ import (
 
 // Kustomization represents a kustomization.yaml file structure
 type Kustomization struct {
-	Resources []string `yaml:"resources,omitempty"`
-	// We only care about the resources field for now
+	Resources  []string `yaml:"resources,omitempty"`
+	Components []string `yaml:"components,omitempty"`
+	// We only care about the resources and components fields for now
 	// Other fields are preserved as-is using RawContent
 	RawContent map[string]any
 }
@@ -24,27 +24,54 @@ func ParseKustomization(data []byte) (*Kustomization, error) {
 		return nil, fmt.Errorf("failed to parse kustomization.yaml: %w", err)
 	}
 
+	if raw == nil {
+		raw = map[string]any{}
+	}
+
 	k := &Kustomization{
 		RawContent: raw,
 	}
 
 	// Extract resources if present
-	if resourcesRaw, ok := raw["resources"]; ok {
-		resourcesList, ok := resourcesRaw.([]any)
+	resources, err := parseStringList(raw, "resources")
+	if err != nil {
+		return nil, err
+	}
+	k.Resources = resources
+
+	// Extract components if present
+	components, err := parseStringList(raw, "components")
+	if err != nil {
+		return nil, err
+	}
+	k.Components = components
+
+	return k, nil
+}
+
+// parseStringList extracts a list of strings stored under field in raw.
+// Returns nil if the field is not present.
+func parseStringList(raw map[string]any, field string) ([]string, error) {
+	listRaw, ok := raw[field]
+	if !ok {
+		return nil, nil
+	}
+
+	list, ok := listRaw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s field must be an array", field)
+	}
+
+	values := make([]string, 0, len(list))
+	for i, item := range list {
+		s, ok := item.(string)
 		if !ok {
-			return nil, fmt.Errorf("resources field must be an array")
-		}
-		k.Resources = make([]string, 0, len(resourcesList))
-		for i, r := range resourcesList {
-			s, ok := r.(string)
-			if !ok {
-				return nil, fmt.Errorf("resources[%d] must be a string, got %T", i, r)
-			}
-			k.Resources = append(k.Resources, s)
+			return nil, fmt.Errorf("%s[%d] must be a string, got %T", field, i, item)
 		}
+		values = append(values, s)
 	}
 
-	return k, nil
+	return values, nil
 }
 
 // AddResource adds a resource to the kustomization if not already present
@@ -58,6 +85,43 @@ func (k *Kustomization) AddResource(resource string) bool {
 	return true
 }
 
+// ReplaceResource replaces the resource old with replacements, in place, if present
+func (k *Kustomization) ReplaceResource(old string, replacements ...string) bool {
+	i := slices.Index(k.Resources, old)
+	if i < 0 {
+		return false
+	}
+
+	k.Resources = slices.Replace(k.Resources, i, i+1, replacements...)
+	k.RawContent["resources"] = k.Resources
+	return true
+}
+
+// AddComponent adds a component to the kustomization if not already present
+func (k *Kustomization) AddComponent(component string) bool {
+	if slices.Contains(k.Components, component) {
+		return false // Already present
+	}
+
+	k.Components = append(k.Components, component)
+	k.RawContent["components"] = k.Components
+	return true
+}
+
+// AddPatch adds the patch file at path, applied to the resources selected by target, if
+// not already present
+func (k *Kustomization) AddPatch(path string, target Selector) bool {
+	patches, _ := k.RawContent["patches"].([]any)
+	for _, patch := range mapItems(patches) {
+		if patch["path"] == path {
+			return false
+		}
+	}
+
+	k.RawContent["patches"] = append(patches, map[string]any{"path": path, "target": target.raw()})
+	return true
+}
+
 // Marshal converts the kustomization back to YAML
 func (k *Kustomization) Marshal() ([]byte, error) {
 	var buf bytes.Buffer
@@ -92,13 +156,3 @@ func EnsureAllYamlInKustomization(kustomizationContent []byte) (updated []byte,
 
 	return updated, changed, nil
 }
-
-// Build runs kubectl kustomize on the given directory and returns the output
-func Build(dir string) ([]byte, error) {
-	cmd := exec.Command("kubectl", "kustomize", dir)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("kubectl kustomize failed: %w\nOutput: %s", err, string(output))
-	}
-	return output, nil
-}
@@ -171,6 +171,50 @@ func TestKustomization_AddResource(t *testing.T) {
 	}
 }
 
+func TestKustomization_ReplaceResource(t *testing.T) {
+	k := &Kustomization{Resources: []string{"base", "all.yaml", "extra.yaml"}, RawContent: map[string]any{}}
+
+	if !k.ReplaceResource("all.yaml", "helm/a.yaml", "helm/b.yaml") {
+		t.Fatal("ReplaceResource() = false, want true")
+	}
+	want := "base helm/a.yaml helm/b.yaml extra.yaml"
+	if got := strings.Join(k.Resources, " "); got != want {
+		t.Errorf("Resources = %s, want %s", got, want)
+	}
+	if k.ReplaceResource("missing.yaml", "other.yaml") {
+		t.Error("ReplaceResource() = true for a missing resource, want false")
+	}
+}
+
+func TestKustomization_AddPatch(t *testing.T) {
+	k, err := ParseKustomization([]byte("patches:\n- path: existing.yaml\n"))
+	if err != nil {
+		t.Fatalf("ParseKustomization() error = %v", err)
+	}
+
+	if !k.AddPatch("patches/deployment-web.yaml", Selector{Kind: "Deployment", Name: "web"}) {
+		t.Fatal("AddPatch() = false, want true")
+	}
+	if k.AddPatch("existing.yaml", Selector{Kind: "Service"}) {
+		t.Error("AddPatch() = true for an existing patch, want false")
+	}
+
+	data, err := k.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `patches:
+  - path: existing.yaml
+  - path: patches/deployment-web.yaml
+    target:
+      kind: Deployment
+      name: web
+`
+	if string(data) != want {
+		t.Errorf("Marshal() =\n%s\nwant\n%s", data, want)
+	}
+}
+
 func TestKustomization_Marshal(t *testing.T) {
 	k := &Kustomization{
 		Resources: []string{"all.yaml", "base.yaml"},
@@ -339,19 +383,19 @@ func TestEnsureAllYamlInKustomization_ParseError(t *testing.T) {
 	}
 }
 
-func TestBuild_Error(t *testing.T) {
-	// Test Build with an invalid/non-existent directory
-	_, err := Build("/nonexistent/directory/that/does/not/exist")
+func TestBuildWith_Error(t *testing.T) {
+	// Test BuildWith with an invalid/non-existent directory
+	_, _, err := BuildWith("/nonexistent/directory/that/does/not/exist", BuildOptions{})
 	if err == nil {
-		t.Fatal("Build() should return error for non-existent directory")
+		t.Fatal("BuildWith() should return error for non-existent directory")
 	}
 	if !strings.Contains(err.Error(), "kubectl kustomize failed") {
 		t.Errorf("Error should mention kubectl kustomize failed, got: %v", err)
 	}
 }
 
-func TestBuild_InvalidKustomizationYaml(t *testing.T) {
-	// Test Build with an invalid kustomization.yaml file
+func TestBuildWith_InvalidKustomizationYaml(t *testing.T) {
+	// Test BuildWith with an invalid kustomization.yaml file
 	// This tests the kubectl kustomize execution failure path
 	tempDir := t.TempDir()
 
@@ -366,11 +410,65 @@ resources:
 		t.Fatalf("Failed to write kustomization.yaml: %v", err)
 	}
 
-	_, err := Build(tempDir)
+	_, _, err := BuildWith(tempDir, BuildOptions{})
 	if err == nil {
-		t.Fatal("Build() should return error for invalid kustomization")
+		t.Fatal("BuildWith() should return error for invalid kustomization")
 	}
 	if !strings.Contains(err.Error(), "kubectl kustomize failed") {
 		t.Errorf("Error should mention kubectl kustomize failed, got: %v", err)
 	}
 }
+
+func TestParseKustomization_Components(t *testing.T) {
+	k, err := ParseKustomization([]byte(`resources:
+- all.yaml
+components:
+- components/istio
+`))
+	if err != nil {
+		t.Fatalf("ParseKustomization() error = %v, want nil", err)
+	}
+
+	if !slices.Equal(k.Components, []string{"components/istio"}) {
+		t.Errorf("ParseKustomization() components = %v, want [components/istio]", k.Components)
+	}
+}
+
+func TestParseKustomization_ComponentsNotArray(t *testing.T) {
+	_, err := ParseKustomization([]byte(`components: components/istio`))
+	if err == nil {
+		t.Fatal("ParseKustomization() should return error when components is not an array")
+	}
+	if !strings.Contains(err.Error(), "components field must be an array") {
+		t.Errorf("Error should mention components field must be an array, got: %v", err)
+	}
+}
+
+func TestKustomization_AddComponent(t *testing.T) {
+	k, err := ParseKustomization([]byte(`components:
+- components/pdb
+`))
+	if err != nil {
+		t.Fatalf("ParseKustomization() error = %v", err)
+	}
+
+	if changed := k.AddComponent("components/pdb"); changed {
+		t.Error("AddComponent() changed = true for an existing component, want false")
+	}
+	if changed := k.AddComponent("components/istio"); !changed {
+		t.Error("AddComponent() changed = false for a new component, want true")
+	}
+
+	data, err := k.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	updated, err := ParseKustomization(data)
+	if err != nil {
+		t.Fatalf("ParseKustomization() error = %v", err)
+	}
+	if !slices.Equal(updated.Components, []string{"components/pdb", "components/istio"}) {
+		t.Errorf("components after AddComponent() = %v, want [components/pdb components/istio]", updated.Components)
+	}
+}
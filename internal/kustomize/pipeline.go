@@ -0,0 +1,211 @@
+package kustomize
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+)
+
+// transformerFields lists the kustomization fields configuring builtin transformers, in
+// the order kustomize applies them. Fields with perEntry set configure one transformer
+// per entry.
+var transformerFields = []struct {
+	field       string
+	transformer string
+	perEntry    bool
+}{
+	{field: "patchesStrategicMerge", transformer: "PatchStrategicMergeTransformer"},
+	{field: "patches", transformer: "PatchTransformer", perEntry: true},
+	{field: "namespace", transformer: "NamespaceTransformer"},
+	{field: "namePrefix", transformer: "PrefixTransformer"},
+	{field: "nameSuffix", transformer: "SuffixTransformer"},
+	{field: "labels", transformer: "LabelTransformer", perEntry: true},
+	{field: "commonLabels", transformer: "LabelTransformer"},
+	{field: "commonAnnotations", transformer: "AnnotationsTransformer"},
+	{field: "patchesJson6902", transformer: "PatchJson6902Transformer", perEntry: true},
+	{field: "replicas", transformer: "ReplicaCountTransformer", perEntry: true},
+	{field: "images", transformer: "ImageTagTransformer", perEntry: true},
+	{field: "replacements", transformer: "ReplacementTransformer"},
+	// Transformer plugins run after the builtin ones
+	{field: "transformers", perEntry: true},
+}
+
+// kustomizationFileNames are the names kustomize looks for in a directory, in order
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// Step is a transformation configured by a kustomization file
+type Step struct {
+	// File is the kustomization file configuring the transformation
+	File string
+	// Field is the kustomization field configuring it
+	Field string
+	// Index is the entry of Field configuring it, -1 if the whole field does
+	Index int
+	// Transformer is the kind of builtin transformer, empty for transformer plugins
+	Transformer string
+}
+
+// String formats the step, e.g. kustomization.yaml: patches[0] (PatchTransformer)
+func (s Step) String() string {
+	field := s.Field
+	if s.Index >= 0 {
+		field = fmt.Sprintf("%s[%d]", s.Field, s.Index)
+	}
+	if s.Transformer == "" {
+		return fmt.Sprintf("%s: %s", s.File, field)
+	}
+	return fmt.Sprintf("%s: %s (%s)", s.File, field, s.Transformer)
+}
+
+// Pipeline describes the build of a kustomization
+type Pipeline struct {
+	// Steps lists the transformations in the order kustomize applies them: the ones of a
+	// kustomization's resources and components come before its own
+	Steps []Step
+	// Kustomizations holds the kustomization files used by the build, by path
+	Kustomizations map[string]*Kustomization
+	// ResourceFiles lists the resource files the build loads
+	ResourceFiles []string
+}
+
+// NewPipeline follows the kustomization in dir through its resources and components.
+// read returns the content of a file by slash-separated path, and whether it exists.
+// Remote resources are skipped.
+func NewPipeline(dir string, read func(name string) ([]byte, bool)) (*Pipeline, error) {
+	p := &Pipeline{Kustomizations: map[string]*Kustomization{}}
+	visited := map[string]bool{}
+
+	var visit func(dir string) error
+	visit = func(dir string) error {
+		if visited[dir] {
+			return nil
+		}
+		visited[dir] = true
+
+		name, content := "", []byte(nil)
+		for _, candidate := range kustomizationFileNames {
+			if c, ok := read(path.Join(dir, candidate)); ok {
+				name, content = path.Join(dir, candidate), c
+				break
+			}
+		}
+		if name == "" {
+			return fmt.Errorf("no kustomization file found in %s", dir)
+		}
+
+		k, err := ParseKustomization(content)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		p.Kustomizations[name] = k
+
+		for _, ref := range k.Resources {
+			if IsRemote(ref) {
+				continue
+			}
+			target := path.Join(dir, ref)
+			if _, ok := read(target); ok {
+				if !slices.Contains(p.ResourceFiles, target) {
+					p.ResourceFiles = append(p.ResourceFiles, target)
+				}
+				continue
+			}
+			if err := visit(target); err != nil {
+				return err
+			}
+		}
+		for _, ref := range k.Components {
+			if IsRemote(ref) {
+				continue
+			}
+			if err := visit(path.Join(dir, ref)); err != nil {
+				return err
+			}
+		}
+
+		p.Steps = append(p.Steps, k.steps(name)...)
+		return nil
+	}
+
+	if err := visit(dir); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// steps returns the transformations configured by the kustomization file name
+func (k *Kustomization) steps(name string) []Step {
+	var steps []Step
+	for _, f := range transformerFields {
+		value, ok := k.RawContent[f.field]
+		if !ok {
+			continue
+		}
+		if !f.perEntry {
+			steps = append(steps, Step{File: name, Field: f.field, Index: -1, Transformer: f.transformer})
+			continue
+		}
+		list, _ := value.([]any)
+		for i := range list {
+			steps = append(steps, Step{File: name, Field: f.field, Index: i, Transformer: f.transformer})
+		}
+	}
+	return steps
+}
+
+// Truncate returns the content of every kustomization file of the pipeline, changed to
+// apply only the first n steps, by path
+func (p *Pipeline) Truncate(n int) (map[string][]byte, error) {
+	removed := map[string]map[string][]int{}
+	for _, step := range p.Steps[n:] {
+		if removed[step.File] == nil {
+			removed[step.File] = map[string][]int{}
+		}
+		removed[step.File][step.Field] = append(removed[step.File][step.Field], step.Index)
+	}
+
+	files := map[string][]byte{}
+	for name, k := range p.Kustomizations {
+		raw := maps.Clone(k.RawContent)
+		for field, indexes := range removed[name] {
+			var kept []any
+			if !slices.Contains(indexes, -1) {
+				list, _ := raw[field].([]any)
+				for i, entry := range list {
+					if !slices.Contains(indexes, i) {
+						kept = append(kept, entry)
+					}
+				}
+			}
+			if len(kept) == 0 {
+				delete(raw, field)
+			} else {
+				raw[field] = kept
+			}
+		}
+
+		content, err := (&Kustomization{RawContent: raw}).Marshal()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		files[name] = content
+	}
+	return files, nil
+}
+
+// AddBuildMetadata adds options to the buildMetadata field if not already present
+func (k *Kustomization) AddBuildMetadata(options ...string) bool {
+	existing, _ := k.RawContent["buildMetadata"].([]any)
+	changed := false
+	for _, option := range options {
+		if !slices.Contains(existing, any(option)) {
+			existing = append(existing, option)
+			changed = true
+		}
+	}
+	if changed {
+		k.RawContent["buildMetadata"] = existing
+	}
+	return changed
+}
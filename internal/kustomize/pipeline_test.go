@@ -0,0 +1,108 @@
+package kustomize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewPipeline(t *testing.T) {
+	files := map[string]string{
+		"kustomization.yaml": `resources:
+- all.yaml
+- base
+- https://github.com/org/repo//deploy?ref=v1
+components:
+- components/pdb
+namePrefix: pre-
+patches:
+- path: patch.yaml
+- path: other.yaml
+images:
+- name: nginx
+`,
+		"all.yaml":                          "kind: Deployment\n",
+		"base/kustomization.yaml":           "resources:\n- cm.yaml\ncommonLabels:\n  tier: base\n",
+		"base/cm.yaml":                      "kind: ConfigMap\n",
+		"components/pdb/kustomization.yaml": "kind: Component\nresources:\n- pdb.yaml\npatchesStrategicMerge:\n- patch.yaml\n",
+		"components/pdb/pdb.yaml":           "kind: PodDisruptionBudget\n",
+	}
+	read := func(name string) ([]byte, bool) {
+		content, ok := files[name]
+		return []byte(content), ok
+	}
+
+	p, err := NewPipeline(".", read)
+	if err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+
+	var steps []string
+	for _, step := range p.Steps {
+		steps = append(steps, step.String())
+	}
+	wantSteps := []string{
+		"base/kustomization.yaml: commonLabels (LabelTransformer)",
+		"components/pdb/kustomization.yaml: patchesStrategicMerge (PatchStrategicMergeTransformer)",
+		"kustomization.yaml: patches[0] (PatchTransformer)",
+		"kustomization.yaml: patches[1] (PatchTransformer)",
+		"kustomization.yaml: namePrefix (PrefixTransformer)",
+		"kustomization.yaml: images[0] (ImageTagTransformer)",
+	}
+	if strings.Join(steps, "\n") != strings.Join(wantSteps, "\n") {
+		t.Errorf("Steps =\n%s\nwant\n%s", strings.Join(steps, "\n"), strings.Join(wantSteps, "\n"))
+	}
+
+	wantResources := "all.yaml base/cm.yaml components/pdb/pdb.yaml"
+	if got := strings.Join(p.ResourceFiles, " "); got != wantResources {
+		t.Errorf("ResourceFiles = %s, want %s", got, wantResources)
+	}
+
+	truncated, err := p.Truncate(3)
+	if err != nil {
+		t.Fatalf("Truncate() error = %v", err)
+	}
+	if len(truncated) != 3 {
+		t.Errorf("Truncate() returned %d files, want 3", len(truncated))
+	}
+	root := string(truncated["kustomization.yaml"])
+	for _, unwanted := range []string{"namePrefix", "images", "other.yaml"} {
+		if strings.Contains(root, unwanted) {
+			t.Errorf("Expected %s to be removed, got:\n%s", unwanted, root)
+		}
+	}
+	if !strings.Contains(root, "patch.yaml") || !strings.Contains(string(truncated["base/kustomization.yaml"]), "commonLabels") {
+		t.Errorf("Expected the first steps to be kept, got:\n%s", root)
+	}
+	if !strings.Contains(string(truncated["components/pdb/kustomization.yaml"]), "patchesStrategicMerge") {
+		t.Errorf("Expected the component patch to be kept")
+	}
+
+	none, err := p.Truncate(0)
+	if err != nil {
+		t.Fatalf("Truncate() error = %v", err)
+	}
+	if strings.Contains(string(none["components/pdb/kustomization.yaml"]), "patchesStrategicMerge") {
+		t.Errorf("Expected every step to be removed, got:\n%s", none["components/pdb/kustomization.yaml"])
+	}
+
+	if _, err := NewPipeline("missing", read); err == nil {
+		t.Error("NewPipeline() error = nil for a directory without kustomization, want error")
+	}
+}
+
+func TestKustomization_AddBuildMetadata(t *testing.T) {
+	k, err := ParseKustomization([]byte("buildMetadata: [originAnnotations]\n"))
+	if err != nil {
+		t.Fatalf("ParseKustomization() error = %v", err)
+	}
+
+	if !k.AddBuildMetadata("originAnnotations", "transformerAnnotations") {
+		t.Error("AddBuildMetadata() = false, want true")
+	}
+	if k.AddBuildMetadata("transformerAnnotations") {
+		t.Error("AddBuildMetadata() = true for an existing option, want false")
+	}
+	if got := stringItems(k.RawContent["buildMetadata"]); strings.Join(got, ",") != "originAnnotations,transformerAnnotations" {
+		t.Errorf("buildMetadata = %v", got)
+	}
+}
@@ -0,0 +1,100 @@
+package kustomize
+
+import (
+	"path"
+	"strings"
+)
+
+// referenceListFields are the kustomization fields besides resources and components
+// holding lists of file or directory paths
+var referenceListFields = []string{
+	"bases", "crds", "configurations", "generators", "transformers", "validators", "patchesStrategicMerge",
+}
+
+// References returns the local files and directories referenced by the kustomization,
+// relative to the directory containing it. Remote resources and inline content are skipped.
+func (k *Kustomization) References() []string {
+	var refs []string
+	add := func(ref string) {
+		if ref != "" && !IsRemote(ref) && !strings.Contains(ref, "\n") {
+			refs = append(refs, path.Clean(ref))
+		}
+	}
+
+	for _, ref := range k.Resources {
+		add(ref)
+	}
+	for _, ref := range k.Components {
+		add(ref)
+	}
+
+	for _, field := range referenceListFields {
+		for _, ref := range stringItems(k.RawContent[field]) {
+			add(ref)
+		}
+	}
+
+	for _, field := range []string{"patches", "patchesJson6902", "replacements"} {
+		for _, entry := range mapItems(k.RawContent[field]) {
+			ref, _ := entry["path"].(string)
+			add(ref)
+		}
+	}
+
+	for _, field := range []string{"configMapGenerator", "secretGenerator"} {
+		for _, generator := range mapItems(k.RawContent[field]) {
+			for _, file := range stringItems(generator["files"]) {
+				// Entries may be "key=path" to set the key name
+				if _, ref, found := strings.Cut(file, "="); found {
+					file = ref
+				}
+				add(file)
+			}
+			env, _ := generator["env"].(string)
+			add(env)
+			for _, env := range stringItems(generator["envs"]) {
+				add(env)
+			}
+		}
+	}
+
+	if openapi, ok := k.RawContent["openapi"].(map[string]any); ok {
+		ref, _ := openapi["path"].(string)
+		add(ref)
+	}
+
+	return refs
+}
+
+// IsRemote reports whether a kustomization reference points to a remote location
+// rather than a local file or directory
+func IsRemote(ref string) bool {
+	return strings.Contains(ref, "://") ||
+		strings.HasPrefix(ref, "git@") ||
+		strings.HasPrefix(ref, "github.com/") ||
+		strings.Contains(ref, "?ref=")
+}
+
+// stringItems returns the string elements of a list, ignoring anything else
+func stringItems(raw any) []string {
+	list, _ := raw.([]any)
+	items := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			items = append(items, s)
+		}
+	}
+	return items
+}
+
+// mapItems returns the map elements of a list, ignoring anything else
+func mapItems(raw any) []map[string]any {
+	list, _ := raw.([]any)
+	items := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		if m, ok := item.(map[string]any); ok {
+			items = append(items, m)
+		}
+	}
+	return items
+}
@@ -0,0 +1,88 @@
+package kustomize
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestKustomization_References(t *testing.T) {
+	k, err := ParseKustomization([]byte(`resources:
+- all.yaml
+- ../base
+- https://github.com/org/repo//deploy?ref=v1
+components:
+- components/pdb/
+crds:
+- crds/crd.yaml
+transformers:
+- |-
+  apiVersion: builtin
+  kind: LabelTransformer
+patchesStrategicMerge:
+- patches/smp.yaml
+patches:
+- path: patches/patch.yaml
+- patch: |-
+    - op: remove
+      path: /spec
+patchesJson6902:
+- path: patches/json.yaml
+replacements:
+- path: replacements.yaml
+configMapGenerator:
+- name: config
+  files:
+  - config/app.properties
+  - custom=config/other.properties
+  env: config/.env
+secretGenerator:
+- name: secret
+  envs:
+  - secret/.env
+openapi:
+  path: schema.json
+`))
+	if err != nil {
+		t.Fatalf("ParseKustomization() error = %v", err)
+	}
+
+	want := []string{
+		"all.yaml",
+		"../base",
+		"components/pdb",
+		"crds/crd.yaml",
+		"patches/smp.yaml",
+		"patches/patch.yaml",
+		"patches/json.yaml",
+		"replacements.yaml",
+		"config/app.properties",
+		"config/other.properties",
+		"config/.env",
+		"secret/.env",
+		"schema.json",
+	}
+
+	if got := k.References(); !slices.Equal(got, want) {
+		t.Errorf("References() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestIsRemote(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want bool
+	}{
+		{"all.yaml", false},
+		{"../base", false},
+		{"https://example.com/manifest.yaml", true},
+		{"git@github.com:org/repo.git", true},
+		{"github.com/org/repo/deploy", true},
+		{"example.com/org/repo?ref=main", true},
+	}
+
+	for _, tt := range tests {
+		if got := IsRemote(tt.ref); got != tt.want {
+			t.Errorf("IsRemote(%q) = %v, want %v", tt.ref, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package kustomize
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Selector selects resources like the target of a kustomize patch or replacement. Kind,
+// Name and Namespace are regular expressions matching the whole value.
+type Selector struct {
+	Group              string
+	Version            string
+	Kind               string
+	Name               string
+	Namespace          string
+	LabelSelector      string
+	AnnotationSelector string
+}
+
+// Target is a selector used by a kustomization
+type Target struct {
+	// Field locates the selector in the kustomization, e.g. patches[0]
+	Field    string
+	Selector Selector
+	// Required is set when the build fails if the selector matches no resource
+	Required bool
+}
+
+// Targets returns the selectors of the patches and replacements of the kustomization.
+// read returns the content of a file referenced by the kustomization, relative to the
+// directory containing it; selectors of files it can't read are skipped.
+func (k *Kustomization) Targets(read func(ref string) (string, bool)) []Target {
+	var targets []Target
+
+	for i, patch := range mapItems(k.RawContent["patches"]) {
+		field := fmt.Sprintf("patches[%d]", i)
+		if target, ok := patch["target"].(map[string]any); ok {
+			targets = append(targets, Target{Field: field, Selector: selectorOf(target)})
+			continue
+		}
+
+		// Without a target, the patch is a strategic merge patch naming its resource
+		content, _ := patch["patch"].(string)
+		if ref, ok := patch["path"].(string); ok {
+			content, _ = read(ref)
+		}
+		targets = append(targets, patchTargets(field, content)...)
+	}
+
+	for i, patch := range mapItems(k.RawContent["patchesJson6902"]) {
+		if target, ok := patch["target"].(map[string]any); ok {
+			targets = append(targets, Target{Field: fmt.Sprintf("patchesJson6902[%d]", i), Selector: selectorOf(target)})
+		}
+	}
+
+	for i, patch := range stringItems(k.RawContent["patchesStrategicMerge"]) {
+		content := patch
+		if !strings.Contains(patch, "\n") {
+			content, _ = read(patch)
+		}
+		targets = append(targets, patchTargets(fmt.Sprintf("patchesStrategicMerge[%d]", i), content)...)
+	}
+
+	for i, replacement := range mapItems(k.RawContent["replacements"]) {
+		field := fmt.Sprintf("replacements[%d]", i)
+		ref, ok := replacement["path"].(string)
+		if !ok {
+			targets = append(targets, replacementTargets(field, replacement)...)
+			continue
+		}
+
+		content, _ := read(ref)
+		var raw any
+		if err := yaml.Unmarshal([]byte(content), &raw); err != nil {
+			continue
+		}
+		if single, ok := raw.(map[string]any); ok {
+			raw = []any{single}
+		}
+		for j, r := range mapItems(raw) {
+			targets = append(targets, replacementTargets(fmt.Sprintf("%s[%d]", field, j), r)...)
+		}
+	}
+
+	return targets
+}
+
+// patchTargets returns the resources named by the documents of a strategic merge patch
+func patchTargets(field string, content string) []Target {
+	var targets []Target
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	for {
+		var doc map[string]any
+		if err := decoder.Decode(&doc); err != nil {
+			break
+		}
+
+		kind, _ := doc["kind"].(string)
+		metadata, _ := doc["metadata"].(map[string]any)
+		name, _ := metadata["name"].(string)
+		if kind == "" || name == "" {
+			continue
+		}
+
+		apiVersion, _ := doc["apiVersion"].(string)
+		group, version := splitAPIVersion(apiVersion)
+		targets = append(targets, Target{
+			Field:    field,
+			Selector: Selector{Group: group, Version: version, Kind: regexp.QuoteMeta(kind), Name: regexp.QuoteMeta(name)},
+			Required: true,
+		})
+	}
+	return targets
+}
+
+// replacementTargets returns the source and target selectors of a replacement
+func replacementTargets(field string, replacement map[string]any) []Target {
+	var targets []Target
+	if source, ok := replacement["source"].(map[string]any); ok {
+		targets = append(targets, Target{Field: field + ".source", Selector: selectorOf(source), Required: true})
+	}
+	for i, target := range mapItems(replacement["targets"]) {
+		if selected, ok := target["select"].(map[string]any); ok {
+			targets = append(targets, Target{Field: fmt.Sprintf("%s.targets[%d]", field, i), Selector: selectorOf(selected)})
+		}
+	}
+	return targets
+}
+
+// selectorOf reads a selector from its kustomization fields
+func selectorOf(raw map[string]any) Selector {
+	field := func(name string) string {
+		value, _ := raw[name].(string)
+		return value
+	}
+	return Selector{
+		Group:              field("group"),
+		Version:            field("version"),
+		Kind:               field("kind"),
+		Name:               field("name"),
+		Namespace:          field("namespace"),
+		LabelSelector:      field("labelSelector"),
+		AnnotationSelector: field("annotationSelector"),
+	}
+}
+
+// raw returns the selector as a kustomization target, leaving out unset fields
+func (s Selector) raw() map[string]any {
+	raw := map[string]any{}
+	for name, value := range map[string]string{
+		"group":              s.Group,
+		"version":            s.Version,
+		"kind":               s.Kind,
+		"name":               s.Name,
+		"namespace":          s.Namespace,
+		"labelSelector":      s.LabelSelector,
+		"annotationSelector": s.AnnotationSelector,
+	} {
+		if value != "" {
+			raw[name] = value
+		}
+	}
+	return raw
+}
+
+// Matches reports whether the selector selects the resource
+func (s Selector) Matches(resource map[string]any) bool {
+	apiVersion, _ := resource["apiVersion"].(string)
+	group, version := splitAPIVersion(apiVersion)
+	kind, _ := resource["kind"].(string)
+	metadata, _ := resource["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+
+	return (s.Group == "" || s.Group == group) &&
+		(s.Version == "" || s.Version == version) &&
+		matchesPattern(s.Kind, kind) &&
+		matchesPattern(s.Name, name) &&
+		matchesPattern(s.Namespace, namespace) &&
+		matchesLabels(s.LabelSelector, metadata["labels"]) &&
+		matchesLabels(s.AnnotationSelector, metadata["annotations"])
+}
+
+// String formats the set fields of the selector, e.g. kind=Deployment name=web
+func (s Selector) String() string {
+	var fields []string
+	for _, f := range []struct{ name, value string }{
+		{"group", s.Group}, {"version", s.Version}, {"kind", s.Kind}, {"name", s.Name}, {"namespace", s.Namespace},
+		{"labelSelector", s.LabelSelector}, {"annotationSelector", s.AnnotationSelector},
+	} {
+		if f.value != "" {
+			fields = append(fields, f.name+"="+f.value)
+		}
+	}
+	if len(fields) == 0 {
+		return "all resources"
+	}
+	return strings.Join(fields, " ")
+}
+
+// matchesPattern reports whether value matches the whole of pattern, an empty pattern matching anything
+func matchesPattern(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	return err == nil && re.MatchString(value)
+}
+
+// matchesLabels reports whether the labels or annotations in raw match the selector
+func matchesLabels(selector string, raw any) bool {
+	if selector == "" {
+		return true
+	}
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return false
+	}
+
+	set := labels.Set{}
+	values, _ := raw.(map[string]any)
+	for key, value := range values {
+		set[key] = fmt.Sprint(value)
+	}
+	return parsed.Matches(set)
+}
+
+// splitAPIVersion splits an apiVersion into its group, empty for the core group, and version
+func splitAPIVersion(apiVersion string) (group, version string) {
+	if group, version, found := strings.Cut(apiVersion, "/"); found {
+		return group, version
+	}
+	return "", apiVersion
+}
+
+// ImageNames returns the image names the images field of the kustomization sets
+func (k *Kustomization) ImageNames() []string {
+	var names []string
+	for _, image := range mapItems(k.RawContent["images"]) {
+		if name, ok := image["name"].(string); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Namespace returns the namespace the kustomization sets, empty if it sets none
+func (k *Kustomization) Namespace() string {
+	namespace, _ := k.RawContent["namespace"].(string)
+	return namespace
+}
@@ -0,0 +1,126 @@
+package kustomize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKustomization_Targets(t *testing.T) {
+	k, err := ParseKustomization([]byte(`patches:
+- path: patches/smp.yaml
+- path: patches/json.yaml
+  target:
+    kind: Deployment
+    name: web-.*
+- patch: |-
+    apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+patchesJson6902:
+- path: patches/json.yaml
+  target:
+    group: apps
+    version: v1
+    kind: StatefulSet
+    name: db
+patchesStrategicMerge:
+- patches/legacy.yaml
+replacements:
+- source:
+    kind: ConfigMap
+    name: config
+  targets:
+  - select:
+      kind: Deployment
+- path: replacements.yaml
+`))
+	if err != nil {
+		t.Fatalf("ParseKustomization() error = %v", err)
+	}
+
+	files := map[string]string{
+		"patches/smp.yaml":    "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+		"patches/legacy.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: legacy\n",
+		"replacements.yaml":   "source:\n  kind: Secret\n  name: token\ntargets: []\n",
+	}
+	targets := k.Targets(func(ref string) (string, bool) {
+		content, ok := files[ref]
+		return content, ok
+	})
+
+	var got []string
+	for _, target := range targets {
+		line := target.Field + ": " + target.Selector.String()
+		if target.Required {
+			line += " (required)"
+		}
+		got = append(got, line)
+	}
+	want := []string{
+		"patches[0]: group=apps version=v1 kind=Deployment name=web (required)",
+		"patches[1]: kind=Deployment name=web-.*",
+		"patches[2]: version=v1 kind=Service name=web (required)",
+		"patchesJson6902[0]: group=apps version=v1 kind=StatefulSet name=db",
+		"patchesStrategicMerge[0]: version=v1 kind=ConfigMap name=legacy (required)",
+		"replacements[0].source: kind=ConfigMap name=config (required)",
+		"replacements[0].targets[0]: kind=Deployment",
+		"replacements[1][0].source: kind=Secret name=token (required)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Targets() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSelector_Matches(t *testing.T) {
+	deployment := map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]any{
+			"name":        "web-frontend",
+			"labels":      map[string]any{"app": "web", "tier": "frontend"},
+			"annotations": map[string]any{"team": "platform"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		selector Selector
+		want     bool
+	}{
+		{name: "empty", selector: Selector{}, want: true},
+		{name: "kind and name", selector: Selector{Kind: "Deployment", Name: "web-frontend"}, want: true},
+		{name: "name regexp", selector: Selector{Name: "web-.*"}, want: true},
+		{name: "name is anchored", selector: Selector{Name: "web"}, want: false},
+		{name: "group and version", selector: Selector{Group: "apps", Version: "v1"}, want: true},
+		{name: "other group", selector: Selector{Group: "batch"}, want: false},
+		{name: "other kind", selector: Selector{Kind: "Service"}, want: false},
+		{name: "namespace", selector: Selector{Namespace: "prod"}, want: false},
+		{name: "label selector", selector: Selector{LabelSelector: "app=web,tier in (frontend,backend)"}, want: true},
+		{name: "label mismatch", selector: Selector{LabelSelector: "app=db"}, want: false},
+		{name: "annotation selector", selector: Selector{AnnotationSelector: "team=platform"}, want: true},
+		{name: "invalid regexp", selector: Selector{Name: "web-("}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.selector.Matches(deployment); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKustomization_ImageNamesAndNamespace(t *testing.T) {
+	k, err := ParseKustomization([]byte("namespace: prod\nimages:\n- name: nginx\n  newTag: \"1.27\"\n- newName: missing-name\n"))
+	if err != nil {
+		t.Fatalf("ParseKustomization() error = %v", err)
+	}
+
+	if got := k.ImageNames(); len(got) != 1 || got[0] != "nginx" {
+		t.Errorf("ImageNames() = %v, want [nginx]", got)
+	}
+	if got := k.Namespace(); got != "prod" {
+		t.Errorf("Namespace() = %q, want prod", got)
+	}
+}
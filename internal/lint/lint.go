@@ -0,0 +1,401 @@
+package lint
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+
+	"github.com/owhelm/helm-kustomize/internal/kustomize"
+	"github.com/owhelm/helm-kustomize/internal/parser"
+)
+
+// Severity is how serious an Issue is
+type Severity string
+
+const (
+	// Error is an issue that makes the build fail or produce wrong output
+	Error Severity = "error"
+	// Warning is an issue that is likely a mistake but doesn't break the build
+	Warning Severity = "warning"
+)
+
+// Rules identifying the checks, as reported in SARIF
+const (
+	RuleMissingKustomization = "missing-kustomization"
+	RuleInvalidKustomization = "invalid-kustomization"
+	RuleDeprecatedField      = "deprecated-field"
+	RuleOutsideReference     = "outside-reference"
+	RuleMissingReference     = "missing-reference"
+	RuleUnreferencedFile     = "unreferenced-file"
+	RuleUnmatchedTarget      = "unmatched-target"
+	RuleUnmatchedImage       = "unmatched-image"
+	RuleNamespaceConflict    = "namespace-conflict"
+)
+
+// Issue is a problem found in an embedded kustomization
+type Issue struct {
+	Severity Severity `json:"severity"`
+	Rule     string   `json:"rule"`
+	// File is the embedded file the issue was found in, empty if it concerns the whole resource
+	File    string `json:"file,omitempty"`
+	Message string `json:"message"`
+}
+
+// String formats the issue for humans
+func (i Issue) String() string {
+	if i.File == "" {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.File, i.Message)
+}
+
+// HasErrors reports whether any of the issues is an Error
+func HasErrors(issues []Issue) bool {
+	return slices.ContainsFunc(issues, func(i Issue) bool { return i.Severity == Error })
+}
+
+// Options configures Lint
+type Options struct {
+	// Overlay is the directory within the files that is built, the root if empty
+	Overlay string
+	// Namespace is the release namespace, which isn't checked if empty
+	Namespace string
+}
+
+// Lint checks the embedded kustomization of a rendered chart for common mistakes. Patch
+// and replacement targets and images are checked against the resources rendered by Helm
+// and those embedded in the files.
+func Lint(result *parser.ParseResult, opts Options) []Issue {
+	data := result.KustomizePluginData
+	overlay := opts.Overlay
+	if overlay == "" {
+		overlay = "."
+	}
+
+	var issues []Issue
+	entry := kustomizationFile(data, overlay)
+	if entry == "" {
+		message := "files must contain a root kustomization.yaml"
+		if overlay != "." {
+			message = fmt.Sprintf("files must contain a kustomization.yaml in the overlay %q", overlay)
+		}
+		issues = append(issues, Issue{Severity: Error, Rule: RuleMissingKustomization, Message: message})
+	}
+
+	kustomizations := map[string]*kustomize.Kustomization{}
+	for _, name := range slices.Sorted(maps.Keys(data.Files)) {
+		if !kustomize.IsKustomizationFile(name) {
+			continue
+		}
+
+		k, err := kustomize.ParseKustomization([]byte(data.Files[name]))
+		if err != nil {
+			issues = append(issues, Issue{Severity: Error, Rule: RuleInvalidKustomization, File: name, Message: err.Error()})
+			continue
+		}
+		kustomizations[name] = k
+
+		if deprecated := k.DeprecatedFields(); len(deprecated) > 0 {
+			issues = append(issues, Issue{
+				Severity: Warning,
+				Rule:     RuleDeprecatedField,
+				File:     name,
+				Message:  fmt.Sprintf("uses deprecated fields %s", strings.Join(deprecated, ", ")),
+			})
+		}
+
+		issues = append(issues, checkReferences(data, name, k, overlay)...)
+	}
+
+	// Files used by the build, starting from the overlay and the declared components
+	roots := []string{overlay}
+	for _, c := range data.Components {
+		roots = append(roots, c.Path)
+	}
+	used := usedFiles(data, kustomizations, roots)
+	if entry != "" {
+		issues = append(issues, checkUnreferenced(data, used)...)
+	}
+
+	var usedKustomizations []string
+	for _, name := range slices.Sorted(maps.Keys(kustomizations)) {
+		if used[name] {
+			usedKustomizations = append(usedKustomizations, name)
+		}
+	}
+
+	// Remote resources can't be known, so anything could match them
+	resources, complete := buildResources(data, result.OtherResources, kustomizations, usedKustomizations)
+	if complete {
+		issues = append(issues, checkTargets(data, kustomizations, usedKustomizations, resources)...)
+		issues = append(issues, checkImages(kustomizations, usedKustomizations, resources)...)
+	}
+
+	if entry != "" && kustomizations[entry] != nil {
+		issues = append(issues, checkNamespace(entry, kustomizations[entry], opts.Namespace)...)
+	}
+
+	return issues
+}
+
+// checkReferences reports references of the kustomization file name to files that aren't embedded
+func checkReferences(data *parser.KustomizePluginData, name string, k *kustomize.Kustomization, overlay string) []Issue {
+	var issues []Issue
+	dir := path.Dir(name)
+
+	for _, ref := range k.References() {
+		target := path.Join(dir, ref)
+
+		switch {
+		case target == ".." || strings.HasPrefix(target, "../"):
+			issues = append(issues, Issue{
+				Severity: Warning,
+				Rule:     RuleOutsideReference,
+				File:     name,
+				Message:  fmt.Sprintf("references %q, which is outside the embedded files", ref),
+			})
+		case dir == overlay && target == path.Join(overlay, "all.yaml"):
+			// Written by the post-renderer
+		case !exists(data, target):
+			issues = append(issues, Issue{
+				Severity: Error,
+				Rule:     RuleMissingReference,
+				File:     name,
+				Message:  fmt.Sprintf("references %q, which is not in files", ref),
+			})
+		}
+	}
+
+	return issues
+}
+
+// exists reports whether target is an embedded file or a directory containing embedded files
+func exists(data *parser.KustomizePluginData, target string) bool {
+	if data.HasFile(target) || target == "." {
+		return true
+	}
+	for _, name := range data.FileNames() {
+		if strings.HasPrefix(name, target+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// kustomizationFile returns the kustomization file in dir, empty if there is none
+func kustomizationFile(data *parser.KustomizePluginData, dir string) string {
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		if file := path.Join(dir, name); data.HasFile(file) {
+			return file
+		}
+	}
+	return ""
+}
+
+// usedFiles returns the kustomization files reached from the directories in roots and
+// the files they reference
+func usedFiles(data *parser.KustomizePluginData, kustomizations map[string]*kustomize.Kustomization, roots []string) map[string]bool {
+	used := map[string]bool{}
+	visited := map[string]bool{}
+
+	var visit func(dir string)
+	visit = func(dir string) {
+		if visited[dir] {
+			return
+		}
+		visited[dir] = true
+
+		name := kustomizationFile(data, dir)
+		if name == "" {
+			return
+		}
+		used[name] = true
+
+		k := kustomizations[name]
+		if k == nil {
+			return
+		}
+		for _, ref := range k.References() {
+			target := path.Join(dir, ref)
+			if data.HasFile(target) {
+				used[target] = true
+			} else {
+				visit(target)
+			}
+		}
+	}
+
+	for _, root := range roots {
+		visit(root)
+	}
+	return used
+}
+
+// checkUnreferenced reports embedded files the build doesn't use
+func checkUnreferenced(data *parser.KustomizePluginData, used map[string]bool) []Issue {
+	var issues []Issue
+	for _, name := range data.FileNames() {
+		if !used[name] {
+			issues = append(issues, Issue{
+				Severity: Warning,
+				Rule:     RuleUnreferencedFile,
+				File:     name,
+				Message:  "is not referenced by any kustomization used by the build",
+			})
+		}
+	}
+	return issues
+}
+
+// buildResources returns the resources the build starts from: the ones rendered by Helm,
+// the embedded resource files and the generated ConfigMaps and Secrets. It reports
+// whether they're complete, which they aren't when remote resources are used.
+func buildResources(data *parser.KustomizePluginData, rendered []map[string]any, kustomizations map[string]*kustomize.Kustomization, names []string) ([]map[string]any, bool) {
+	resources := slices.Clone(rendered)
+
+	for _, name := range names {
+		k := kustomizations[name]
+		for _, ref := range k.Resources {
+			if kustomize.IsRemote(ref) {
+				return nil, false
+			}
+			if content, ok := data.Files[path.Join(path.Dir(name), ref)]; ok {
+				resources = append(resources, decodeResources(content)...)
+			}
+		}
+
+		for field, kind := range map[string]string{"configMapGenerator": "ConfigMap", "secretGenerator": "Secret"} {
+			generators, _ := k.RawContent[field].([]any)
+			for _, g := range generators {
+				generator, _ := g.(map[string]any)
+				if generatorName, ok := generator["name"].(string); ok {
+					resources = append(resources, map[string]any{
+						"apiVersion": "v1",
+						"kind":       kind,
+						"metadata":   map[string]any{"name": generatorName},
+					})
+				}
+			}
+		}
+	}
+
+	return resources, true
+}
+
+// decodeResources decodes the resources of a multi-document YAML file, ignoring invalid documents
+func decodeResources(content string) []map[string]any {
+	var resources []map[string]any
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	for {
+		var doc map[string]any
+		if err := decoder.Decode(&doc); err != nil {
+			return resources
+		}
+		if doc != nil {
+			resources = append(resources, doc)
+		}
+	}
+}
+
+// checkTargets reports patches and replacements selecting no resource
+func checkTargets(data *parser.KustomizePluginData, kustomizations map[string]*kustomize.Kustomization, names []string, resources []map[string]any) []Issue {
+	var issues []Issue
+
+	for _, name := range names {
+		dir := path.Dir(name)
+		targets := kustomizations[name].Targets(func(ref string) (string, bool) {
+			content, ok := data.Files[path.Join(dir, ref)]
+			return content, ok
+		})
+
+		for _, target := range targets {
+			if slices.ContainsFunc(resources, target.Selector.Matches) {
+				continue
+			}
+
+			severity := Warning
+			if target.Required {
+				severity = Error
+			}
+			issues = append(issues, Issue{
+				Severity: severity,
+				Rule:     RuleUnmatchedTarget,
+				File:     name,
+				Message:  fmt.Sprintf("%s selects %s, which matches no resource", target.Field, target.Selector),
+			})
+		}
+	}
+
+	return issues
+}
+
+// checkImages reports images entries matching no container
+func checkImages(kustomizations map[string]*kustomize.Kustomization, names []string, resources []map[string]any) []Issue {
+	var issues []Issue
+
+	images := map[string]bool{}
+	for _, resource := range resources {
+		collectImages(resource, false, images)
+	}
+
+	for _, name := range names {
+		for _, image := range kustomizations[name].ImageNames() {
+			if !images[image] {
+				issues = append(issues, Issue{
+					Severity: Warning,
+					Rule:     RuleUnmatchedImage,
+					File:     name,
+					Message:  fmt.Sprintf("images entry %q matches no container", image),
+				})
+			}
+		}
+	}
+
+	return issues
+}
+
+// collectImages adds the names of the container images found in raw to images.
+// inContainers is set for the elements of a containers list.
+func collectImages(raw any, inContainers bool, images map[string]bool) {
+	switch value := raw.(type) {
+	case map[string]any:
+		if image, ok := value["image"].(string); ok && inContainers {
+			images[imageName(image)] = true
+		}
+		for key, child := range value {
+			collectImages(child, key == "containers" || key == "initContainers" || key == "ephemeralContainers", images)
+		}
+	case []any:
+		for _, child := range value {
+			collectImages(child, inContainers, images)
+		}
+	}
+}
+
+// imageName strips the tag and digest of an image reference
+func imageName(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// checkNamespace reports a namespace set by the overlay's kustomization that differs
+// from the release namespace
+func checkNamespace(name string, k *kustomize.Kustomization, releaseNamespace string) []Issue {
+	namespace := k.Namespace()
+	if releaseNamespace == "" || namespace == "" || namespace == releaseNamespace {
+		return nil
+	}
+	return []Issue{{
+		Severity: Warning,
+		Rule:     RuleNamespaceConflict,
+		File:     name,
+		Message:  fmt.Sprintf("sets namespace %q, which differs from the release namespace %q", namespace, releaseNamespace),
+	}}
+}
@@ -0,0 +1,301 @@
+package lint
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/owhelm/helm-kustomize/internal/parser"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "valid",
+			files: map[string]string{
+				"kustomization.yaml":         "resources:\n- all.yaml\n- overlay\npatches:\n- path: patches/patch.yaml\n",
+				"overlay/kustomization.yaml": "resources:\n- service.yaml\n",
+				"overlay/service.yaml":       "kind: Service\n",
+				"patches/patch.yaml":         "kind: Deployment\n",
+			},
+			want: nil,
+		},
+		{
+			name: "missing root kustomization",
+			files: map[string]string{
+				"overlay/kustomization.yaml": "resources: []\n",
+			},
+			want: []string{"error: files must contain a root kustomization.yaml"},
+		},
+		{
+			name: "missing references",
+			files: map[string]string{
+				"kustomization.yaml":         "resources:\n- overlay\n- missing-dir\n",
+				"overlay/kustomization.yaml": "resources:\n- all.yaml\npatches:\n- path: ../patch.yaml\n",
+			},
+			want: []string{
+				`error: kustomization.yaml: references "missing-dir", which is not in files`,
+				`error: overlay/kustomization.yaml: references "all.yaml", which is not in files`,
+				`error: overlay/kustomization.yaml: references "../patch.yaml", which is not in files`,
+			},
+		},
+		{
+			name: "outside references and deprecated fields",
+			files: map[string]string{
+				"kustomization.yaml": "bases:\n- ../base\n",
+			},
+			want: []string{
+				"warning: kustomization.yaml: uses deprecated fields bases",
+				`warning: kustomization.yaml: references "../base", which is outside the embedded files`,
+			},
+		},
+		{
+			name: "outside references",
+			files: map[string]string{
+				"kustomization.yaml": "resources:\n- ../base\n- all.yaml\n",
+			},
+			want: []string{
+				`warning: kustomization.yaml: references "../base", which is outside the embedded files`,
+			},
+		},
+		{
+			name: "invalid kustomization",
+			files: map[string]string{
+				"kustomization.yaml": "resources: all.yaml\n",
+			},
+			want: []string{
+				"error: kustomization.yaml: resources field must be an array",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := Lint(&parser.ParseResult{KustomizePluginData: &parser.KustomizePluginData{Files: tt.files}}, Options{})
+
+			var got []string
+			for _, issue := range issues {
+				got = append(got, issue.String())
+			}
+
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Lint() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestLint_Resources(t *testing.T) {
+	rendered := []map[string]any{
+		{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]any{"name": "web", "labels": map[string]any{"app": "web"}},
+			"spec": map[string]any{"template": map[string]any{"spec": map[string]any{
+				"initContainers": []any{map[string]any{"name": "init", "image": "busybox:1.36"}},
+				"containers":     []any{map[string]any{"name": "web", "image": "registry.example.com:5000/web@sha256:abc"}},
+			}}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		files     map[string]string
+		overlay   string
+		namespace string
+		want      []string
+	}{
+		{
+			name: "matching targets and images",
+			files: map[string]string{
+				"kustomization.yaml": `resources:
+- all.yaml
+- service.yaml
+namespace: prod
+configMapGenerator:
+- name: settings
+patches:
+- path: replicas.yaml
+- patch: '[{"op": "remove", "path": "/spec/selector"}]'
+  target:
+    kind: Service
+    labelSelector: app=web
+replacements:
+- source:
+    kind: ConfigMap
+    name: settings
+  targets:
+  - select:
+      kind: Deployment
+images:
+- name: busybox
+- name: registry.example.com:5000/web
+`,
+				"replicas.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 2\n",
+				"service.yaml":  "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  labels:\n    app: web\n",
+			},
+			namespace: "prod",
+			want:      nil,
+		},
+		{
+			name: "unmatched targets and images",
+			files: map[string]string{
+				"kustomization.yaml": `resources:
+- all.yaml
+patches:
+- path: replicas.yaml
+- path: json.yaml
+  target:
+    kind: StatefulSet
+replacements:
+- source:
+    kind: Secret
+    name: token
+images:
+- name: nginx
+`,
+				"replicas.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: api\n",
+				"json.yaml":     "[]\n",
+			},
+			want: []string{
+				"error: kustomization.yaml: patches[0] selects group=apps version=v1 kind=Deployment name=api, which matches no resource",
+				"warning: kustomization.yaml: patches[1] selects kind=StatefulSet, which matches no resource",
+				"error: kustomization.yaml: replacements[0].source selects kind=Secret name=token, which matches no resource",
+				`warning: kustomization.yaml: images entry "nginx" matches no container`,
+			},
+		},
+		{
+			name: "remote resources skip target checks",
+			files: map[string]string{
+				"kustomization.yaml": "resources:\n- all.yaml\n- https://github.com/org/repo//deploy?ref=v1\nimages:\n- name: nginx\n",
+			},
+			want: nil,
+		},
+		{
+			name: "unreferenced files and namespace conflict",
+			files: map[string]string{
+				"kustomization.yaml":     "resources:\n- all.yaml\nnamespace: prod\n",
+				"README.md":              "# Notes\n",
+				"old/kustomization.yaml": "resources:\n- service.yaml\n",
+				"old/service.yaml":       "kind: Service\n",
+			},
+			namespace: "staging",
+			want: []string{
+				"warning: README.md: is not referenced by any kustomization used by the build",
+				"warning: old/kustomization.yaml: is not referenced by any kustomization used by the build",
+				"warning: old/service.yaml: is not referenced by any kustomization used by the build",
+				`warning: kustomization.yaml: sets namespace "prod", which differs from the release namespace "staging"`,
+			},
+		},
+		{
+			name: "overlay",
+			files: map[string]string{
+				"base/kustomization.yaml": "resources:\n- service.yaml\n",
+				"base/service.yaml":       "kind: Service\nmetadata:\n  name: web\n",
+				"prod/kustomization.yaml": "resources:\n- all.yaml\n- ../base\n",
+			},
+			overlay: "prod",
+			want:    nil,
+		},
+		{
+			name: "missing overlay",
+			files: map[string]string{
+				"kustomization.yaml": "resources:\n- all.yaml\n",
+			},
+			overlay: "prod",
+			want: []string{
+				`error: files must contain a kustomization.yaml in the overlay "prod"`,
+				`error: kustomization.yaml: references "all.yaml", which is not in files`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &parser.ParseResult{
+				KustomizePluginData: &parser.KustomizePluginData{Files: tt.files},
+				OtherResources:      rendered,
+			}
+			issues := Lint(result, Options{Overlay: tt.overlay, Namespace: tt.namespace})
+
+			var got []string
+			for _, issue := range issues {
+				got = append(got, issue.String())
+			}
+
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Lint() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	issues := []Issue{
+		{Severity: Error, Rule: RuleMissingReference, File: "kustomization.yaml", Message: `references "patch.yaml", which is not in files`},
+		{Severity: Warning, Rule: RuleMissingKustomization, Message: "files must contain a root kustomization.yaml"},
+	}
+
+	output, err := SARIF(issues, "1.2.3", "chart/kustomization")
+	if err != nil {
+		t.Fatalf("SARIF() error = %v", err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Version string `json:"version"`
+					Rules   []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(output, &log); err != nil {
+		t.Fatalf("SARIF() produced invalid JSON: %v", err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected a SARIF 2.1.0 log with one run, got:\n%s", output)
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Version != "1.2.3" || len(run.Tool.Driver.Rules) != len(rules) {
+		t.Errorf("Unexpected driver in:\n%s", output)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("Expected 2 results, got:\n%s", output)
+	}
+	if r := run.Results[0]; r.RuleID != RuleMissingReference || r.Level != "error" || r.Locations[0].PhysicalLocation.ArtifactLocation.URI != "chart/kustomization/kustomization.yaml" {
+		t.Errorf("Unexpected first result in:\n%s", output)
+	}
+	if r := run.Results[1]; r.Level != "warning" || len(r.Locations) != 0 {
+		t.Errorf("Unexpected second result in:\n%s", output)
+	}
+}
+
+func TestHasErrors(t *testing.T) {
+	if HasErrors([]Issue{{Severity: Warning}}) {
+		t.Error("HasErrors() = true for warnings only, want false")
+	}
+	if !HasErrors([]Issue{{Severity: Warning}, {Severity: Error}}) {
+		t.Error("HasErrors() = false with an error, want true")
+	}
+}
@@ -0,0 +1,116 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ruleDescriptions documents the rules in SARIF reports
+var ruleDescriptions = map[string]string{
+	RuleMissingKustomization: "The kustomization to build is not embedded",
+	RuleInvalidKustomization: "A kustomization file can't be parsed",
+	RuleDeprecatedField:      "A kustomization file uses deprecated fields",
+	RuleOutsideReference:     "A kustomization file references a file outside the embedded files",
+	RuleMissingReference:     "A kustomization file references a file that is not embedded",
+	RuleUnreferencedFile:     "An embedded file is not used by the build",
+	RuleUnmatchedTarget:      "A patch or replacement selects no resource",
+	RuleUnmatchedImage:       "An images entry matches no container",
+	RuleNamespaceConflict:    "The kustomization sets a namespace other than the release namespace",
+}
+
+// rules lists the rules in a stable order
+var rules = []string{
+	RuleMissingKustomization, RuleInvalidKustomization, RuleDeprecatedField, RuleOutsideReference,
+	RuleMissingReference, RuleUnreferencedFile, RuleUnmatchedTarget, RuleUnmatchedImage, RuleNamespaceConflict,
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// SARIF formats the issues as a SARIF 2.1.0 log. File locations are prefixed with
+// baseDir, the directory the files are embedded from, when it isn't empty.
+func SARIF(issues []Issue, toolVersion, baseDir string) ([]byte, error) {
+	driver := sarifDriver{
+		Name:           "helm-kustomize",
+		Version:        toolVersion,
+		InformationURI: "https://github.com/owhelm/helm-kustomize",
+	}
+	for _, id := range rules {
+		driver.Rules = append(driver.Rules, sarifRule{ID: id, ShortDescription: sarifMessage{Text: ruleDescriptions[id]}})
+	}
+
+	results := make([]sarifResult, 0, len(issues))
+	for _, issue := range issues {
+		result := sarifResult{
+			RuleID:  issue.Rule,
+			Level:   string(issue.Severity),
+			Message: sarifMessage{Text: issue.Message},
+		}
+		if issue.File != "" {
+			uri := issue.File
+			if baseDir != "" {
+				uri = baseDir + "/" + uri
+			}
+			result.Locations = []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: uri}}}}
+		}
+		results = append(results, result)
+	}
+
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+	output, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal SARIF: %w", err)
+	}
+	return append(output, '\n'), nil
+}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+)
+
+// DataLabel marks a ConfigMap carrying KustomizePluginData, as a label or an annotation set
+// to "true". Unlike the custom kind, such charts pass schema validation without the plugin.
+const DataLabel = "helm-kustomize.owhelm.io/data"
+
+// carrierPathSeparator stands for "/" in the keys of a carrier ConfigMap, which can't
+// contain slashes
+const carrierPathSeparator = "__"
+
+// carrierFields are the KustomizePluginData fields a carrier ConfigMap declares as YAML in
+// annotations named after them, e.g. helm-kustomize.owhelm.io/components
+var carrierFields = []string{"kustomization", "components", "options", "requires", "substitute", "fileSources", "exclude"}
+
+// fromConfigMap converts a ConfigMap carrying KustomizePluginData to the equivalent
+// KustomizePluginData document: data holds the files and binaryData the binary files.
+// Returns nil and nil if the document is not a carrier ConfigMap.
+func fromConfigMap(doc map[string]any) (map[string]any, error) {
+	if doc["apiVersion"] != "v1" || doc["kind"] != "ConfigMap" {
+		return nil, nil
+	}
+	metadata, _ := doc["metadata"].(map[string]any)
+	labels, _ := metadata["labels"].(map[string]any)
+	annotations, _ := metadata["annotations"].(map[string]any)
+	if fmt.Sprint(labels[DataLabel]) != "true" && fmt.Sprint(annotations[DataLabel]) != "true" {
+		return nil, nil
+	}
+	name, _ := metadata["name"].(string)
+
+	converted := map[string]any{"apiVersion": APIVersion, "kind": Kind, "metadata": metadata}
+	for field, target := range map[string]string{"data": "files", "binaryData": "binaryFiles"} {
+		raw, ok := doc[field]
+		if !ok || raw == nil {
+			continue
+		}
+		entries, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("ConfigMap %q carrying %s: '%s' field must be a map", name, Kind, field)
+		}
+		files := make(map[string]any, len(entries))
+		for key, value := range entries {
+			files[strings.ReplaceAll(key, carrierPathSeparator, "/")] = value
+		}
+		converted[target] = files
+	}
+
+	for _, field := range carrierFields {
+		annotation := "helm-kustomize.owhelm.io/" + field
+		raw, ok := annotations[annotation]
+		if !ok {
+			continue
+		}
+		text, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("ConfigMap %q carrying %s: annotation %q must be a string", name, Kind, annotation)
+		}
+		var value any
+		if err := yaml.Unmarshal([]byte(text), &value); err != nil {
+			return nil, fmt.Errorf("ConfigMap %q carrying %s: annotation %q is not valid YAML: %w", name, Kind, annotation, err)
+		}
+		converted[field] = value
+	}
+
+	return converted, nil
+}
@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseManifests_ConfigMapCarrier(t *testing.T) {
+	result, err := ParseManifests([]byte(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kustomize-files
+  labels:
+    helm-kustomize.owhelm.io/data: "true"
+  annotations:
+    helm-kustomize.owhelm.io/components: |
+      - name: ha
+        path: components/ha
+    helm-kustomize.owhelm.io/options: "{output: json}"
+data:
+  kustomization.yaml: "resources: []"
+  components__ha__kustomization.yaml: "kind: Component"
+binaryData:
+  logo.png: iVBORwD/
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  kustomization.yaml: "resources: []"
+`))
+	if err != nil {
+		t.Fatalf("ParseManifests() error = %v", err)
+	}
+
+	data := result.KustomizePluginData
+	if data == nil {
+		t.Fatal("KustomizePluginData = nil, want the data of the carrier ConfigMap")
+	}
+	if _, ok := data.Files["components/ha/kustomization.yaml"]; !ok {
+		t.Errorf("Files = %v, want components/ha/kustomization.yaml", data.Files)
+	}
+	if got := string(data.BinaryFiles["logo.png"]); got != "\x89PNG\x00\xff" {
+		t.Errorf("BinaryFiles[logo.png] = %q", got)
+	}
+	if len(data.Components) != 1 || data.Components[0].Path != "components/ha" {
+		t.Errorf("Components = %v, want ha", data.Components)
+	}
+	if got := data.Options["output"]; got != "json" {
+		t.Errorf("Options[output] = %v, want json", got)
+	}
+	// Only the carrier is removed from the output
+	if len(result.OtherResources) != 1 || KeyOf(result.OtherResources[0]).Name != "settings" {
+		t.Errorf("OtherResources = %v, want the settings ConfigMap", result.OtherResources)
+	}
+}
+
+func TestParseManifests_ConfigMapCarrier_Kustomization(t *testing.T) {
+	result, err := ParseManifests([]byte(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kustomize-files
+  labels:
+    helm-kustomize.owhelm.io/data: "true"
+  annotations:
+    helm-kustomize.owhelm.io/kustomization: |
+      resources:
+        - all.yaml
+      namePrefix: prod-
+`))
+	if err != nil {
+		t.Fatalf("ParseManifests() error = %v", err)
+	}
+
+	data := result.KustomizePluginData
+	if data == nil || data.Kustomization == nil || data.Kustomization.NamePrefix != "prod-" {
+		t.Fatalf("KustomizePluginData = %v, want the kustomization of the annotation", data)
+	}
+	if got, want := data.Files["kustomization.yaml"], "namePrefix: prod-\nresources:\n  - all.yaml\n"; got != want {
+		t.Errorf("Files[kustomization.yaml] = %q, want %q", got, want)
+	}
+}
+
+func TestParseManifests_ConfigMapCarrier_Marker(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata string
+		want     bool
+	}{
+		{name: "label", metadata: "labels: {helm-kustomize.owhelm.io/data: \"true\"}", want: true},
+		{name: "annotation", metadata: "annotations: {helm-kustomize.owhelm.io/data: \"true\"}", want: true},
+		{name: "disabled", metadata: "labels: {helm-kustomize.owhelm.io/data: \"false\"}"},
+		{name: "no marker", metadata: "labels: {app: web}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseManifests([]byte(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kustomize-files
+  ` + tt.metadata + `
+data:
+  kustomization.yaml: "resources: []"
+`))
+			if err != nil {
+				t.Fatalf("ParseManifests() error = %v", err)
+			}
+			if got := result.KustomizePluginData != nil; got != tt.want {
+				t.Errorf("KustomizePluginData found = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseManifests_ConfigMapCarrier_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  string
+		wantErr string
+	}{
+		{
+			name:    "invalid annotation",
+			fields:  "metadata:\n  name: files\n  labels: {helm-kustomize.owhelm.io/data: \"true\"}\n  annotations: {helm-kustomize.owhelm.io/options: \"{output\"}\ndata: {}",
+			wantErr: `ConfigMap "files" carrying KustomizePluginData: annotation "helm-kustomize.owhelm.io/options" is not valid YAML`,
+		},
+		{
+			name:    "data not a map",
+			fields:  "metadata:\n  name: files\n  labels: {helm-kustomize.owhelm.io/data: \"true\"}\ndata: []",
+			wantErr: `ConfigMap "files" carrying KustomizePluginData: 'data' field must be a map`,
+		},
+		{
+			name:    "invalid file",
+			fields:  "metadata:\n  name: files\n  labels: {helm-kustomize.owhelm.io/data: \"true\"}\ndata: {kustomization.yaml: 3}",
+			wantErr: `at "/files/kustomization.yaml": got number, want string`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifests([]byte("---\napiVersion: v1\nkind: ConfigMap\n" + tt.fields + "\n"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseManifests() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+
+	"go.yaml.in/yaml/v4"
+)
+
+// Directories of the conventional layout, used when the files have no root kustomization
+const (
+	PatchesDir    = "patches"
+	ResourcesDir  = "resources"
+	ComponentsDir = "components"
+)
+
+// applyConvention generates the root kustomization.yaml of files laid out by convention:
+// files under patches/ are patches, strategic merge or JSON 6902 depending on their
+// content, files under resources/ are resources and the directories of components/ with a
+// kustomization are components. Components declared in data.Components are left to
+// SelectComponents. JSON 6902 patches target the resources named after them, Kind.yaml
+// or Kind_name.yaml. The other files are listed in data.UnusedFiles.
+func applyConvention(data *KustomizePluginData) error {
+	var kustomization struct {
+		Resources  []string         `yaml:"resources,omitempty"`
+		Components []string         `yaml:"components,omitempty"`
+		Patches    []map[string]any `yaml:"patches,omitempty"`
+	}
+
+	for _, name := range conventionalFiles(data.Files, PatchesDir) {
+		patch, err := conventionalPatch(name, data.Files[name])
+		if err != nil {
+			return fmt.Errorf("KustomizePluginData %s: %w", name, err)
+		}
+		kustomization.Patches = append(kustomization.Patches, patch)
+	}
+
+	for _, name := range conventionalFiles(data.Files, ResourcesDir) {
+		// Files of a kustomization under resources/ are built by it
+		resource := name
+		for dir := path.Dir(name); dir != ResourcesDir; dir = path.Dir(dir) {
+			if hasKustomizationFile(data.Files, dir) {
+				resource = dir
+			}
+		}
+		if !slices.Contains(kustomization.Resources, resource) {
+			kustomization.Resources = append(kustomization.Resources, resource)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(data.Files)) {
+		dir := path.Dir(name)
+		if path.Dir(dir) != ComponentsDir || !kustomizationFileNames[path.Base(name)] {
+			continue
+		}
+		declared := slices.ContainsFunc(data.Components, func(c Component) bool { return c.Path == dir })
+		if !declared && !slices.Contains(kustomization.Components, dir) {
+			kustomization.Components = append(kustomization.Components, dir)
+		}
+	}
+
+	// Files outside of the layout are reported, since a misplaced patch would have no effect
+	dirs := []string{PatchesDir, ResourcesDir}
+	dirs = append(dirs, kustomization.Components...)
+	for _, c := range data.Components {
+		dirs = append(dirs, c.Path)
+	}
+	for _, name := range data.FileNames() {
+		if !slices.ContainsFunc(dirs, func(dir string) bool { return strings.HasPrefix(name, dir+"/") }) {
+			data.UnusedFiles = append(data.UnusedFiles, name)
+		}
+	}
+
+	content, err := marshalFile(kustomization)
+	if err != nil {
+		return fmt.Errorf("failed to generate kustomization.yaml: %w", err)
+	}
+	if data.Files == nil {
+		data.Files = map[string]string{}
+	}
+	data.Files["kustomization.yaml"] = string(content)
+	data.Conventional = true
+	return nil
+}
+
+// kustomizationFileNames are the names kustomize looks for in a directory
+var kustomizationFileNames = map[string]bool{"kustomization.yaml": true, "kustomization.yml": true, "Kustomization": true}
+
+// conventionalFiles returns the sorted YAML and JSON files under dir
+func conventionalFiles(files map[string]string, dir string) []string {
+	var names []string
+	for name := range files {
+		ext := path.Ext(name)
+		if strings.HasPrefix(name, dir+"/") && (ext == ".yaml" || ext == ".yml" || ext == ".json") {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// conventionalPatch returns the patches entry of the patch file name. A list of operations
+// is a JSON 6902 patch targeting the resources its file name designates.
+func conventionalPatch(name, content string) (map[string]any, error) {
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	var doc any
+	if err := decoder.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+
+	switch doc.(type) {
+	case []any:
+		kind, resourceName, _ := strings.Cut(strings.TrimSuffix(path.Base(name), path.Ext(name)), "_")
+		if kind == "" || !unicode.IsUpper(rune(kind[0])) || strings.ContainsAny(kind, ".-") {
+			return nil, fmt.Errorf("JSON 6902 patches need a target: name the file Kind%s or Kind_name%s", path.Ext(name), path.Ext(name))
+		}
+		target := map[string]any{"kind": kind}
+		if resourceName != "" {
+			target["name"] = resourceName
+		}
+		return map[string]any{"path": name, "target": target}, nil
+	case map[string]any:
+		return map[string]any{"path": name}, nil
+	default:
+		return nil, fmt.Errorf("a patch must be a strategic merge patch or a list of JSON 6902 operations")
+	}
+}
+
+// hasRootKustomization reports whether the files or binary files of data have a root
+// kustomization file
+func hasRootKustomization(data *KustomizePluginData) bool {
+	for name := range kustomizationFileNames {
+		if data.HasFile(name) {
+			return true
+		}
+	}
+	return false
+}
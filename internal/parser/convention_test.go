@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseManifests_KustomizePluginData_Convention(t *testing.T) {
+	result, err := ParseManifests([]byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+components:
+- {name: istio, path: components/istio}
+files:
+  patches/labels.yaml: "{apiVersion: v1, kind: Service, metadata: {name: web}}"
+  patches/Deployment.json: '[{"op": "remove", "path": "/spec/replicas"}]'
+  patches/replicas/Deployment_web.yml: "- {op: replace, path: /spec/replicas, value: 3}"
+  patches/README.md: "Patches of the chart"
+  resources/service.yaml: "{apiVersion: v1, kind: Service, metadata: {name: extra}}"
+  resources/base/kustomization.yaml: "resources: [deployment.yaml]"
+  resources/base/deployment.yaml: "{apiVersion: apps/v1, kind: Deployment, metadata: {name: base}}"
+  components/ha/kustomization.yaml: "kind: Component"
+  components/istio/kustomization.yaml: "kind: Component"
+  components/README.md: "Optional features"
+  patch.yaml: "{apiVersion: v1, kind: Service, metadata: {name: web}}"
+`))
+	if err != nil {
+		t.Fatalf("ParseManifests() error = %v", err)
+	}
+
+	data := result.KustomizePluginData
+	if !data.Conventional {
+		t.Error("Conventional = false, want true")
+	}
+	if want := []string{"components/README.md", "patch.yaml"}; !slices.Equal(data.UnusedFiles, want) {
+		t.Errorf("UnusedFiles = %v, want %v", data.UnusedFiles, want)
+	}
+	want := `resources:
+  - resources/base
+  - resources/service.yaml
+components:
+  - components/ha
+patches:
+  - path: patches/Deployment.json
+    target:
+      kind: Deployment
+  - path: patches/labels.yaml
+  - path: patches/replicas/Deployment_web.yml
+    target:
+      kind: Deployment
+      name: web
+`
+	if got := data.Files["kustomization.yaml"]; got != want {
+		t.Errorf("kustomization.yaml =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseManifests_KustomizePluginData_ConventionNotUsed(t *testing.T) {
+	result, err := ParseManifests([]byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  Kustomization: "resources: []"
+  patches/labels.yaml: "{apiVersion: v1, kind: Service, metadata: {name: web}}"
+`))
+	if err != nil {
+		t.Fatalf("ParseManifests() error = %v", err)
+	}
+	if data := result.KustomizePluginData; data.Conventional || data.HasFile("kustomization.yaml") {
+		t.Errorf("Conventional = %v, want the kustomization of the files to be used", data.Conventional)
+	}
+}
+
+func TestParseManifests_KustomizePluginData_InvalidConvention(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "JSON 6902 patch without target",
+			file:    "patches/replicas.yaml",
+			content: "[{op: remove, path: /spec/replicas}]",
+			wantErr: "KustomizePluginData patches/replicas.yaml: JSON 6902 patches need a target: name the file Kind.yaml or Kind_name.yaml",
+		},
+		{
+			name:    "scalar patch",
+			file:    "patches/web.yaml",
+			content: "web",
+			wantErr: "a patch must be a strategic merge patch or a list of JSON 6902 operations",
+		},
+		{
+			name:    "invalid patch",
+			file:    "patches/web.yaml",
+			content: "{kind: [",
+			wantErr: "KustomizePluginData patches/web.yaml: invalid patch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifests([]byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  ` + tt.file + `: "` + tt.content + `"
+`))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseManifests() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
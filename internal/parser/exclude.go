@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+)
+
+// Exclude selects chart resources that are removed before the build. Every field that is
+// set must match. Values are glob patterns, as in path.Match.
+type Exclude struct {
+	Kind      string `yaml:"kind,omitempty"`
+	Name      string `yaml:"name,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+	// Labels maps label names to patterns of their values
+	Labels map[string]string `yaml:"labels,omitempty"`
+	// Source is the template the resource is rendered from, e.g. */templates/tests/*
+	Source string `yaml:"source,omitempty"`
+}
+
+// Exclusion lists the resources an Exclude entry removed
+type Exclusion struct {
+	Exclude Exclude
+	Matched []ResourceKey
+}
+
+// parseExclude parses the optional 'exclude' list of a KustomizePluginData resource
+func parseExclude(raw any) ([]Exclude, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	list, _ := raw.([]any)
+	excludes := make([]Exclude, 0, len(list))
+	for i, item := range list {
+		content, err := yaml.Marshal(item)
+		if err != nil {
+			return nil, fmt.Errorf("KustomizePluginData 'exclude[%d]' is invalid: %w", i, err)
+		}
+		var exclude Exclude
+		if err := yaml.Unmarshal(content, &exclude); err != nil {
+			return nil, fmt.Errorf("KustomizePluginData 'exclude[%d]' is invalid: %w", i, err)
+		}
+
+		if exclude.Kind == "" && exclude.Name == "" && exclude.Namespace == "" && exclude.Source == "" && len(exclude.Labels) == 0 {
+			return nil, fmt.Errorf("KustomizePluginData 'exclude[%d]' must select resources by kind, name, namespace, labels or source", i)
+		}
+		patterns := append([]string{exclude.Kind, exclude.Name, exclude.Namespace, exclude.Source}, slices.Collect(maps.Values(exclude.Labels))...)
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("KustomizePluginData 'exclude[%d]' has an invalid pattern %q: %w", i, pattern, err)
+			}
+		}
+		excludes = append(excludes, exclude)
+	}
+
+	return excludes, nil
+}
+
+// Matches reports whether resource, rendered from the template source, is selected
+func (e Exclude) Matches(resource map[string]any, source string) bool {
+	key := KeyOf(resource)
+	metadata, _ := resource["metadata"].(map[string]any)
+	labels, _ := metadata["labels"].(map[string]any)
+
+	for _, check := range [][2]string{{e.Kind, key.Kind}, {e.Name, key.Name}, {e.Namespace, key.Namespace}, {e.Source, source}} {
+		if check[0] != "" && !globMatch(check[0], check[1]) {
+			return false
+		}
+	}
+	for name, pattern := range e.Labels {
+		value, ok := labels[name].(string)
+		if !ok || !globMatch(pattern, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the selected resources, e.g. kind=Pod source=*/templates/tests/*
+func (e Exclude) String() string {
+	var parts []string
+	for _, field := range [][2]string{{"kind", e.Kind}, {"name", e.Name}, {"namespace", e.Namespace}} {
+		if field[1] != "" {
+			parts = append(parts, field[0]+"="+field[1])
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(e.Labels)) {
+		parts = append(parts, fmt.Sprintf("labels.%s=%s", name, e.Labels[name]))
+	}
+	if e.Source != "" {
+		parts = append(parts, "source="+e.Source)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ExcludeResources returns the resources no Exclude entry selects, and what every entry
+// removed. sources maps resources to the template they're rendered from.
+func (k *KustomizePluginData) ExcludeResources(resources []map[string]any, sources map[ResourceKey]string) ([]map[string]any, []Exclusion) {
+	exclusions := make([]Exclusion, len(k.Exclude))
+	for i, e := range k.Exclude {
+		exclusions[i].Exclude = e
+	}
+
+	kept := make([]map[string]any, 0, len(resources))
+	for _, resource := range resources {
+		key := KeyOf(resource)
+		excluded := false
+		for i, e := range k.Exclude {
+			if e.Matches(resource, sources[key]) {
+				exclusions[i].Matched = append(exclusions[i].Matched, key)
+				excluded = true
+			}
+		}
+		if !excluded {
+			kept = append(kept, resource)
+		}
+	}
+	return kept, exclusions
+}
+
+// globMatch reports whether value matches the glob pattern, which is valid
+func globMatch(pattern, value string) bool {
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseManifests_KustomizePluginData_Exclude(t *testing.T) {
+	manifest := `---
+# Source: app/templates/tests/test-connection.yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-test
+---
+# Source: app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+# Source: app/charts/redis/templates/metrics.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: redis-metrics
+  labels:
+    app.kubernetes.io/component: monitoring
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: "resources: [all.yaml]"
+exclude:
+- {kind: Pod, source: "*/templates/tests/*"}
+- kind: Service
+  name: "*-metrics"
+  labels: {app.kubernetes.io/component: monitor*}
+- {kind: Job}
+`
+	result, err := ParseManifests([]byte(manifest))
+	if err != nil {
+		t.Fatalf("ParseManifests() error = %v", err)
+	}
+
+	kept, exclusions := result.KustomizePluginData.ExcludeResources(result.OtherResources, Sources([]byte(manifest)))
+	if len(kept) != 1 || KeyOf(kept[0]).String() != "Service/web" {
+		t.Errorf("ExcludeResources() kept %v, want only Service/web", kept)
+	}
+
+	want := []string{
+		"kind=Pod source=*/templates/tests/*: Pod/web-test",
+		"kind=Service name=*-metrics labels.app.kubernetes.io/component=monitor*: Service/redis-metrics",
+		"kind=Job: ",
+	}
+	for i, e := range exclusions {
+		var matched []string
+		for _, key := range e.Matched {
+			matched = append(matched, key.String())
+		}
+		if got := e.Exclude.String() + ": " + strings.Join(matched, ", "); got != want[i] {
+			t.Errorf("exclusions[%d] = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestParseManifests_KustomizePluginData_InvalidExclude(t *testing.T) {
+	tests := []struct {
+		name    string
+		exclude string
+		wantErr string
+	}{
+		{
+			name:    "empty entry",
+			exclude: "[{}]",
+			wantErr: "KustomizePluginData 'exclude[0]' must select resources by kind, name, namespace, labels or source",
+		},
+		{
+			name:    "invalid pattern",
+			exclude: "[{kind: Pod}, {name: 'web-['}]",
+			wantErr: `KustomizePluginData 'exclude[1]' has an invalid pattern "web-["`,
+		},
+		{
+			name:    "unknown field",
+			exclude: "[{group: apps}]",
+			wantErr: `additional properties 'group' not allowed`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifests([]byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: "resources: []"
+exclude: ` + tt.exclude + `
+`))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseManifests() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
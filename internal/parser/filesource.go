@@ -0,0 +1,173 @@
+package parser
+
+import (
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"go.yaml.in/yaml/v4"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// FileSource declares where the content of an embedded file comes from
+type FileSource struct {
+	FromResource ResourceSource `yaml:"fromResource"`
+}
+
+// ResourceSource takes the content of a file from a field of another rendered resource
+type ResourceSource struct {
+	Kind string `yaml:"kind"`
+	Name string `yaml:"name"`
+	// Namespace is only needed when resources of several namespaces have the name
+	Namespace string `yaml:"namespace,omitempty"`
+	// JSONPath selects the field, e.g. .data.nginx\.conf, with or without braces
+	JSONPath string `yaml:"jsonPath"`
+	// Drop removes the resource from the output, so its data is only templated once
+	Drop bool `yaml:"drop,omitempty"`
+}
+
+// parseFileSources parses the optional 'fileSources' map of a KustomizePluginData resource.
+// Paths can't also be embedded files, since their content comes from the source.
+func parseFileSources(raw any, files map[string]string, binaryFiles map[string][]byte) (map[string]FileSource, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	entries, _ := raw.(map[string]any)
+	sources := make(map[string]FileSource, len(entries))
+	for _, name := range slices.Sorted(maps.Keys(entries)) {
+		if _, ok := files[name]; ok {
+			return nil, fmt.Errorf("KustomizePluginData file %q is declared in both 'files' and 'fileSources'", name)
+		}
+		if _, ok := binaryFiles[name]; ok {
+			return nil, fmt.Errorf("KustomizePluginData file %q is declared in both 'binaryFiles' and 'fileSources'", name)
+		}
+
+		content, err := yaml.Marshal(entries[name])
+		if err != nil {
+			return nil, fmt.Errorf("KustomizePluginData 'fileSources' value for key %q is invalid: %w", name, err)
+		}
+		var source FileSource
+		if err := yaml.Unmarshal(content, &source); err != nil {
+			return nil, fmt.Errorf("KustomizePluginData 'fileSources' value for key %q is invalid: %w", name, err)
+		}
+		from := source.FromResource
+		if from.Kind == "" || from.Name == "" {
+			return nil, fmt.Errorf("KustomizePluginData 'fileSources' value for key %q: fromResource needs a kind and a name", name)
+		}
+		if _, err := parseJSONPath(from.JSONPath); err != nil {
+			return nil, fmt.Errorf("KustomizePluginData 'fileSources' value for key %q: %w", name, err)
+		}
+		sources[name] = source
+	}
+
+	return sources, nil
+}
+
+// resolveFileSources sets the content of the files declared in data.FileSources from the
+// resources of result, and removes the sources marked to be dropped from them
+func resolveFileSources(result *ParseResult) error {
+	data := result.KustomizePluginData
+	dropped := map[ResourceKey]bool{}
+	for _, name := range slices.Sorted(maps.Keys(data.FileSources)) {
+		from := data.FileSources[name].FromResource
+
+		resource, err := findSource(result.OtherResources, from)
+		if err != nil {
+			return fmt.Errorf("KustomizePluginData file %q: %w", name, err)
+		}
+		content, err := sourceContent(resource, from)
+		if err != nil {
+			return fmt.Errorf("KustomizePluginData file %q: %s %s: %w", name, from.Kind, from.Name, err)
+		}
+
+		if utf8.Valid(content) {
+			if data.Files == nil {
+				data.Files = map[string]string{}
+			}
+			data.Files[name] = string(content)
+		} else {
+			if data.BinaryFiles == nil {
+				data.BinaryFiles = map[string][]byte{}
+			}
+			data.BinaryFiles[name] = content
+		}
+		if from.Drop {
+			dropped[KeyOf(resource)] = true
+		}
+	}
+
+	result.OtherResources = slices.DeleteFunc(result.OtherResources, func(resource map[string]any) bool {
+		return dropped[KeyOf(resource)]
+	})
+	return nil
+}
+
+// findSource returns the only resource matching from
+func findSource(resources []map[string]any, from ResourceSource) (map[string]any, error) {
+	var found []map[string]any
+	for _, resource := range resources {
+		key := KeyOf(resource)
+		if key.Kind == from.Kind && key.Name == from.Name && (from.Namespace == "" || key.Namespace == from.Namespace) {
+			found = append(found, resource)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("source resource %s not found", ResourceKey{Kind: from.Kind, Namespace: from.Namespace, Name: from.Name})
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("%d resources match %s/%s, set the namespace of the source", len(found), from.Kind, from.Name)
+	}
+}
+
+// sourceContent returns the value of the field selected by from in resource. Strings are
+// used as they are and other values as YAML. Secret data is base64-decoded.
+func sourceContent(resource map[string]any, from ResourceSource) ([]byte, error) {
+	path, err := parseJSONPath(from.JSONPath)
+	if err != nil {
+		return nil, err
+	}
+	values, err := FindValues(path, resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate %s: %w", from.JSONPath, err)
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("%s selects %d values, want 1", from.JSONPath, len(values))
+	}
+
+	switch value := values[0].(type) {
+	case string:
+		if from.Kind == "Secret" && strings.HasPrefix(strings.TrimPrefix(from.JSONPath, "{"), ".data.") {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not valid base64: %w", from.JSONPath, err)
+			}
+			return decoded, nil
+		}
+		return []byte(value), nil
+	default:
+		content, err := marshalFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", from.JSONPath, err)
+		}
+		return content, nil
+	}
+}
+
+// parseJSONPath parses the jsonPath of a file source
+func parseJSONPath(expression string) (*jsonpath.JSONPath, error) {
+	if expression == "" {
+		return nil, fmt.Errorf("jsonPath is required")
+	}
+	path, err := ParseJSONPath(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jsonPath: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseManifests_KustomizePluginData_FileSources(t *testing.T) {
+	result, err := ParseManifests([]byte(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: nginx
+data:
+  nginx.conf: |
+    worker_processes 4;
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: tls
+  namespace: web
+data:
+  tls.key: a2V5
+  logo.png: iVBORwD/
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: web
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: "resources: []"
+fileSources:
+  config/nginx.conf:
+    fromResource: {kind: ConfigMap, name: nginx, jsonPath: '.data.nginx\.conf', drop: true}
+  secrets/tls.key:
+    fromResource: {kind: Secret, name: tls, namespace: web, jsonPath: '{.data.tls\.key}'}
+  logo.png:
+    fromResource: {kind: Secret, name: tls, jsonPath: '.data.logo\.png'}
+  selector.yaml:
+    fromResource: {kind: Deployment, name: web, jsonPath: .spec.selector}
+`))
+	if err != nil {
+		t.Fatalf("ParseManifests() error = %v", err)
+	}
+
+	data := result.KustomizePluginData
+	for name, want := range map[string]string{
+		"config/nginx.conf": "worker_processes 4;\n",
+		"secrets/tls.key":   "key",
+		"selector.yaml":     "matchLabels:\n  app: web\n",
+	} {
+		if got := data.Files[name]; got != want {
+			t.Errorf("Files[%s] = %q, want %q", name, got, want)
+		}
+	}
+	if got := string(data.BinaryFiles["logo.png"]); got != "\x89PNG\x00\xff" {
+		t.Errorf("BinaryFiles[logo.png] = %q, want the decoded secret data", got)
+	}
+
+	// Only the dropped source is removed from the output
+	var kept []string
+	for _, resource := range result.OtherResources {
+		kept = append(kept, KeyOf(resource).String())
+	}
+	if got := strings.Join(kept, ", "); got != "Secret/web/tls, Deployment/web" {
+		t.Errorf("OtherResources = %s, want the Secret and the Deployment", got)
+	}
+}
+
+func TestParseManifests_KustomizePluginData_InvalidFileSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{
+			name:    "also embedded",
+			source:  "kustomization.yaml: {fromResource: {kind: ConfigMap, name: settings, jsonPath: .data.a}}",
+			wantErr: `file "kustomization.yaml" is declared in both 'files' and 'fileSources'`,
+		},
+		{
+			name:    "missing resource",
+			source:  "a.yaml: {fromResource: {kind: ConfigMap, name: other, jsonPath: .data.a}}",
+			wantErr: `KustomizePluginData file "a.yaml": source resource ConfigMap/other not found`,
+		},
+		{
+			name:    "ambiguous resource",
+			source:  "a.yaml: {fromResource: {kind: ConfigMap, name: settings, jsonPath: .data.a}}",
+			wantErr: `2 resources match ConfigMap/settings, set the namespace of the source`,
+		},
+		{
+			name:    "missing field",
+			source:  "a.yaml: {fromResource: {kind: ConfigMap, name: settings, namespace: dev, jsonPath: .data.b}}",
+			wantErr: `ConfigMap settings: .data.b selects 0 values, want 1`,
+		},
+		{
+			name:    "invalid path",
+			source:  "a.yaml: {fromResource: {kind: ConfigMap, name: settings, jsonPath: '.data[a'}}",
+			wantErr: `value for key "a.yaml": invalid jsonPath`,
+		},
+		{
+			name:    "missing path",
+			source:  "a.yaml: {fromResource: {kind: ConfigMap, name: settings}}",
+			wantErr: `at "/fileSources/a.yaml/fromResource": missing property 'jsonPath'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifests([]byte(`---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: settings, namespace: dev}
+data: {a: dev}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: settings, namespace: prod}
+data: {a: prod}
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: "resources: []"
+fileSources:
+  ` + tt.source + `
+`))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseManifests() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
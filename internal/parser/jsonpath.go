@@ -0,0 +1,35 @@
+package parser
+
+import (
+	"strings"
+
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// ParseJSONPath parses a JSONPath expression in kubectl's syntax, with or without the
+// surrounding braces. Missing keys select no value instead of failing.
+func ParseJSONPath(expression string) (*jsonpath.JSONPath, error) {
+	if !strings.HasPrefix(expression, "{") {
+		expression = "{" + expression + "}"
+	}
+	path := jsonpath.New("path").AllowMissingKeys(true)
+	if err := path.Parse(expression); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+// FindValues returns the values path selects in resource
+func FindValues(path *jsonpath.JSONPath, resource map[string]any) ([]any, error) {
+	results, err := path.FindResults(resource)
+	if err != nil {
+		return nil, err
+	}
+	var values []any
+	for _, group := range results {
+		for _, v := range group {
+			values = append(values, v.Interface())
+		}
+	}
+	return values, nil
+}
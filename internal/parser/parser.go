@@ -2,10 +2,18 @@ package parser
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
 
+	"github.com/Masterminds/semver/v3"
 	"go.yaml.in/yaml/v4"
+	"sigs.k8s.io/kustomize/api/types"
 )
 
 const (
@@ -13,24 +21,86 @@ const (
 	Kind       = "KustomizePluginData"
 )
 
+// upgradeHint tells users how to install the latest plugin
+const upgradeHint = "helm plugin uninstall helm-kustomize && helm plugin install oci://ghcr.io/owhelm/helm-kustomize:latest"
+
 // KustomizePluginData represents the special resource containing kustomize files
 type KustomizePluginData struct {
-	APIVersion string            `yaml:"apiVersion"`
-	Kind       string            `yaml:"kind"`
-	Files      map[string]string `yaml:"files"`
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	// Files is required unless Kustomization is set
+	Files map[string]string `yaml:"files,omitempty"`
+	// Kustomization is the root kustomization as a YAML map, an alternative to a
+	// kustomization.yaml in Files, which it is written to when parsed
+	Kustomization *types.Kustomization `yaml:"kustomization,omitempty"`
+	// BinaryFiles holds files that can't be embedded as text, such as binary files, base64-encoded in the resource
+	BinaryFiles map[string][]byte `yaml:"binaryFiles,omitempty"`
+	// FileSources declares files whose content is taken from other rendered resources,
+	// keyed by path. ParseManifests adds them to Files, or to BinaryFiles if they aren't text.
+	FileSources map[string]FileSource `yaml:"fileSources,omitempty"`
+	Components  []Component           `yaml:"components,omitempty"`
+	// Options holds the chart's defaults for the post-renderer options, keyed as in the
+	// config file. Arguments, environment variables and the config file take precedence.
+	Options map[string]any `yaml:"options,omitempty"`
+	// Requires declares what the chart needs from the plugin
+	Requires Requires `yaml:"requires,omitempty"`
+	// Exclude selects the chart resources removed before the build
+	Exclude []Exclude `yaml:"exclude,omitempty"`
+	// Substitute enables the substitution of environment variables in Files when set
+	Substitute *Substitute `yaml:"substitute,omitempty"`
+	// Conventional is set when the root kustomization.yaml was generated from the layout of
+	// Files, because there was none
+	Conventional bool `yaml:"-"`
+	// UnusedFiles are the files outside of the conventional layout, which the generated
+	// kustomization.yaml doesn't use
+	UnusedFiles []string `yaml:"-"`
+}
+
+// Substitute configures the substitution of ${VAR} references in the embedded files
+type Substitute struct {
+	// AllowedVars are the environment variables that can be substituted, besides the
+	// variables Helm passes to plugins
+	AllowedVars []string `yaml:"allowedVars,omitempty"`
+}
+
+// Requires declares the plugin features a chart depends on
+type Requires struct {
+	// PluginVersion is a version constraint on the plugin, e.g. ">=0.3.0"
+	PluginVersion string `yaml:"pluginVersion,omitempty"`
+}
+
+// Component describes an optional kustomize Component embedded in the files map
+type Component struct {
+	// Name identifies the component when enabling or disabling it at render time
+	Name string `yaml:"name"`
+	// Path is the directory of the component inside the files map
+	Path string `yaml:"path"`
+	// Enabled is the default enablement when no override is given
+	Enabled bool `yaml:"enabled,omitempty"`
 }
 
 // ParseResult contains the parsed manifests separated by type
 type ParseResult struct {
 	KustomizePluginData *KustomizePluginData
 	OtherResources      []map[string]any
+	// Skipped are the resources annotated with SkipAnnotation, which bypass kustomize
+	Skipped []map[string]any
 }
 
 // tryParseKustomizePluginDataResource attempts to parse a document as KustomizePluginData.
 // Returns the parsed KustomizePluginData and nil error if successful.
 // Returns nil and nil if the document is not a KustomizePluginData resource.
 // Returns nil and error if the document is a KustomizePluginData resource but has invalid structure.
-func tryParseKustomizePluginDataResource(doc map[string]any) (*KustomizePluginData, error) {
+// A ConfigMap carrying KustomizePluginData is parsed like the resource it stands for.
+func tryParseKustomizePluginDataResource(doc map[string]any, opts ParseOptions) (*KustomizePluginData, error) {
+	carried, err := fromConfigMap(doc)
+	if err != nil {
+		return nil, err
+	}
+	if carried != nil {
+		doc = carried
+	}
+
 	// Check apiVersion
 	apiVersion, ok := doc["apiVersion"].(string)
 	if !ok || apiVersion != APIVersion {
@@ -43,30 +113,325 @@ func tryParseKustomizePluginDataResource(doc map[string]any) (*KustomizePluginDa
 		return nil, nil
 	}
 
-	// Parse files - this is required and must be map[string]string
-	filesRaw, ok := doc["files"].(map[string]any)
-	if !ok {
-		return nil, fmt.Errorf("KustomizePluginData 'files' field must be a map")
+	// Check the requirements first, since older plugins may not understand the other fields
+	requires, err := parseRequires(doc["requires"], opts.PluginVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// The schema checks the type of every field, so the parsers below only check values
+	if err := validateSchema(doc); err != nil {
+		return nil, err
 	}
 
+	filesRaw, _ := doc["files"].(map[string]any)
 	files := make(map[string]string, len(filesRaw))
 	for k, v := range filesRaw {
-		strVal, ok := v.(string)
-		if !ok {
-			return nil, fmt.Errorf("KustomizePluginData 'files' values must be strings, got non-string value for key %q", k)
-		}
-		files[k] = strVal
+		files[k], _ = v.(string)
+	}
+
+	binaryFiles, err := parseBinaryFiles(doc["binaryFiles"], files)
+	if err != nil {
+		return nil, err
+	}
+
+	kustomization, err := parseKustomization(doc["kustomization"], files, binaryFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	fileSources, err := parseFileSources(doc["fileSources"], files, binaryFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	components, err := parseComponents(doc["components"], files)
+	if err != nil {
+		return nil, err
+	}
+
+	substitute, err := parseSubstitute(doc["substitute"])
+	if err != nil {
+		return nil, err
+	}
+
+	exclude, err := parseExclude(doc["exclude"])
+	if err != nil {
+		return nil, err
 	}
 
+	options, _ := doc["options"].(map[string]any)
+
 	return &KustomizePluginData{
-		APIVersion: apiVersion,
-		Kind:       kind,
-		Files:      files,
+		APIVersion:    apiVersion,
+		Kind:          kind,
+		Files:         files,
+		Kustomization: kustomization,
+		BinaryFiles:   binaryFiles,
+		FileSources:   fileSources,
+		Components:    components,
+		Options:       options,
+		Requires:      requires,
+		Exclude:       exclude,
+		Substitute:    substitute,
 	}, nil
 }
 
+// parseKustomization parses the optional structured 'kustomization' field of a
+// KustomizePluginData resource, validates it as kustomize would and writes it to files as
+// the root kustomization.yaml. It can't be combined with a root kustomization file.
+func parseKustomization(raw any, files map[string]string, binaryFiles map[string][]byte) (*types.Kustomization, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	fields, _ := raw.(map[string]any)
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		_, isText := files[name]
+		_, isBinary := binaryFiles[name]
+		if isText || isBinary {
+			return nil, fmt.Errorf("KustomizePluginData 'kustomization' and the file %q are mutually exclusive, use one or the other", name)
+		}
+	}
+
+	content, err := marshalFile(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode KustomizePluginData 'kustomization': %w", err)
+	}
+
+	k := &types.Kustomization{}
+	if err := k.Unmarshal(content); err != nil {
+		return nil, fmt.Errorf("KustomizePluginData 'kustomization' is invalid: %w", err)
+	}
+	files["kustomization.yaml"] = string(content)
+	return k, nil
+}
+
+// marshalFile encodes value as the YAML content of an embedded file, indented like the
+// files written by hand
+func marshalFile(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// varName matches valid environment variable names
+var varName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseSubstitute parses the optional 'substitute' map of a KustomizePluginData resource
+func parseSubstitute(raw any) (*Substitute, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	entries, _ := raw.(map[string]any)
+	list, _ := entries["allowedVars"].([]any)
+
+	substitute := &Substitute{}
+	for i, item := range list {
+		name, _ := item.(string)
+		if !varName.MatchString(name) {
+			return nil, fmt.Errorf("KustomizePluginData 'substitute.allowedVars[%d]' must be an environment variable name, got %v", i, item)
+		}
+		substitute.AllowedVars = append(substitute.AllowedVars, name)
+	}
+	return substitute, nil
+}
+
+// parseRequires parses the optional 'requires' map of a KustomizePluginData resource and
+// checks pluginVersion, the version of the running plugin, against its constraint unless empty
+func parseRequires(raw any, pluginVersion string) (Requires, error) {
+	if raw == nil {
+		return Requires{}, nil
+	}
+
+	entries, ok := raw.(map[string]any)
+	if !ok {
+		return Requires{}, fmt.Errorf("KustomizePluginData 'requires' field must be a map")
+	}
+
+	var requires Requires
+	if value, ok := entries["pluginVersion"]; ok {
+		requires.PluginVersion, ok = value.(string)
+		if !ok || requires.PluginVersion == "" {
+			return Requires{}, fmt.Errorf("KustomizePluginData 'requires.pluginVersion' must be a non-empty string")
+		}
+		constraint, err := semver.NewConstraint(requires.PluginVersion)
+		if err != nil {
+			return Requires{}, fmt.Errorf("KustomizePluginData 'requires.pluginVersion' is not a valid version constraint: %w", err)
+		}
+
+		if pluginVersion != "" {
+			current, err := semver.NewVersion(pluginVersion)
+			if err != nil {
+				return Requires{}, fmt.Errorf("invalid plugin version %q: %w", pluginVersion, err)
+			}
+			if !constraint.Check(current) {
+				return Requires{}, fmt.Errorf("the chart requires helm-kustomize %s, but version %s is installed; upgrade the plugin with: %s",
+					requires.PluginVersion, pluginVersion, upgradeHint)
+			}
+		}
+	}
+
+	// Requirements added by later versions can't be checked, so they must not be ignored
+	for _, key := range slices.Sorted(maps.Keys(entries)) {
+		if key != "pluginVersion" {
+			return Requires{}, fmt.Errorf("KustomizePluginData 'requires' has unknown field %q, the chart may need a newer plugin; upgrade it with: %s", key, upgradeHint)
+		}
+	}
+
+	return requires, nil
+}
+
+// parseBinaryFiles parses the optional 'binaryFiles' map of a KustomizePluginData resource,
+// whose values are base64-encoded. A path can't be in both files and binaryFiles.
+func parseBinaryFiles(raw any, files map[string]string) (map[string][]byte, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	entries, _ := raw.(map[string]any)
+	binaryFiles := make(map[string][]byte, len(entries))
+	for name, value := range entries {
+		encoded, _ := value.(string)
+		if _, ok := files[name]; ok {
+			return nil, fmt.Errorf("KustomizePluginData file %q is declared in both 'files' and 'binaryFiles'", name)
+		}
+
+		// Tolerate line breaks, e.g. from base64 tools that wrap their output
+		content, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+		if err != nil {
+			return nil, fmt.Errorf("KustomizePluginData 'binaryFiles' value for key %q is not valid base64: %w", name, err)
+		}
+		binaryFiles[name] = content
+	}
+
+	return binaryFiles, nil
+}
+
+// HasFile reports whether name is embedded as a text or binary file
+func (k *KustomizePluginData) HasFile(name string) bool {
+	_, isText := k.Files[name]
+	_, isBinary := k.BinaryFiles[name]
+	return isText || isBinary
+}
+
+// FileNames returns the sorted names of the text and binary files
+func (k *KustomizePluginData) FileNames() []string {
+	names := slices.Collect(maps.Keys(k.Files))
+	for name := range k.BinaryFiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// parseComponents parses the optional 'components' list of a KustomizePluginData resource.
+// Every component must have a unique name and point to a directory in files that
+// contains a kustomization file.
+func parseComponents(raw any, files map[string]string) ([]Component, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	list, _ := raw.([]any)
+	components := make([]Component, 0, len(list))
+	for i, item := range list {
+		entry, _ := item.(map[string]any)
+
+		name, _ := entry["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("KustomizePluginData 'components[%d].name' must be a non-empty string", i)
+		}
+		if slices.ContainsFunc(components, func(c Component) bool { return c.Name == name }) {
+			return nil, fmt.Errorf("KustomizePluginData component %q is declared more than once", name)
+		}
+
+		componentPath, _ := entry["path"].(string)
+		if componentPath == "" {
+			return nil, fmt.Errorf("KustomizePluginData 'components[%d].path' must be a non-empty string", i)
+		}
+		componentPath = path.Clean(componentPath)
+		if !hasKustomizationFile(files, componentPath) {
+			return nil, fmt.Errorf("KustomizePluginData component %q: no kustomization.yaml found in files under %q", name, componentPath)
+		}
+
+		enabled, _ := entry["enabled"].(bool)
+		components = append(components, Component{Name: name, Path: componentPath, Enabled: enabled})
+	}
+
+	return components, nil
+}
+
+// hasKustomizationFile reports whether files contains a kustomization file in dir
+func hasKustomizationFile(files map[string]string, dir string) bool {
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		if _, ok := files[path.Join(dir, name)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectComponents returns the components that should be enabled, in declaration order.
+// Each component starts from its default enablement, which is replaced by the entry in
+// overrides if there is one. Overrides naming an undeclared component are an error.
+func (k *KustomizePluginData) SelectComponents(overrides map[string]bool) ([]Component, error) {
+	for name := range overrides {
+		if !slices.ContainsFunc(k.Components, func(c Component) bool { return c.Name == name }) {
+			return nil, fmt.Errorf("unknown component %q, available components: %s", name, k.componentNames())
+		}
+	}
+
+	var selected []Component
+	for _, c := range k.Components {
+		enabled := c.Enabled
+		if override, ok := overrides[c.Name]; ok {
+			enabled = override
+		}
+		if enabled {
+			selected = append(selected, c)
+		}
+	}
+
+	return selected, nil
+}
+
+// componentNames returns the sorted, comma-separated names of all declared components
+func (k *KustomizePluginData) componentNames() string {
+	if len(k.Components) == 0 {
+		return "(none)"
+	}
+
+	names := make([]string, 0, len(k.Components))
+	for _, c := range k.Components {
+		names = append(names, c.Name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+// ParseOptions configures ParseManifestsWith
+type ParseOptions struct {
+	// PluginVersion is the version of the running plugin, which the requirements of
+	// KustomizePluginData resources are checked against. They aren't checked when it's empty.
+	PluginVersion string
+}
+
 // ParseManifests parses YAML input from bytes and separates KustomizePluginData from other resources
 func ParseManifests(data []byte) (*ParseResult, error) {
+	return ParseManifestsWith(data, ParseOptions{})
+}
+
+// ParseManifestsWith is ParseManifests with options
+func ParseManifestsWith(data []byte, opts ParseOptions) (*ParseResult, error) {
 	result := &ParseResult{
 		OtherResources: make([]map[string]any, 0),
 	}
@@ -89,7 +454,7 @@ func ParseManifests(data []byte) (*ParseResult, error) {
 			continue
 		}
 
-		kpd, err := tryParseKustomizePluginDataResource(doc)
+		kpd, err := tryParseKustomizePluginDataResource(doc, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -104,6 +469,24 @@ func ParseManifests(data []byte) (*ParseResult, error) {
 		}
 	}
 
+	// File sources can only be resolved once every resource is parsed
+	if result.KustomizePluginData != nil && len(result.KustomizePluginData.FileSources) > 0 {
+		if err := resolveFileSources(result); err != nil {
+			return nil, err
+		}
+	}
+
+	// Skipped resources can still be file sources, so they're only set aside now
+	if result.KustomizePluginData != nil {
+		skipResources(result)
+	}
+
+	if result.KustomizePluginData != nil && !hasRootKustomization(result.KustomizePluginData) {
+		if err := applyConvention(result.KustomizePluginData); err != nil {
+			return nil, err
+		}
+	}
+
 	return result, nil
 }
 
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -333,7 +334,7 @@ apiVersion: helm.plugin.kustomize/v1
 kind: KustomizePluginData
 files: "not a map"
 `,
-			wantErrSubstr: "files' field must be a map",
+			wantErrSubstr: `at "/files": got string, want object`,
 		},
 		{
 			name: "files field missing",
@@ -341,7 +342,7 @@ files: "not a map"
 apiVersion: helm.plugin.kustomize/v1
 kind: KustomizePluginData
 `,
-			wantErrSubstr: "files' field must be a map",
+			wantErrSubstr: `at "": missing property 'files'`,
 		},
 		{
 			name: "files value is not a string",
@@ -351,7 +352,7 @@ kind: KustomizePluginData
 files:
   test.yaml: 123
 `,
-			wantErrSubstr: "files' values must be strings",
+			wantErrSubstr: `at "/files/test.yaml": got number, want string`,
 		},
 		{
 			name: "files value is a map instead of string",
@@ -362,7 +363,7 @@ files:
   test.yaml:
     nested: value
 `,
-			wantErrSubstr: "files' values must be strings",
+			wantErrSubstr: `at "/files/test.yaml": got object, want string`,
 		},
 	}
 
@@ -378,3 +379,436 @@ files:
 		})
 	}
 }
+
+func TestParseManifests_KustomizePluginData_Components(t *testing.T) {
+	input := []byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    resources:
+    - all.yaml
+  components/istio/kustomization.yaml: |
+    kind: Component
+  components/pdb/kustomization.yaml: |
+    kind: Component
+components:
+  - name: istio
+    path: components/istio/
+  - name: pdb
+    path: components/pdb
+    enabled: true
+`)
+
+	result, err := ParseManifests(input)
+	if err != nil {
+		t.Fatalf("ParseManifests() error = %v, want nil", err)
+	}
+
+	want := []Component{
+		{Name: "istio", Path: "components/istio", Enabled: false},
+		{Name: "pdb", Path: "components/pdb", Enabled: true},
+	}
+	got := result.KustomizePluginData.Components
+	if len(got) != len(want) {
+		t.Fatalf("Components = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Components[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseManifests_KustomizePluginData_InvalidComponents(t *testing.T) {
+	tests := []struct {
+		name          string
+		components    string
+		wantErrSubstr string
+	}{
+		{
+			name:          "components not a list",
+			components:    `components: "istio"`,
+			wantErrSubstr: `at "/components": got string, want array`,
+		},
+		{
+			name: "component not a map",
+			components: `components:
+  - istio`,
+			wantErrSubstr: `at "/components/0": got string, want object`,
+		},
+		{
+			name: "missing name",
+			components: `components:
+  - path: components/istio`,
+			wantErrSubstr: `at "/components/0": missing property 'name'`,
+		},
+		{
+			name: "missing path",
+			components: `components:
+  - name: istio`,
+			wantErrSubstr: `at "/components/0": missing property 'path'`,
+		},
+		{
+			name: "path without kustomization",
+			components: `components:
+  - name: istio
+    path: components/missing`,
+			wantErrSubstr: "no kustomization.yaml found",
+		},
+		{
+			name: "enabled not a boolean",
+			components: `components:
+  - name: istio
+    path: components/istio
+    enabled: "yes"`,
+			wantErrSubstr: `at "/components/0/enabled": got string, want boolean`,
+		},
+		{
+			name: "duplicate name",
+			components: `components:
+  - name: istio
+    path: components/istio
+  - name: istio
+    path: components/istio`,
+			wantErrSubstr: "declared more than once",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := `---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  components/istio/kustomization.yaml: |
+    kind: Component
+` + tt.components + "\n"
+
+			_, err := ParseManifests([]byte(input))
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErrSubstr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErrSubstr, err)
+			}
+		})
+	}
+}
+
+func TestKustomizePluginData_SelectComponents(t *testing.T) {
+	data := &KustomizePluginData{
+		Components: []Component{
+			{Name: "istio", Path: "components/istio"},
+			{Name: "pdb", Path: "components/pdb", Enabled: true},
+			{Name: "hpa", Path: "components/hpa", Enabled: true},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		overrides map[string]bool
+		want      []string
+		wantErr   string
+	}{
+		{
+			name:      "defaults",
+			overrides: nil,
+			want:      []string{"pdb", "hpa"},
+		},
+		{
+			name:      "enable and disable",
+			overrides: map[string]bool{"istio": true, "pdb": false},
+			want:      []string{"istio", "hpa"},
+		},
+		{
+			name:      "disable all",
+			overrides: map[string]bool{"pdb": false, "hpa": false},
+			want:      nil,
+		},
+		{
+			name:      "unknown component",
+			overrides: map[string]bool{"linkerd": true},
+			wantErr:   `unknown component "linkerd", available components: hpa, istio, pdb`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := data.SelectComponents(tt.overrides)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SelectComponents() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectComponents() error = %v, want nil", err)
+			}
+
+			var got []string
+			for _, c := range selected {
+				got = append(got, c.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("SelectComponents() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseManifests_KustomizePluginData_Options(t *testing.T) {
+	input := []byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: "resources: []"
+options:
+  overlay: overlays/prod
+  warningsAsErrors: true
+`)
+
+	result, err := ParseManifests(input)
+	if err != nil {
+		t.Fatalf("ParseManifests() error = %v, want nil", err)
+	}
+
+	options := result.KustomizePluginData.Options
+	if options["overlay"] != "overlays/prod" || options["warningsAsErrors"] != true {
+		t.Errorf("Options = %v, want overlay and warningsAsErrors", options)
+	}
+
+	_, err = ParseManifests([]byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files: {}
+options: [overlay]
+`))
+	if err == nil || !strings.Contains(err.Error(), `at "/options": got array, want object`) {
+		t.Errorf("ParseManifests() error = %v, want options map error", err)
+	}
+}
+
+func TestParseManifests_KustomizePluginData_BinaryFiles(t *testing.T) {
+	result, err := ParseManifests([]byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: "resources: []"
+binaryFiles:
+  logo.png: |
+    iVBO
+    RwD/
+`))
+	if err != nil {
+		t.Fatalf("ParseManifests() error = %v, want nil", err)
+	}
+
+	data := result.KustomizePluginData
+	if got, want := string(data.BinaryFiles["logo.png"]), "\x89PNG\x00\xff"; got != want {
+		t.Errorf("BinaryFiles[logo.png] = %q, want %q", got, want)
+	}
+	if !data.HasFile("logo.png") || !data.HasFile("kustomization.yaml") || data.HasFile("missing") {
+		t.Error("HasFile() should report text and binary files only")
+	}
+	if names := data.FileNames(); len(names) != 2 || names[0] != "kustomization.yaml" || names[1] != "logo.png" {
+		t.Errorf("FileNames() = %v, want [kustomization.yaml logo.png]", names)
+	}
+}
+
+func TestParseManifests_KustomizePluginData_InvalidBinaryFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		binaryFiles string
+		wantErr     string
+	}{
+		{name: "not a map", binaryFiles: "[logo.png]", wantErr: `at "/binaryFiles": got array, want object`},
+		{name: "non-string value", binaryFiles: "{logo.png: 42}", wantErr: `at "/binaryFiles/logo.png": got number, want string`},
+		{name: "invalid base64", binaryFiles: "{logo.png: '!!!'}", wantErr: `value for key "logo.png" is not valid base64`},
+		{name: "duplicate path", binaryFiles: "{kustomization.yaml: cmVzb3VyY2VzOiBbXQ==}", wantErr: `"kustomization.yaml" is declared in both 'files' and 'binaryFiles'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifests([]byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: "resources: []"
+binaryFiles: ` + tt.binaryFiles + `
+`))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseManifests() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseManifests_KustomizePluginData_Requires(t *testing.T) {
+	tests := []struct {
+		name     string
+		requires string
+		want     string
+		wantErr  string
+	}{
+		{name: "satisfied", requires: `{pluginVersion: ">=0.3.0"}`, want: ">=0.3.0"},
+		{name: "range", requires: `{pluginVersion: "~0.3"}`, want: "~0.3"},
+		{name: "too old", requires: `{pluginVersion: ">=0.4.0"}`, wantErr: "the chart requires helm-kustomize >=0.4.0, but version 0.3.1 is installed; upgrade the plugin with: helm plugin uninstall"},
+		{name: "invalid constraint", requires: `{pluginVersion: "newest"}`, wantErr: "'requires.pluginVersion' is not a valid version constraint"},
+		{name: "not a string", requires: `{pluginVersion: 3}`, wantErr: "'requires.pluginVersion' must be a non-empty string"},
+		{name: "not a map", requires: `[">=0.3.0"]`, wantErr: "'requires' field must be a map"},
+		{name: "unknown requirement", requires: `{kustomizeVersion: ">=5"}`, wantErr: `'requires' has unknown field "kustomizeVersion", the chart may need a newer plugin`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseManifestsWith([]byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: "resources: []"
+requires: `+tt.requires+`
+`), ParseOptions{PluginVersion: "0.3.1"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseManifests() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseManifests() error = %v, want nil", err)
+			}
+			if got := result.KustomizePluginData.Requires.PluginVersion; got != tt.want {
+				t.Errorf("Requires.PluginVersion = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseManifests_KustomizePluginData_Kustomization(t *testing.T) {
+	result, err := ParseManifests([]byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+kustomization:
+  namePrefix: prod-
+  images:
+  - name: nginx
+    newTag: "1.27"
+  labels:
+  - pairs:
+      team: web
+  patches:
+  - target:
+      kind: Deployment
+    patch: |-
+      - op: replace
+        path: /spec/replicas
+        value: 3
+  replacements:
+  - source:
+      kind: ConfigMap
+      name: settings
+      fieldPath: data.host
+    targets:
+    - select:
+        kind: Ingress
+      fieldPaths:
+      - spec.rules.0.host
+`))
+	if err != nil {
+		t.Fatalf("ParseManifests() error = %v", err)
+	}
+
+	data := result.KustomizePluginData
+	k := data.Kustomization
+	if k == nil {
+		t.Fatal("Kustomization = nil, want the parsed kustomization")
+	}
+	if k.NamePrefix != "prod-" || len(k.Images) != 1 || len(k.Labels) != 1 || len(k.Patches) != 1 || len(k.Replacements) != 1 {
+		t.Errorf("Kustomization = %+v, want the name prefix, image, labels, patch and replacement", k)
+	}
+	content, ok := data.Files["kustomization.yaml"]
+	if !ok {
+		t.Fatal("Files has no kustomization.yaml")
+	}
+	for _, want := range []string{"namePrefix: prod-\n", "newTag: \"1.27\"\n", "- patch: |-\n      - op: replace\n", "fieldPath: data.host\n"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("kustomization.yaml = %q, want it to contain %q", content, want)
+		}
+	}
+}
+
+func TestParseManifests_KustomizePluginData_InvalidKustomization(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  string
+		wantErr string
+	}{
+		{
+			name:    "kustomization file too",
+			fields:  "files:\n  kustomization.yaml: \"resources: []\"\nkustomization: {namePrefix: a-}",
+			wantErr: `KustomizePluginData 'kustomization' and the file "kustomization.yaml" are mutually exclusive`,
+		},
+		{
+			name:    "binary kustomization file too",
+			fields:  "binaryFiles:\n  Kustomization: cmVzb3VyY2VzOiBbXQ==\nkustomization: {namePrefix: a-}",
+			wantErr: `KustomizePluginData 'kustomization' and the file "Kustomization" are mutually exclusive`,
+		},
+		{name: "not a map", fields: "kustomization: [resources]", wantErr: `at "/kustomization": got array, want object`},
+		{name: "unknown field", fields: "kustomization: {namePrefx: a-}", wantErr: `at "/kustomization": additional properties 'namePrefx' not allowed`},
+		{name: "wrong type", fields: "kustomization: {images: {name: nginx}}", wantErr: `at "/kustomization/images": got object, want array`},
+		{name: "neither", fields: "", wantErr: `at "": missing property 'kustomization'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifests([]byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+` + tt.fields + "\n"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseManifests() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseManifests_KustomizePluginData_Substitute(t *testing.T) {
+	tests := []struct {
+		name       string
+		substitute string
+		want       []string
+		wantErr    string
+	}{
+		{name: "allowlist", substitute: "{allowedVars: [REGISTRY, CLUSTER_NAME]}", want: []string{"REGISTRY", "CLUSTER_NAME"}},
+		{name: "Helm variables only", substitute: "{}"},
+		{name: "invalid name", substitute: "{allowedVars: [REGISTRY, 'cluster-name']}", wantErr: "'substitute.allowedVars[1]' must be an environment variable name, got cluster-name"},
+		{name: "not a list", substitute: "{allowedVars: REGISTRY}", wantErr: `at "/substitute/allowedVars": got string, want array`},
+		{name: "unknown field", substitute: "{allowedVar: [REGISTRY]}", wantErr: `at "/substitute": additional properties 'allowedVar' not allowed`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseManifests([]byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: "resources: []"
+substitute: ` + tt.substitute + `
+`))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseManifests() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseManifests() error = %v, want nil", err)
+			}
+			substitute := result.KustomizePluginData.Substitute
+			if substitute == nil || !slices.Equal(substitute.AllowedVars, tt.want) {
+				t.Errorf("Substitute = %+v, want allowed variables %v", substitute, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+)
+
+// sourcePrefix starts the comment Helm writes before each rendered template
+const sourcePrefix = "# Source: "
+
+// ResourceKey identifies a Kubernetes resource by kind, namespace and name
+type ResourceKey struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// KeyOf returns the ResourceKey of a resource
+func KeyOf(resource map[string]any) ResourceKey {
+	key := ResourceKey{}
+	key.Kind, _ = resource["kind"].(string)
+	if metadata, ok := resource["metadata"].(map[string]any); ok {
+		key.Namespace, _ = metadata["namespace"].(string)
+		key.Name, _ = metadata["name"].(string)
+	}
+	return key
+}
+
+// String formats the key as kind/name, or kind/namespace/name for namespaced resources
+func (k ResourceKey) String() string {
+	if k.Namespace == "" {
+		return fmt.Sprintf("%s/%s", k.Kind, k.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", k.Kind, k.Namespace, k.Name)
+}
+
+// ParseKey parses a key formatted like String
+func ParseKey(s string) (ResourceKey, error) {
+	parts := strings.Split(s, "/")
+	for _, part := range parts {
+		if part == "" {
+			parts = nil
+		}
+	}
+	switch len(parts) {
+	case 2:
+		return ResourceKey{Kind: parts[0], Name: parts[1]}, nil
+	case 3:
+		return ResourceKey{Kind: parts[0], Namespace: parts[1], Name: parts[2]}, nil
+	default:
+		return ResourceKey{}, fmt.Errorf("invalid resource %q, must be kind/name or kind/namespace/name", s)
+	}
+}
+
+// Sources returns the template each resource of a rendered manifest comes from, read from
+// the "# Source:" comments Helm writes at the top of every document
+func Sources(data []byte) map[ResourceKey]string {
+	sources := map[ResourceKey]string{}
+
+	var doc bytes.Buffer
+	source := ""
+	flush := func() {
+		var resource map[string]any
+		if source != "" && yaml.Unmarshal(doc.Bytes(), &resource) == nil && resource != nil {
+			sources[KeyOf(resource)] = source
+		}
+		doc.Reset()
+		source = ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "---"):
+			flush()
+		case source == "" && strings.HasPrefix(line, sourcePrefix):
+			source = strings.TrimSpace(strings.TrimPrefix(line, sourcePrefix))
+		default:
+			doc.WriteString(line)
+			doc.WriteByte('\n')
+		}
+	}
+	flush()
+
+	return sources
+}
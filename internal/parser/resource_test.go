@@ -0,0 +1,107 @@
+package parser
+
+import "testing"
+
+func TestKeyOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource map[string]any
+		want     string
+	}{
+		{
+			name: "namespaced",
+			resource: map[string]any{
+				"kind":     "Deployment",
+				"metadata": map[string]any{"name": "app", "namespace": "prod"},
+			},
+			want: "Deployment/prod/app",
+		},
+		{
+			name: "cluster-scoped",
+			resource: map[string]any{
+				"kind":     "ClusterRole",
+				"metadata": map[string]any{"name": "reader"},
+			},
+			want: "ClusterRole/reader",
+		},
+		{
+			name:     "no metadata",
+			resource: map[string]any{"kind": "List"},
+			want:     "List/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KeyOf(tt.resource).String(); got != tt.want {
+				t.Errorf("KeyOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ResourceKey
+		wantErr bool
+	}{
+		{input: "Deployment/app", want: ResourceKey{Kind: "Deployment", Name: "app"}},
+		{input: "Deployment/prod/app", want: ResourceKey{Kind: "Deployment", Namespace: "prod", Name: "app"}},
+		{input: "Deployment", wantErr: true},
+		{input: "Deployment/", wantErr: true},
+		{input: "a/b/c/d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseKey(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseKey() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSources(t *testing.T) {
+	manifest := `---
+# Source: app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+# Source: app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+spec:
+  template: |
+    ---
+    not a separator
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: untracked
+`
+
+	sources := Sources([]byte(manifest))
+	want := map[string]string{
+		"Service/web":         "app/templates/service.yaml",
+		"Deployment/prod/web": "app/templates/deployment.yaml",
+	}
+	if len(sources) != len(want) {
+		t.Errorf("Sources() = %v, want %v", sources, want)
+	}
+	for key, source := range sources {
+		if want[key.String()] != source {
+			t.Errorf("Sources()[%s] = %q, want %q", key, source, want[key.String()])
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// SchemaID identifies the JSON Schema of KustomizePluginData
+const SchemaID = "https://github.com/owhelm/helm-kustomize/schema/kustomizeplugindata-v1.json"
+
+// Schema returns the JSON Schema of the KustomizePluginData resource. It's generated from
+// the KustomizePluginData type, so new fields are part of it as soon as they're declared.
+// Fields without omitempty are required, and unknown fields are rejected.
+func Schema() map[string]any {
+	schema := schemaOf(reflect.TypeFor[KustomizePluginData]())
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["$id"] = SchemaID
+	schema["title"] = Kind
+	schema["description"] = "Kustomize files embedded in a Helm chart for the helm-kustomize post-renderer"
+
+	properties := schema["properties"].(map[string]any)
+	properties["apiVersion"] = map[string]any{"const": APIVersion}
+	properties["kind"] = map[string]any{"const": Kind}
+	// Standard Kubernetes object metadata, which the plugin doesn't use
+	properties["metadata"] = map[string]any{"type": "object"}
+	schema["anyOf"] = []any{
+		map[string]any{"required": []any{"files"}},
+		map[string]any{"required": []any{"kustomization"}},
+	}
+	return schema
+}
+
+// schemaOf returns the schema of values of type t, as decoded from YAML
+func schemaOf(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.Struct:
+		properties := map[string]any{}
+		required := []string{}
+		addFields(t, properties, &required)
+		return map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+// addFields adds the schema of every field of the struct type t to properties. Inline
+// fields add their own fields, and fields without omitempty are required.
+func addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("yaml")
+		if !ok {
+			// Types of the kustomize API are only tagged for JSON in places
+			tag = field.Tag.Get("json")
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		switch {
+		case name == "" && (field.Anonymous || strings.Contains(options, "inline")):
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			addFields(fieldType, properties, required)
+			continue
+		case !field.IsExported() || name == "-" || name == "":
+			continue
+		}
+		properties[name] = schemaOf(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// compiledSchema is the compiled Schema, shared by every validation
+var compiledSchema = sync.OnceValues(func() (*jsonschema.Schema, error) {
+	schema, err := toJSONValue(Schema())
+	if err != nil {
+		return nil, err
+	}
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(SchemaID, schema); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(SchemaID)
+})
+
+// validateSchema checks a KustomizePluginData document against its JSON Schema. The error
+// lists every problem, sorted by the JSON pointer of the value it concerns.
+func validateSchema(doc map[string]any) error {
+	schema, err := compiledSchema()
+	if err != nil {
+		return fmt.Errorf("invalid KustomizePluginData schema: %w", err)
+	}
+
+	instance, err := toJSONValue(doc)
+	if err != nil {
+		return fmt.Errorf("KustomizePluginData can't be represented as JSON: %w", err)
+	}
+
+	err = schema.Validate(instance)
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+	printer := message.NewPrinter(language.English)
+	var problems []string
+	for _, leaf := range leafErrors(validationErr) {
+		problems = append(problems, fmt.Sprintf("at %q: %s", pointer(leaf.InstanceLocation), leaf.ErrorKind.LocalizedString(printer)))
+	}
+	slices.Sort(problems)
+	return fmt.Errorf("invalid KustomizePluginData: %s", strings.Join(problems, "; "))
+}
+
+// toJSONValue converts value to the types of encoding/json, which the validator expects
+func toJSONValue(value any) (any, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return jsonschema.UnmarshalJSON(bytes.NewReader(content))
+}
+
+// leafErrors returns the errors without causes, which describe the actual problems
+func leafErrors(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, leafErrors(cause)...)
+	}
+	return leaves
+}
+
+// pointer formats the location of a value as a JSON pointer (RFC 6901)
+func pointer(location []string) string {
+	var sb strings.Builder
+	for _, token := range location {
+		sb.WriteString("/")
+		sb.WriteString(strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
+	}
+	return sb.String()
+}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	schema := Schema()
+
+	properties := schema["properties"].(map[string]any)
+	// Every field of the type is described, with the metadata of Kubernetes objects
+	typ := reflect.TypeFor[KustomizePluginData]()
+	for i := range typ.NumField() {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if _, ok := properties[name]; !ok {
+			t.Errorf("Schema has no property %q", name)
+		}
+	}
+	if _, ok := properties["metadata"]; !ok {
+		t.Error("Schema has no metadata property")
+	}
+
+	if got := schema["required"].([]string); !slices.Equal(got, []string{"apiVersion", "kind"}) {
+		t.Errorf("required = %v, want apiVersion and kind", got)
+	}
+	// Either files or a structured kustomization is required
+	if got := len(schema["anyOf"].([]any)); got != 2 {
+		t.Errorf("anyOf has %d schemas, want 2", got)
+	}
+	kustomization := properties["kustomization"].(map[string]any)["properties"].(map[string]any)
+	for _, name := range []string{"resources", "patches", "images", "labels", "replacements"} {
+		if _, ok := kustomization[name]; !ok {
+			t.Errorf("kustomization schema has no property %q", name)
+		}
+	}
+	if got := properties["binaryFiles"]; !reflect.DeepEqual(got, map[string]any{
+		"type":                 "object",
+		"additionalProperties": map[string]any{"type": "string", "contentEncoding": "base64"},
+	}) {
+		t.Errorf("binaryFiles = %v, want a map of base64 strings", got)
+	}
+	component := properties["components"].(map[string]any)["items"].(map[string]any)
+	if got := component["required"].([]string); !slices.Equal(got, []string{"name", "path"}) {
+		t.Errorf("components required = %v, want name and path", got)
+	}
+}
+
+func TestParseManifests_KustomizePluginData_Schema(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  string
+		wantErr string
+	}{
+		{name: "unknown field", fields: "compnents: []", wantErr: `invalid KustomizePluginData: at "": additional properties 'compnents' not allowed`},
+		{name: "unknown component field", fields: "components:\n- {name: a, path: a, enable: true}", wantErr: `at "/components/0": additional properties 'enable' not allowed`},
+		{name: "escaped pointer", fields: "binaryFiles:\n  a/b~c: true", wantErr: `at "/binaryFiles/a~1b~0c": got boolean, want string`},
+		{
+			name:    "every problem",
+			fields:  "options: []\ncomponents: {}",
+			wantErr: `at "/components": got object, want array; at "/options": got array, want object`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifests([]byte(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+metadata:
+  name: kustomize-files
+files:
+  kustomization.yaml: "resources: []"
+` + tt.fields + "\n"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseManifests() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
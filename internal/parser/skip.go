@@ -0,0 +1,26 @@
+package parser
+
+// SkipAnnotation marks a resource, when set to "true", to be left out of the kustomize build
+// and added to its output unchanged, e.g. a large custom resource kustomize would reformat
+const SkipAnnotation = "helm-kustomize.owhelm.io/skip"
+
+// skipResources moves the resources annotated with SkipAnnotation from result.OtherResources
+// to result.Skipped, without the annotation
+func skipResources(result *ParseResult) {
+	kept := result.OtherResources[:0]
+	for _, resource := range result.OtherResources {
+		metadata, _ := resource["metadata"].(map[string]any)
+		annotations, _ := metadata["annotations"].(map[string]any)
+		if value, ok := annotations[SkipAnnotation].(string); !ok || value != "true" {
+			kept = append(kept, resource)
+			continue
+		}
+
+		delete(annotations, SkipAnnotation)
+		if len(annotations) == 0 {
+			delete(metadata, "annotations")
+		}
+		result.Skipped = append(result.Skipped, resource)
+	}
+	result.OtherResources = kept
+}
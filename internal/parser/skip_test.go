@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseManifests_Skip(t *testing.T) {
+	input := []byte(`---
+apiVersion: example.com/v1
+kind: Dashboard
+metadata:
+  name: web
+  annotations:
+    helm-kustomize.owhelm.io/skip: "true"
+spec:
+  json: '{"panels": []}'
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+  annotations:
+    helm-kustomize.owhelm.io/skip: "false"
+    team: web
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: "resources: []"
+`)
+
+	result, err := ParseManifests(input)
+	if err != nil {
+		t.Fatalf("ParseManifests() error = %v", err)
+	}
+
+	want := []map[string]any{{
+		"apiVersion": "example.com/v1",
+		"kind":       "Dashboard",
+		"metadata":   map[string]any{"name": "web"},
+		"spec":       map[string]any{"json": `{"panels": []}`},
+	}}
+	if !reflect.DeepEqual(result.Skipped, want) {
+		t.Errorf("Skipped = %v, want %v", result.Skipped, want)
+	}
+	if len(result.OtherResources) != 1 || KeyOf(result.OtherResources[0]).Kind != "ConfigMap" {
+		t.Errorf("OtherResources = %v, want only the ConfigMap", result.OtherResources)
+	}
+}
+
+func TestParseManifests_SkipWithoutData(t *testing.T) {
+	// Without KustomizePluginData the input passes through, annotation included
+	result, err := ParseManifests([]byte(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+  annotations:
+    helm-kustomize.owhelm.io/skip: "true"
+`))
+	if err != nil {
+		t.Fatalf("ParseManifests() error = %v", err)
+	}
+	if len(result.Skipped) != 0 || len(result.OtherResources) != 1 {
+		t.Errorf("Skipped = %v, want no skipped resources", result.Skipped)
+	}
+}
@@ -0,0 +1,203 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// Strategic returns the strategic merge patch turning before into after, or nil if they're
+// equal. Lists of built-in kinds are merged by their merge keys, such as the name of a
+// container; other kinds get a JSON merge patch, which replaces lists. The patch names the
+// resource by its apiVersion, kind and metadata of before.
+func Strategic(before, after map[string]any) (map[string]any, error) {
+	original, err := json.Marshal(before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal resource: %w", err)
+	}
+	modified, err := json.Marshal(after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal resource: %w", err)
+	}
+
+	var patch map[string]any
+	if obj, err := scheme.Scheme.New(groupVersionKind(before)); err == nil {
+		content, err := strategicpatch.CreateTwoWayMergePatch(original, modified, obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create strategic merge patch: %w", err)
+		}
+		if err := json.Unmarshal(content, &patch); err != nil {
+			return nil, fmt.Errorf("failed to create strategic merge patch: %w", err)
+		}
+		stripElementOrder(patch)
+	} else {
+		patch, _ = mergePatch(before, after).(map[string]any)
+	}
+	if len(patch) == 0 {
+		return nil, nil
+	}
+
+	// Identify the resource, as kustomize expects of strategic merge patches
+	patch["apiVersion"] = before["apiVersion"]
+	patch["kind"] = before["kind"]
+	metadata, _ := patch["metadata"].(map[string]any)
+	if metadata == nil {
+		metadata = map[string]any{}
+		patch["metadata"] = metadata
+	}
+	beforeMetadata, _ := before["metadata"].(map[string]any)
+	for _, field := range []string{"name", "namespace"} {
+		if value, ok := beforeMetadata[field]; ok {
+			metadata[field] = value
+		}
+	}
+	return patch, nil
+}
+
+// stripElementOrder removes the $setElementOrder directives of a strategic merge patch,
+// which keep the order of merged lists but make patches harder to read and maintain
+func stripElementOrder(value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if strings.HasPrefix(key, "$setElementOrder/") {
+				delete(v, key)
+				continue
+			}
+			stripElementOrder(item)
+		}
+	case []any:
+		for _, item := range v {
+			stripElementOrder(item)
+		}
+	}
+}
+
+// groupVersionKind returns the type of a resource
+func groupVersionKind(resource map[string]any) schema.GroupVersionKind {
+	apiVersion, _ := resource["apiVersion"].(string)
+	kind, _ := resource["kind"].(string)
+	return schema.FromAPIVersionAndKind(apiVersion, kind)
+}
+
+// mergePatch returns the JSON merge patch (RFC 7386) turning before into after
+func mergePatch(before, after any) any {
+	beforeMap, ok := before.(map[string]any)
+	afterMap, ok2 := after.(map[string]any)
+	if !ok || !ok2 {
+		return after
+	}
+
+	patch := map[string]any{}
+	for key, value := range afterMap {
+		old, exists := beforeMap[key]
+		switch {
+		case !exists:
+			patch[key] = value
+		case !reflect.DeepEqual(old, value):
+			patch[key] = mergePatch(old, value)
+		}
+	}
+	for key := range beforeMap {
+		if _, exists := afterMap[key]; !exists {
+			patch[key] = nil
+		}
+	}
+	return patch
+}
+
+// Operation is a JSON patch (RFC 6902) operation
+type Operation struct {
+	Op    string
+	Path  string
+	Value any
+}
+
+// MarshalYAML writes the operation without a value for remove operations, since a nil
+// value is a valid one for add and replace
+func (o Operation) MarshalYAML() (any, error) {
+	raw := map[string]any{"op": o.Op, "path": o.Path}
+	if o.Op != "remove" {
+		raw["value"] = o.Value
+	}
+	return raw, nil
+}
+
+// JSON6902 returns the JSON patch operations turning before into after. Lists are patched
+// item by item when items are only changed, appended or removed at the end, and replaced
+// otherwise.
+func JSON6902(before, after any) []Operation {
+	var ops []Operation
+	diff6902("", before, after, &ops)
+	return ops
+}
+
+// diff6902 appends the operations turning before into after at path to ops
+func diff6902(path string, before, after any, ops *[]Operation) {
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+
+	switch b := before.(type) {
+	case map[string]any:
+		a, ok := after.(map[string]any)
+		if !ok {
+			break
+		}
+		for _, key := range slices.Sorted(maps.Keys(b)) {
+			if _, exists := a[key]; !exists {
+				*ops = append(*ops, Operation{Op: "remove", Path: path + "/" + escape(key)})
+			}
+		}
+		for _, key := range slices.Sorted(maps.Keys(a)) {
+			if old, exists := b[key]; exists {
+				diff6902(path+"/"+escape(key), old, a[key], ops)
+			} else {
+				*ops = append(*ops, Operation{Op: "add", Path: path + "/" + escape(key), Value: a[key]})
+			}
+		}
+		return
+	case []any:
+		a, ok := after.([]any)
+		if !ok {
+			break
+		}
+		common := min(len(a), len(b))
+		// A list with every item changed and items added or removed, such as after an insertion
+		// at the front, is replaced
+		changed := 0
+		for i := range common {
+			if !reflect.DeepEqual(b[i], a[i]) {
+				changed++
+			}
+		}
+		if common > 0 && changed == common && len(a) != len(b) {
+			break
+		}
+		for i := range common {
+			diff6902(path+"/"+strconv.Itoa(i), b[i], a[i], ops)
+		}
+		for i := len(b) - 1; i >= len(a); i-- {
+			*ops = append(*ops, Operation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+		}
+		for i := len(b); i < len(a); i++ {
+			*ops = append(*ops, Operation{Op: "add", Path: path + "/-", Value: a[i]})
+		}
+		return
+	}
+
+	*ops = append(*ops, Operation{Op: "replace", Path: path, Value: after})
+}
+
+// escape escapes a key for a JSON pointer (RFC 6901)
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,156 @@
+package patch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.yaml.in/yaml/v4"
+)
+
+func parse(t *testing.T, content string) map[string]any {
+	t.Helper()
+	var resource map[string]any
+	if err := yaml.Unmarshal([]byte(content), &resource); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	return resource
+}
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx:1.25
+        env:
+        - name: MODE
+          value: dev
+      - name: sidecar
+        image: envoy
+`
+
+func TestStrategic(t *testing.T) {
+	before := parse(t, deployment)
+	after := parse(t, strings.NewReplacer("replicas: 1", "replicas: 3", "value: dev", "value: prod", "    app: web\n", "").Replace(deployment))
+
+	got, err := Strategic(before, after)
+	if err != nil {
+		t.Fatalf("Strategic() error = %v", err)
+	}
+	want := parse(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels: null
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: app
+        env:
+        - name: MODE
+          value: prod
+`)
+	if !reflect.DeepEqual(normalize(t, got), normalize(t, want)) {
+		out, _ := yaml.Marshal(got)
+		t.Errorf("Strategic() =\n%s", out)
+	}
+
+	if got, err := Strategic(before, before); err != nil || got != nil {
+		t.Errorf("Strategic() of equal resources = %v, %v, want nil", got, err)
+	}
+}
+
+func TestStrategic_CustomResource(t *testing.T) {
+	before := parse(t, "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\n  namespace: ns\nspec:\n  sizes: [1, 2]\n  color: red\n")
+	after := parse(t, "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\n  namespace: ns\nspec:\n  sizes: [1, 2, 3]\n  color: red\n")
+
+	got, err := Strategic(before, after)
+	if err != nil {
+		t.Fatalf("Strategic() error = %v", err)
+	}
+	want := parse(t, "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\n  namespace: ns\nspec:\n  sizes: [1, 2, 3]\n")
+	if !reflect.DeepEqual(normalize(t, got), normalize(t, want)) {
+		t.Errorf("Strategic() = %v, want %v", got, want)
+	}
+}
+
+func TestJSON6902(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   []Operation
+	}{
+		{
+			name:   "change, add and remove keys",
+			before: "spec:\n  replicas: 1\n  paused: true\n",
+			after:  "spec:\n  replicas: 3\n  a/b: x\n",
+			want: []Operation{
+				{Op: "remove", Path: "/spec/paused"},
+				{Op: "add", Path: "/spec/a~1b", Value: "x"},
+				{Op: "replace", Path: "/spec/replicas", Value: 3},
+			},
+		},
+		{
+			name:   "list items",
+			before: "args: [a, b, c]\n",
+			after:  "args: [a, B]\n",
+			want: []Operation{
+				{Op: "replace", Path: "/args/1", Value: "B"},
+				{Op: "remove", Path: "/args/2"},
+			},
+		},
+		{
+			name:   "appended items",
+			before: "args: [a]\n",
+			after:  "args: [a, b]\n",
+			want:   []Operation{{Op: "add", Path: "/args/-", Value: "b"}},
+		},
+		{
+			name:   "inserted item",
+			before: "args: [a, b]\n",
+			after:  "args: [x, a, b]\n",
+			want:   []Operation{{Op: "replace", Path: "/args", Value: []any{"x", "a", "b"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := JSON6902(parse(t, tt.before), parse(t, tt.after))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JSON6902() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOperation_MarshalYAML(t *testing.T) {
+	out, err := yaml.Marshal([]Operation{{Op: "remove", Path: "/a"}, {Op: "add", Path: "/b", Value: nil}})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := "- op: remove\n  path: /a\n- op: add\n  path: /b\n  value: null\n"
+	if string(out) != want {
+		t.Errorf("Marshal() =\n%s\nwant\n%s", out, want)
+	}
+}
+
+// normalize round-trips a value through YAML, so numbers have the same types
+func normalize(t *testing.T, value map[string]any) map[string]any {
+	t.Helper()
+	out, err := yaml.Marshal(value)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	return parse(t, string(out))
+}
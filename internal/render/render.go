@@ -0,0 +1,121 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/chart"
+	"helm.sh/helm/v4/pkg/chart/common"
+	"helm.sh/helm/v4/pkg/chart/loader"
+	"helm.sh/helm/v4/pkg/cli/values"
+	"helm.sh/helm/v4/pkg/getter"
+	"helm.sh/helm/v4/pkg/postrenderer"
+	release "helm.sh/helm/v4/pkg/release/v1"
+)
+
+const (
+	// DefaultReleaseName is the release name used when none is given, as in helm template
+	DefaultReleaseName = "release-name"
+	// DefaultNamespace is the namespace used when none is given
+	DefaultNamespace = "default"
+)
+
+// Options configures how a chart is rendered
+type Options struct {
+	ReleaseName string
+	Namespace   string
+	// Values are merged the same way as helm's -f and --set flags. Only local files are supported.
+	Values values.Options
+	// KubeVersion overrides the Kubernetes version reported to templates, e.g. "1.31.0"
+	KubeVersion string
+	IncludeCRDs bool
+	// SkipHooks leaves hook resources out of the output
+	SkipHooks bool
+	// PostRenderer, if set, receives the rendered manifests before they are split into
+	// hooks and resources, as with helm's --post-renderer
+	PostRenderer postrenderer.PostRenderer
+}
+
+// IsChart reports whether path is a chart directory or a chart archive
+func IsChart(path string) bool {
+	if strings.HasSuffix(path, ".tgz") || strings.HasSuffix(path, ".tar.gz") {
+		return true
+	}
+	info, err := os.Stat(filepath.Join(path, "Chart.yaml"))
+	return err == nil && !info.IsDir()
+}
+
+// Chart loads the chart directory or archive at chartPath and renders it client-side,
+// without a cluster, as helm template does. Hooks follow the resources, each preceded
+// by a "# Source:" comment.
+func Chart(ctx context.Context, chartPath string, opts Options) ([]byte, error) {
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart: %w", err)
+	}
+
+	accessor, err := chart.NewAccessor(chrt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart: %w", err)
+	}
+	if accessor.IsLibraryChart() {
+		return nil, fmt.Errorf("%s is a library chart, which can't be rendered", accessor.Name())
+	}
+	if deps := accessor.MetaDependencies(); deps != nil {
+		if err := action.CheckDependencies(chrt, deps); err != nil {
+			return nil, fmt.Errorf("missing chart dependencies, run helm dependency build: %w", err)
+		}
+	}
+
+	vals, err := opts.Values.MergeValues(getter.Providers{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values: %w", err)
+	}
+
+	client := action.NewInstall(action.NewConfiguration())
+	client.DryRunStrategy = action.DryRunClient
+	client.ReleaseName = opts.ReleaseName
+	if client.ReleaseName == "" {
+		client.ReleaseName = DefaultReleaseName
+	}
+	client.Namespace = opts.Namespace
+	if client.Namespace == "" {
+		client.Namespace = DefaultNamespace
+	}
+	// Skip the check for an existing release with the same name
+	client.Replace = true
+	client.IncludeCRDs = opts.IncludeCRDs
+	client.DisableHooks = opts.SkipHooks
+	client.PostRenderer = opts.PostRenderer
+
+	if opts.KubeVersion != "" {
+		kubeVersion, err := common.ParseKubeVersion(opts.KubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kube version %q: %w", opts.KubeVersion, err)
+		}
+		client.KubeVersion = kubeVersion
+	}
+
+	releaser, err := client.RunWithContext(ctx, chrt, vals)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render chart: %w", err)
+	}
+	rel, ok := releaser.(*release.Release)
+	if !ok {
+		return nil, fmt.Errorf("failed to render chart: unsupported release type %T", releaser)
+	}
+
+	var manifests bytes.Buffer
+	fmt.Fprintln(&manifests, strings.TrimSpace(rel.Manifest))
+	if !opts.SkipHooks {
+		for _, hook := range rel.Hooks {
+			fmt.Fprintf(&manifests, "---\n# Source: %s\n%s\n", hook.Path, hook.Manifest)
+		}
+	}
+	return manifests.Bytes(), nil
+}
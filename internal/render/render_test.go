@@ -0,0 +1,223 @@
+package render
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v4/pkg/cli/values"
+)
+
+// testChart is a minimal chart with a ConfigMap and a hook
+var testChart = map[string]string{
+	"Chart.yaml":  "apiVersion: v2\nname: demo\nversion: 0.1.0\n",
+	"values.yaml": "greeting: hello\n",
+	"templates/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-config
+  namespace: {{ .Release.Namespace }}
+data:
+  greeting: {{ .Values.greeting }}
+  kubeVersion: {{ .Capabilities.KubeVersion.Version }}
+`,
+	"templates/hook.yaml": `apiVersion: v1
+kind: Pod
+metadata:
+  name: {{ .Release.Name }}-test
+  annotations:
+    helm.sh/hook: test
+`,
+}
+
+func writeChart(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "demo")
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func writeChartArchive(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		header := &tar.Header{Name: "demo/" + name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "demo-0.1.0.tgz")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestIsChart(t *testing.T) {
+	chartDir := writeChart(t, testChart)
+	manifest := filepath.Join(t.TempDir(), "manifest.yaml")
+	if err := os.WriteFile(manifest, []byte("kind: ConfigMap\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: chartDir, want: true},
+		{path: "demo-0.1.0.tgz", want: true},
+		{path: "demo-0.1.0.tar.gz", want: true},
+		{path: manifest, want: false},
+		{path: t.TempDir(), want: false},
+		{path: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsChart(tt.path); got != tt.want {
+			t.Errorf("IsChart(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestChart(t *testing.T) {
+	tests := []struct {
+		name      string
+		chartPath func(t *testing.T) string
+	}{
+		{name: "directory", chartPath: func(t *testing.T) string { return writeChart(t, testChart) }},
+		{name: "archive", chartPath: func(t *testing.T) string { return writeChartArchive(t, testChart) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valuesFile := filepath.Join(t.TempDir(), "values.yaml")
+			if err := os.WriteFile(valuesFile, []byte("greeting: hi\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			output, err := Chart(context.Background(), tt.chartPath(t), Options{
+				ReleaseName: "demo",
+				Namespace:   "apps",
+				Values:      values.Options{ValueFiles: []string{valuesFile}, Values: []string{"greeting=hey"}},
+				KubeVersion: "1.31.0",
+			})
+			if err != nil {
+				t.Fatalf("Chart() error = %v, want nil", err)
+			}
+
+			for _, want := range []string{
+				"# Source: demo/templates/configmap.yaml",
+				"name: demo-config",
+				"namespace: apps",
+				"greeting: hey",
+				"kubeVersion: v1.31.0",
+				"---\n# Source: demo/templates/hook.yaml\n",
+				"name: demo-test",
+			} {
+				if !strings.Contains(string(output), want) {
+					t.Errorf("Chart() output is missing %q:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestChart_Defaults(t *testing.T) {
+	output, err := Chart(context.Background(), writeChart(t, testChart), Options{SkipHooks: true})
+	if err != nil {
+		t.Fatalf("Chart() error = %v, want nil", err)
+	}
+
+	for _, want := range []string{"name: release-name-config", "namespace: default", "greeting: hello"} {
+		if !strings.Contains(string(output), want) {
+			t.Errorf("Chart() output is missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(string(output), "release-name-test") {
+		t.Errorf("Chart() output contains the hook despite SkipHooks:\n%s", output)
+	}
+}
+
+// upperPostRenderer upper-cases the manifests it receives
+type upperPostRenderer struct {
+	received string
+}
+
+func (p *upperPostRenderer) Run(manifests *bytes.Buffer) (*bytes.Buffer, error) {
+	p.received = manifests.String()
+	return bytes.NewBufferString(strings.ReplaceAll(manifests.String(), "hello", "HELLO")), nil
+}
+
+func TestChart_PostRenderer(t *testing.T) {
+	postRenderer := &upperPostRenderer{}
+	output, err := Chart(context.Background(), writeChart(t, testChart), Options{PostRenderer: postRenderer})
+	if err != nil {
+		t.Fatalf("Chart() error = %v, want nil", err)
+	}
+
+	if !strings.Contains(postRenderer.received, "greeting: hello") {
+		t.Errorf("post-renderer received %q, want the rendered manifests", postRenderer.received)
+	}
+	if !strings.Contains(string(output), "greeting: HELLO") {
+		t.Errorf("Chart() output = %s, want the post-rendered manifests", output)
+	}
+}
+
+func TestChart_Errors(t *testing.T) {
+	library := map[string]string{"Chart.yaml": "apiVersion: v2\nname: lib\nversion: 0.1.0\ntype: library\n"}
+	dependent := map[string]string{
+		"Chart.yaml": "apiVersion: v2\nname: app\nversion: 0.1.0\ndependencies:\n- name: sub\n  version: 1.0.0\n  repository: https://example.com\n",
+	}
+
+	tests := []struct {
+		name      string
+		chartPath string
+		opts      Options
+		wantErr   string
+	}{
+		{name: "missing chart", chartPath: filepath.Join(t.TempDir(), "missing"), wantErr: "failed to load chart"},
+		{name: "library chart", chartPath: writeChart(t, library), wantErr: "lib is a library chart"},
+		{name: "missing dependency", chartPath: writeChart(t, dependent), wantErr: "missing chart dependencies"},
+		{name: "missing values file", chartPath: writeChart(t, testChart), opts: Options{Values: values.Options{ValueFiles: []string{"/missing/values.yaml"}}}, wantErr: "failed to read values"},
+		{name: "invalid kube version", chartPath: writeChart(t, testChart), opts: Options{KubeVersion: "latest"}, wantErr: `invalid kube version "latest"`},
+		{name: "template error", chartPath: writeChart(t, map[string]string{
+			"Chart.yaml":          testChart["Chart.yaml"],
+			"templates/bad.yaml":  "{{ required \"name is required\" .Values.name }}",
+			"templates/_help.tpl": "",
+		}), wantErr: "name is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Chart(context.Background(), tt.chartPath, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Chart() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+
+	"github.com/owhelm/helm-kustomize/internal/diff"
+	"github.com/owhelm/helm-kustomize/internal/parser"
+)
+
+// Change is how kustomize changed a resource
+type Change string
+
+const (
+	Added    Change = "added"
+	Removed  Change = "removed"
+	Renamed  Change = "renamed"
+	Modified Change = "modified"
+)
+
+// Entry is a resource changed by kustomize
+type Entry struct {
+	Change Change `json:"change"`
+	// Resource is the resource in the kustomize output, or in the Helm output if removed
+	Resource string `json:"resource"`
+	// Previous is the resource in the Helm output of a renamed resource
+	Previous string `json:"previous,omitempty"`
+	// Diff is the unified diff of a modified or renamed resource, empty if only renamed
+	Diff string `json:"diff,omitempty"`
+}
+
+// Summary counts the resources by change
+type Summary struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Renamed   int `json:"renamed"`
+	Modified  int `json:"modified"`
+	Unchanged int `json:"unchanged"`
+}
+
+// Report lists the changes kustomize made to the resources rendered by Helm
+type Report struct {
+	Summary   Summary `json:"summary"`
+	Resources []Entry `json:"resources"`
+}
+
+// Compare reports how the resources rendered by Helm, before, differ from the kustomize
+// output, after. Resources are matched by kind, namespace and name. A resource missing from
+// after is matched as renamed with an unmatched resource of the same kind whose name
+// contains its name, such as the one added by namePrefix or nameSuffix.
+func Compare(before, after []map[string]any) *Report {
+	r := &Report{Resources: []Entry{}}
+
+	pairs, added := match(before, after)
+	for _, p := range pairs {
+		if p.after == nil {
+			r.Resources = append(r.Resources, Entry{Change: Removed, Resource: parser.KeyOf(p.before).String()})
+			r.Summary.Removed++
+			continue
+		}
+		r.compare(p.before, p.after)
+	}
+
+	for _, resource := range added {
+		r.Resources = append(r.Resources, Entry{Change: Added, Resource: parser.KeyOf(resource).String()})
+		r.Summary.Added++
+	}
+
+	return r
+}
+
+// Rename is a resource rendered by Helm that kustomize renamed
+type Rename struct {
+	Previous parser.ResourceKey
+	Resource parser.ResourceKey
+}
+
+// Renames returns the resources of before renamed in after, matched like Compare matches
+// them but without diffing them, for callers that only need the names
+func Renames(before, after []map[string]any) []Rename {
+	pairs, _ := match(before, after)
+	var renames []Rename
+	for _, p := range pairs {
+		if p.after == nil {
+			continue
+		}
+		if previous, key := parser.KeyOf(p.before), parser.KeyOf(p.after); previous != key {
+			renames = append(renames, Rename{Previous: previous, Resource: key})
+		}
+	}
+	return renames
+}
+
+// pair is a resource rendered by Helm and its kustomized version, nil if it was removed
+type pair struct {
+	before, after map[string]any
+}
+
+// match pairs every resource of before with its version in after, as described in Compare:
+// first the resources with the same key, then the others in order. It also returns the
+// resources of after that match none of before.
+func match(before, after []map[string]any) ([]pair, []map[string]any) {
+	afterByKey := map[parser.ResourceKey]map[string]any{}
+	for _, resource := range after {
+		afterByKey[parser.KeyOf(resource)] = resource
+	}
+
+	var pairs []pair
+	matched := map[parser.ResourceKey]bool{}
+	var unmatched []map[string]any
+	for _, resource := range before {
+		key := parser.KeyOf(resource)
+		if other, ok := afterByKey[key]; ok {
+			matched[key] = true
+			pairs = append(pairs, pair{before: resource, after: other})
+			continue
+		}
+		unmatched = append(unmatched, resource)
+	}
+
+	var added []map[string]any
+	for _, resource := range after {
+		if !matched[parser.KeyOf(resource)] {
+			added = append(added, resource)
+		}
+	}
+
+	for _, resource := range unmatched {
+		i := renamedTo(resource, added)
+		if i < 0 {
+			pairs = append(pairs, pair{before: resource})
+			continue
+		}
+		pairs = append(pairs, pair{before: resource, after: added[i]})
+		added = append(added[:i], added[i+1:]...)
+	}
+
+	return pairs, added
+}
+
+// compare records the changes between a resource rendered by Helm and its kustomized version
+func (r *Report) compare(before, after map[string]any) {
+	beforeKey, afterKey := parser.KeyOf(before), parser.KeyOf(after)
+	renamed := beforeKey != afterKey
+	if !renamed && reflect.DeepEqual(before, after) {
+		r.Summary.Unchanged++
+		return
+	}
+
+	entry := Entry{Change: Modified, Resource: afterKey.String()}
+	if renamed {
+		entry.Change = Renamed
+		entry.Previous = beforeKey.String()
+		r.Summary.Renamed++
+	} else {
+		r.Summary.Modified++
+	}
+	if !reflect.DeepEqual(before, after) {
+		entry.Diff = diff.Unified(beforeKey.String()+" (helm)", afterKey.String()+" (kustomize)", encode(before), encode(after))
+	}
+	r.Resources = append(r.Resources, entry)
+}
+
+// renamedTo returns the index of the resource of candidates the resource was renamed to, or -1
+func renamedTo(resource map[string]any, candidates []map[string]any) int {
+	key := parser.KeyOf(resource)
+	best := -1
+	for i, candidate := range candidates {
+		other := parser.KeyOf(candidate)
+		if other.Kind != key.Kind || !strings.Contains(other.Name, key.Name) {
+			continue
+		}
+		// Prefer the closest name, e.g. app-web over app-web-canary for web
+		if best < 0 || len(other.Name) < len(parser.KeyOf(candidates[best]).Name) {
+			best = i
+		}
+	}
+	return best
+}
+
+// encode formats a resource as YAML for diffs
+func encode(resource map[string]any) string {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(resource); err != nil {
+		return fmt.Sprint(resource)
+	}
+	_ = encoder.Close()
+	return buf.String()
+}
+
+// Text formats the report for humans
+func (r *Report) Text() []byte {
+	var out bytes.Buffer
+	s := r.Summary
+	fmt.Fprintf(&out, "Change report: %d added, %d removed, %d renamed, %d modified, %d unchanged\n",
+		s.Added, s.Removed, s.Renamed, s.Modified, s.Unchanged)
+
+	for _, e := range r.Resources {
+		switch e.Change {
+		case Added:
+			fmt.Fprintf(&out, "+ %s\n", e.Resource)
+		case Removed:
+			fmt.Fprintf(&out, "- %s\n", e.Resource)
+		case Renamed:
+			fmt.Fprintf(&out, "> %s -> %s\n", e.Previous, e.Resource)
+		default:
+			fmt.Fprintf(&out, "~ %s\n", e.Resource)
+		}
+		out.WriteString(e.Diff)
+	}
+	return out.Bytes()
+}
+
+// JSON formats the report for tools
+func (r *Report) JSON() ([]byte, error) {
+	output, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal report: %w", err)
+	}
+	return append(output, '\n'), nil
+}
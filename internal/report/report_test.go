@@ -0,0 +1,134 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/owhelm/helm-kustomize/internal/parser"
+)
+
+func resource(kind, namespace, name string, spec map[string]any) map[string]any {
+	metadata := map[string]any{"name": name}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	r := map[string]any{"apiVersion": "v1", "kind": kind, "metadata": metadata}
+	if spec != nil {
+		r["spec"] = spec
+	}
+	return r
+}
+
+func TestCompare(t *testing.T) {
+	before := []map[string]any{
+		resource("Deployment", "", "web", map[string]any{"replicas": 1}),
+		resource("Service", "", "web", nil),
+		resource("ConfigMap", "", "settings", nil),
+		resource("Secret", "", "token", nil),
+	}
+	after := []map[string]any{
+		resource("Deployment", "", "web", map[string]any{"replicas": 3}),
+		resource("Service", "", "web", nil),
+		resource("ConfigMap", "", "settings-canary", nil),
+		resource("ConfigMap", "", "settings-v2", nil),
+		resource("PodDisruptionBudget", "", "web", nil),
+	}
+
+	r := Compare(before, after)
+
+	want := Summary{Added: 2, Removed: 1, Renamed: 1, Modified: 1, Unchanged: 1}
+	if r.Summary != want {
+		t.Errorf("Summary = %+v, want %+v", r.Summary, want)
+	}
+
+	var changes []string
+	for _, e := range r.Resources {
+		changes = append(changes, string(e.Change)+" "+e.Previous+" "+e.Resource)
+	}
+	wantChanges := []string{
+		"modified  Deployment/web",
+		"renamed ConfigMap/settings ConfigMap/settings-v2",
+		"removed  Secret/token",
+		"added  ConfigMap/settings-canary",
+		"added  PodDisruptionBudget/web",
+	}
+	if strings.Join(changes, "\n") != strings.Join(wantChanges, "\n") {
+		t.Errorf("Resources =\n%s\nwant\n%s", strings.Join(changes, "\n"), strings.Join(wantChanges, "\n"))
+	}
+
+	if diff := r.Resources[0].Diff; !strings.Contains(diff, "-  replicas: 1\n+  replicas: 3\n") ||
+		!strings.HasPrefix(diff, "--- Deployment/web (helm)\n+++ Deployment/web (kustomize)\n") {
+		t.Errorf("Diff =\n%s", diff)
+	}
+	if r.Resources[1].Diff == "" {
+		t.Error("Expected a diff for the renamed resource")
+	}
+}
+
+func TestRenames(t *testing.T) {
+	before := []map[string]any{
+		resource("Deployment", "", "web", map[string]any{"replicas": 1}),
+		resource("ConfigMap", "", "settings", nil),
+		resource("Secret", "", "token", nil),
+	}
+	after := []map[string]any{
+		resource("Deployment", "", "web", map[string]any{"replicas": 3}),
+		resource("ConfigMap", "", "settings-canary", nil),
+		resource("ConfigMap", "", "settings-v2", nil),
+	}
+
+	renames := Renames(before, after)
+	want := Rename{
+		Previous: parser.ResourceKey{Kind: "ConfigMap", Name: "settings"},
+		Resource: parser.ResourceKey{Kind: "ConfigMap", Name: "settings-v2"},
+	}
+	if len(renames) != 1 || renames[0] != want {
+		t.Errorf("Renames() = %v, want [%v]", renames, want)
+	}
+}
+
+func TestCompare_RenamedOnly(t *testing.T) {
+	r := Compare(
+		[]map[string]any{resource("Service", "default", "web", nil)},
+		[]map[string]any{resource("Service", "prod", "web", nil)},
+	)
+	if r.Summary.Renamed != 1 || r.Resources[0].Previous != "Service/default/web" || r.Resources[0].Resource != "Service/prod/web" {
+		t.Errorf("Compare() = %+v, want a rename to the prod namespace", r)
+	}
+}
+
+func TestReport_Text(t *testing.T) {
+	r := Compare(
+		[]map[string]any{resource("Service", "", "web", nil), resource("Secret", "", "token", nil)},
+		[]map[string]any{resource("Service", "", "pre-web", nil), resource("ConfigMap", "", "env", nil)},
+	)
+
+	want := "Change report: 1 added, 1 removed, 1 renamed, 0 modified, 0 unchanged\n" +
+		"> Service/web -> Service/pre-web\n" +
+		"--- Service/web (helm)\n+++ Service/pre-web (kustomize)\n" +
+		"@@ -1,4 +1,4 @@\n apiVersion: v1\n kind: Service\n metadata:\n-  name: web\n+  name: pre-web\n" +
+		"- Secret/token\n" +
+		"+ ConfigMap/env\n"
+	if got := string(r.Text()); got != want {
+		t.Errorf("Text() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestReport_JSON(t *testing.T) {
+	output, err := Compare(nil, nil).JSON()
+	if err != nil {
+		t.Fatalf("JSON() error = %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(output, &decoded); err != nil {
+		t.Fatalf("JSON() returned invalid JSON: %v", err)
+	}
+	if resources, ok := decoded["resources"].([]any); !ok || len(resources) != 0 {
+		t.Errorf("resources = %v, want an empty list", decoded["resources"])
+	}
+	if _, ok := decoded["summary"].(map[string]any); !ok {
+		t.Errorf("summary = %v, want an object", decoded["summary"])
+	}
+}
@@ -0,0 +1,224 @@
+package scaffold
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/owhelm/helm-kustomize/internal/kustomize"
+)
+
+const (
+	// KustomizationDir is the chart folder holding the embedded kustomize files
+	KustomizationDir = "kustomization"
+	// TemplatePath is the chart template embedding the kustomize files
+	TemplatePath = "templates/kustomize-files.yaml"
+)
+
+var (
+	//go:embed templates/kustomize-files.yaml
+	filesTemplate []byte
+
+	//go:embed templates/kustomization.yaml
+	starterKustomization []byte
+)
+
+// Options configures Init and Import
+type Options struct {
+	// Force overwrites a modified embedding template and, for Import, replaces an
+	// existing kustomization folder
+	Force bool
+}
+
+// Result lists what Init or Import did, with paths relative to the chart
+type Result struct {
+	Created  []string
+	Skipped  []string
+	Warnings []string
+}
+
+// Init prepares the chart in chartDir for the post-renderer: it creates a kustomization
+// folder with a starter kustomization.yaml unless the folder already has one, and writes
+// the template embedding the folder in the chart.
+func Init(chartDir string, opts Options) (*Result, error) {
+	if err := checkChart(chartDir); err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	starter := path.Join(KustomizationDir, "kustomization.yaml")
+	if dir := filepath.Join(chartDir, KustomizationDir); hasKustomizationFile(dir) {
+		result.Skipped = append(result.Skipped, starter)
+	} else {
+		if err := writeFile(chartDir, starter, starterKustomization, 0644); err != nil {
+			return nil, err
+		}
+		result.Created = append(result.Created, starter)
+	}
+
+	if err := writeTemplate(chartDir, opts, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Import copies the standalone kustomization in srcDir to the kustomization folder of the
+// chart in chartDir and writes the template embedding it. Hidden files and directories are
+// skipped. References to files outside srcDir are reported as warnings, since they won't
+// be embedded.
+func Import(srcDir, chartDir string, opts Options) (*Result, error) {
+	if err := checkChart(chartDir); err != nil {
+		return nil, err
+	}
+	if !hasKustomizationFile(srcDir) {
+		return nil, fmt.Errorf("%s has no kustomization.yaml", srcDir)
+	}
+
+	dest := filepath.Join(chartDir, KustomizationDir)
+	if entries, err := os.ReadDir(dest); err == nil && len(entries) > 0 {
+		if !opts.Force {
+			return nil, fmt.Errorf("%s already exists, use --force to replace it", KustomizationDir)
+		}
+		if err := os.RemoveAll(dest); err != nil {
+			return nil, fmt.Errorf("failed to replace %s: %w", KustomizationDir, err)
+		}
+	}
+
+	result := &Result{}
+	files := map[string]string{}
+	err := filepath.WalkDir(srcDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("skipped %s, which is not a regular file", rel))
+			return nil
+		}
+		if rel == "all.yaml" {
+			return fmt.Errorf("%s contains all.yaml, which is reserved for the manifests rendered by Helm", srcDir)
+		}
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filePath, err)
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		target := path.Join(KustomizationDir, rel)
+		if err := writeFile(chartDir, target, content, info.Mode().Perm()); err != nil {
+			return err
+		}
+		result.Created = append(result.Created, target)
+		files[rel] = string(content)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Warnings = append(result.Warnings, outsideReferences(files)...)
+
+	if err := writeTemplate(chartDir, opts, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// outsideReferences describes the references of the kustomization files to files that
+// weren't copied
+func outsideReferences(files map[string]string) []string {
+	var warnings []string
+	for name, content := range files {
+		if !kustomize.IsKustomizationFile(name) {
+			continue
+		}
+		k, err := kustomize.ParseKustomization([]byte(content))
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+
+		for _, ref := range k.References() {
+			target := path.Join(path.Dir(name), ref)
+			if target == ".." || strings.HasPrefix(target, "../") {
+				warnings = append(warnings, fmt.Sprintf("%s references %q, which is outside the imported directory; copy it into %s and update the reference", name, ref, KustomizationDir))
+			}
+		}
+	}
+	return warnings
+}
+
+// writeTemplate writes the embedding template, keeping an identical one and refusing to
+// overwrite a modified one without Force
+func writeTemplate(chartDir string, opts Options, result *Result) error {
+	existing, err := os.ReadFile(filepath.Join(chartDir, filepath.FromSlash(TemplatePath)))
+	switch {
+	case err == nil && bytes.Equal(existing, filesTemplate):
+		result.Skipped = append(result.Skipped, TemplatePath)
+		return nil
+	case err == nil && !opts.Force:
+		return fmt.Errorf("%s already exists and differs from the generated template, use --force to overwrite it", TemplatePath)
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("failed to read %s: %w", TemplatePath, err)
+	}
+
+	if err := writeFile(chartDir, TemplatePath, filesTemplate, 0644); err != nil {
+		return err
+	}
+	result.Created = append(result.Created, TemplatePath)
+	return nil
+}
+
+// checkChart fails if dir isn't a chart directory
+func checkChart(dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, "Chart.yaml")); err != nil {
+		return fmt.Errorf("%s is not a chart: no Chart.yaml found", dir)
+	}
+	return nil
+}
+
+// hasKustomizationFile reports whether dir contains a kustomization file
+func hasKustomizationFile(dir string) bool {
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFile writes content to the slash-separated path name within dir
+func writeFile(dir, name string, content []byte, perm fs.FileMode) error {
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", name, err)
+	}
+	if err := os.WriteFile(target, content, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package scaffold
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/owhelm/helm-kustomize/internal/parser"
+	"github.com/owhelm/helm-kustomize/internal/render"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newChart(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Chart.yaml": "apiVersion: v2\nname: demo\nversion: 0.1.0\n"})
+	return dir
+}
+
+func TestInit(t *testing.T) {
+	chartDir := newChart(t)
+
+	result, err := Init(chartDir, Options{})
+	if err != nil {
+		t.Fatalf("Init() error = %v, want nil", err)
+	}
+	if !slices.Equal(result.Created, []string{"kustomization/kustomization.yaml", TemplatePath}) {
+		t.Errorf("Created = %v, want the starter kustomization and the template", result.Created)
+	}
+
+	// Running it again keeps everything
+	result, err = Init(chartDir, Options{})
+	if err != nil {
+		t.Fatalf("Init() error = %v, want nil", err)
+	}
+	if len(result.Created) != 0 || len(result.Skipped) != 2 {
+		t.Errorf("Init() = %+v, want everything kept", result)
+	}
+
+	// A modified template is only replaced with Force
+	template := filepath.Join(chartDir, filepath.FromSlash(TemplatePath))
+	if err := os.WriteFile(template, []byte("# custom\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Init(chartDir, Options{}); err == nil || !strings.Contains(err.Error(), "use --force to overwrite it") {
+		t.Errorf("Init() error = %v, want overwrite error", err)
+	}
+	if _, err := Init(chartDir, Options{Force: true}); err != nil {
+		t.Fatalf("Init() error = %v, want nil", err)
+	}
+	if content, _ := os.ReadFile(template); string(content) != string(filesTemplate) {
+		t.Errorf("template = %q, want the generated template", content)
+	}
+}
+
+func TestInit_NotAChart(t *testing.T) {
+	_, err := Init(t.TempDir(), Options{})
+	if err == nil || !strings.Contains(err.Error(), "is not a chart") {
+		t.Errorf("Init() error = %v, want not a chart error", err)
+	}
+}
+
+func TestTemplate_EmbedsFilesVerbatim(t *testing.T) {
+	chartDir := newChart(t)
+	files := map[string]string{
+		"kustomization.yaml":         "resources:\n  - all.yaml\n",
+		"patches/deep/nested.yaml":   "kind: Deployment\n",
+		"special.txt":                "key: \"quoted: value\"\n\ttabbed\n  leading spaces\n# not a comment\nunicode: héllo ✓\n{{ not a template }}\n",
+		"no-trailing-newline.txt":    "last line",
+		"spaces and: colons.yaml":    "a: b\n",
+		"empty.txt":                  "",
+		"components/x/Kustomization": "kind: Component\n",
+		"trailing-whitespace.txt":    "line   \n\n\n",
+		"windows.txt":                "a\r\nb\r\n",
+		"leading-newlines.txt":       "\n\nstart\n",
+		"document-markers.yaml":      "---\na: 1\n---\nb: 2\n...\n",
+		"indicator-chars.txt":        "- |\n> x\n? y\n@z\n",
+		"line-separator.txt":         "non\u00a0breaking\u2028separator\n",
+		"binary.dat":                 "a\x00b\xffc",
+		"invalid-utf8.txt":           "caf\xe9\n",
+	}
+	writeFiles(t, filepath.Join(chartDir, KustomizationDir), files)
+
+	if _, err := Init(chartDir, Options{}); err != nil {
+		t.Fatalf("Init() error = %v, want nil", err)
+	}
+
+	output, err := render.Chart(context.Background(), chartDir, render.Options{})
+	if err != nil {
+		t.Fatalf("render.Chart() error = %v, want nil", err)
+	}
+	result, err := parser.ParseManifests(output)
+	if err != nil {
+		t.Fatalf("ParseManifests() error = %v, want nil\n%s", err, output)
+	}
+	data := result.KustomizePluginData
+	if data == nil {
+		t.Fatalf("Expected a KustomizePluginData resource, got:\n%s", output)
+	}
+
+	// Files Helm would mangle as text are base64-encoded
+	wantBinary := []string{"binary.dat", "document-markers.yaml", "invalid-utf8.txt", "line-separator.txt"}
+
+	for name, want := range files {
+		got, ok := data.Files[name]
+		if binary, isBinary := data.BinaryFiles[name]; isBinary {
+			got, ok = string(binary), true
+			if !slices.Contains(wantBinary, name) {
+				t.Errorf("%s was embedded as a binary file, want text", name)
+			}
+		}
+		if !ok {
+			t.Errorf("%s is missing from the embedded files", name)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if len(data.BinaryFiles) != len(wantBinary) {
+		t.Errorf("BinaryFiles has %d files, want %v", len(data.BinaryFiles), wantBinary)
+	}
+}
+
+func TestImport(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"kustomization.yaml":    "resources:\n  - ../base\n  - https://example.com/remote.yaml\npatches:\n  - path: patches/replicas.yaml\n",
+		"patches/replicas.yaml": "kind: Deployment\n",
+		".git/config":           "[core]\n",
+		".hidden.yaml":          "a: b\n",
+	})
+	chartDir := newChart(t)
+
+	result, err := Import(src, chartDir, Options{})
+	if err != nil {
+		t.Fatalf("Import() error = %v, want nil", err)
+	}
+
+	wantCreated := []string{"kustomization/kustomization.yaml", "kustomization/patches/replicas.yaml", TemplatePath}
+	if !slices.Equal(result.Created, wantCreated) {
+		t.Errorf("Created = %v, want %v", result.Created, wantCreated)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], `references "../base", which is outside the imported directory`) {
+		t.Errorf("Warnings = %v, want one warning about ../base", result.Warnings)
+	}
+	if _, err := os.Stat(filepath.Join(chartDir, KustomizationDir, ".git")); err == nil {
+		t.Error("Expected hidden directories to be skipped")
+	}
+
+	// The folder now exists, so importing again requires Force
+	if _, err := Import(src, chartDir, Options{}); err == nil || !strings.Contains(err.Error(), "use --force to replace it") {
+		t.Errorf("Import() error = %v, want existing folder error", err)
+	}
+	writeFiles(t, filepath.Join(chartDir, KustomizationDir), map[string]string{"stale.yaml": "a: b\n"})
+	if _, err := Import(src, chartDir, Options{Force: true}); err != nil {
+		t.Fatalf("Import() error = %v, want nil", err)
+	}
+	if _, err := os.Stat(filepath.Join(chartDir, KustomizationDir, "stale.yaml")); err == nil {
+		t.Error("Expected --force to replace the kustomization folder")
+	}
+}
+
+func TestImport_Errors(t *testing.T) {
+	noKustomization := t.TempDir()
+	reserved := t.TempDir()
+	writeFiles(t, reserved, map[string]string{"kustomization.yaml": "resources: []\n", "all.yaml": "kind: List\n"})
+
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{name: "no kustomization", src: noKustomization, wantErr: "has no kustomization.yaml"},
+		{name: "reserved all.yaml", src: reserved, wantErr: "contains all.yaml, which is reserved"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Import(tt.src, newChart(t), Options{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Import() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
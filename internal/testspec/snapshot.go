@@ -0,0 +1,127 @@
+package testspec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+
+	"github.com/owhelm/helm-kustomize/internal/parser"
+)
+
+// Snapshot is a directory of expected manifests
+type Snapshot struct {
+	Resources []map[string]any
+	// Files maps every resource to the file of the directory holding it
+	Files map[parser.ResourceKey]string
+}
+
+// ReadSnapshot parses every YAML file in dir
+func ReadSnapshot(dir string) (*Snapshot, error) {
+	snapshot := &Snapshot{Files: map[parser.ResourceKey]string{}}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read expected output: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !isManifest(entry) {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read expected output: %w", err)
+		}
+
+		result, err := parser.ParseManifests(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", entry.Name(), err)
+		}
+		for _, resource := range result.OtherResources {
+			snapshot.Files[parser.KeyOf(resource)] = entry.Name()
+		}
+		snapshot.Resources = append(snapshot.Resources, result.OtherResources...)
+	}
+
+	return snapshot, nil
+}
+
+// WriteSnapshot replaces the expected manifests in dir with resources. Resources stay in
+// the file holding them, and new ones get a file named after their kind and name, e.g.
+// deployment-web.yaml. Files left without resources are removed.
+func WriteSnapshot(dir string, resources []map[string]any) error {
+	previous := &Snapshot{Files: map[parser.ResourceKey]string{}}
+	if _, err := os.Stat(dir); err == nil {
+		if previous, err = ReadSnapshot(dir); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read expected output: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create expected output: %w", err)
+	}
+
+	var files []string
+	byFile := map[string][]map[string]any{}
+	for _, resource := range resources {
+		key := parser.KeyOf(resource)
+		file, ok := previous.Files[key]
+		if !ok {
+			file = snapshotFileName(key)
+		}
+		if _, ok := byFile[file]; !ok {
+			files = append(files, file)
+		}
+		byFile[file] = append(byFile[file], resource)
+	}
+
+	for _, file := range files {
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		// Indent like kustomize, so unchanged snapshots are rewritten as they are
+		encoder.SetIndent(2)
+		encoder.CompactSeqIndent()
+		for _, resource := range byFile[file] {
+			if err := encoder.Encode(resource); err != nil {
+				return fmt.Errorf("failed to encode %s: %w", file, err)
+			}
+		}
+		if err := encoder.Close(); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", file, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write expected output: %w", err)
+		}
+	}
+
+	for _, file := range previous.Files {
+		if !slices.Contains(files, file) {
+			if err := os.Remove(filepath.Join(dir, file)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to remove %s: %w", file, err)
+			}
+		}
+	}
+	return nil
+}
+
+// snapshotFileName returns the file of a new resource in a snapshot
+func snapshotFileName(key parser.ResourceKey) string {
+	parts := []string{key.Kind, key.Namespace, key.Name}
+	parts = slices.DeleteFunc(parts, func(s string) bool { return s == "" })
+	return strings.ToLower(strings.Join(parts, "-")) + ".yaml"
+}
+
+// isManifest reports whether a directory entry is a YAML file
+func isManifest(entry fs.DirEntry) bool {
+	ext := filepath.Ext(entry.Name())
+	return !entry.IsDir() && (ext == ".yaml" || ext == ".yml")
+}
@@ -0,0 +1,71 @@
+package testspec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"web.yaml":  "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: old\n",
+		"gone.yaml": "apiVersion: v1\nkind: Secret\nmetadata:\n  name: gone\n",
+		"notes.txt": "kept\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resources := []map[string]any{
+		{"apiVersion": "v1", "kind": "Service", "metadata": map[string]any{"name": "web"}, "spec": map[string]any{"ports": []any{map[string]any{"port": 80}}}},
+		{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]any{"name": "web", "namespace": "prod"}},
+	}
+	if err := WriteSnapshot(dir, resources); err != nil {
+		t.Fatalf("WriteSnapshot() error = %v", err)
+	}
+
+	want := map[string]string{
+		"web.yaml":                 "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\nspec:\n  ports:\n  - port: 80\n",
+		"deployment-prod-web.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: prod\n",
+		"notes.txt":                "kept\n",
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		t.Errorf("WriteSnapshot() left %d files, want %d", len(entries), len(want))
+	}
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("Expected %s: %v", name, err)
+			continue
+		}
+		if string(got) != content {
+			t.Errorf("%s =\n%s\nwant\n%s", name, got, content)
+		}
+	}
+
+	snapshot, err := ReadSnapshot(dir)
+	if err != nil {
+		t.Fatalf("ReadSnapshot() error = %v", err)
+	}
+	if len(snapshot.Resources) != 2 {
+		t.Errorf("ReadSnapshot() = %d resources, want 2", len(snapshot.Resources))
+	}
+}
+
+func TestWriteSnapshot_NewDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "expected", "prod")
+	resources := []map[string]any{{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]any{"name": "settings"}}}
+	if err := WriteSnapshot(dir, resources); err != nil {
+		t.Fatalf("WriteSnapshot() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "configmap-settings.yaml")); err != nil {
+		t.Errorf("Expected the snapshot to be written: %v", err)
+	}
+}
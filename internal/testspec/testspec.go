@@ -0,0 +1,313 @@
+package testspec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+
+	"github.com/owhelm/helm-kustomize/internal/parser"
+)
+
+// Dir is the directory of a chart holding its test specs
+const Dir = "kustomize-tests"
+
+// Spec is a test of a chart's kustomization: the chart is rendered with the values of the
+// spec, and its output compared with a snapshot directory and checked by assertions. Specs
+// are hermetic: they only read files within the chart and the environment they declare.
+type Spec struct {
+	// Name defaults to the file name without its extension
+	Name string `yaml:"name"`
+	// File is the path of the spec file
+	File string `yaml:"-"`
+	// Values lists values files, relative to the chart
+	Values []string `yaml:"values"`
+	// Set holds values by dotted key, e.g. image.tag
+	Set map[string]any `yaml:"set"`
+	// Release and Namespace override the release name and namespace
+	Release   string `yaml:"release"`
+	Namespace string `yaml:"namespace"`
+	// Options holds post-renderer options by config file key, e.g. overlay
+	Options map[string]any `yaml:"options"`
+	// Env holds the environment variables the chart can substitute
+	Env map[string]string `yaml:"env"`
+	// Expected is the snapshot directory, relative to the chart
+	Expected string   `yaml:"expected"`
+	Asserts  []Assert `yaml:"asserts"`
+}
+
+// Discover reads the test specs of the chart in dir, sorted by file name. A chart without
+// specs has none.
+func Discover(dir string) ([]*Spec, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, Dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test specs: %w", err)
+	}
+
+	var specs []*Spec
+	for _, entry := range entries {
+		if !isManifest(entry) {
+			continue
+		}
+
+		file := filepath.Join(dir, Dir, entry.Name())
+		spec, err := Read(file)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// Read parses the test spec in file
+func Read(file string) (*Spec, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test spec: %w", err)
+	}
+
+	spec := &Spec{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(spec); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid test spec %s: %w", file, err)
+	}
+	spec.File = file
+	if spec.Name == "" {
+		spec.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if spec.Expected == "" && len(spec.Asserts) == 0 {
+		return nil, fmt.Errorf("invalid test spec %s: expected or asserts is required", file)
+	}
+	for i, name := range spec.Values {
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("invalid test spec %s: values[%d] %q must be a relative path within the chart", file, i, name)
+		}
+	}
+	if spec.Expected != "" && !filepath.IsLocal(spec.Expected) {
+		return nil, fmt.Errorf("invalid test spec %s: expected %q must be a relative path within the chart", file, spec.Expected)
+	}
+	for i := range spec.Asserts {
+		if err := spec.Asserts[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid test spec %s: asserts[%d]: %w", file, i, err)
+		}
+	}
+	return spec, nil
+}
+
+// JSONValues returns the set values in the key=json format of helm's --set-json
+func (s *Spec) JSONValues() ([]string, error) {
+	var values []string
+	for _, key := range slices.Sorted(maps.Keys(s.Set)) {
+		value, err := json.Marshal(s.Set[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %w", key, err)
+		}
+		values = append(values, key+"="+string(value))
+	}
+	return values, nil
+}
+
+// Assert checks a field of a resource, selected by a JSONPath expression such as
+// .spec.replicas. Exactly one of Equal, Exists and MatchRegex is set.
+type Assert struct {
+	// Resource is kind/name, which matches the resource in any namespace, or kind/namespace/name
+	Resource   string  `yaml:"resource"`
+	Path       string  `yaml:"path"`
+	Equal      *Value  `yaml:"-"`
+	Exists     *bool   `yaml:"exists"`
+	MatchRegex *string `yaml:"matchRegex"`
+
+	// regex is MatchRegex compiled by validate
+	regex *regexp.Regexp
+}
+
+// Value is the expected value of an equal assertion, which may be null
+type Value struct {
+	Value any
+}
+
+// UnmarshalYAML reads equal from the node, since a null value would otherwise leave it unset
+func (a *Assert) UnmarshalYAML(node *yaml.Node) error {
+	type fields Assert
+	var f fields
+	if err := node.Decode(&f); err != nil {
+		return err
+	}
+	*a = Assert(f)
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "equal" {
+			a.Equal = &Value{}
+			if err := node.Content[i+1].Decode(&a.Equal.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks that the assertion is complete, and compiles its regular expression
+func (a *Assert) validate() error {
+	if _, err := parser.ParseKey(a.Resource); err != nil {
+		return err
+	}
+	if a.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if _, err := parser.ParseJSONPath(a.Path); err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+
+	checks := 0
+	for _, set := range []bool{a.Equal != nil, a.Exists != nil, a.MatchRegex != nil} {
+		if set {
+			checks++
+		}
+	}
+	if checks != 1 {
+		return fmt.Errorf("exactly one of equal, exists and matchRegex is required")
+	}
+	if a.MatchRegex != nil {
+		regex, err := regexp.Compile(*a.MatchRegex)
+		if err != nil {
+			return fmt.Errorf("invalid matchRegex: %w", err)
+		}
+		a.regex = regex
+	}
+	return nil
+}
+
+// String describes the assertion, e.g. Deployment/web .spec.replicas
+func (a Assert) String() string {
+	return a.Resource + " " + a.Path
+}
+
+// Check returns an error describing why the assertion fails on resources, or nil
+func (a Assert) Check(resources []map[string]any) error {
+	key, err := parser.ParseKey(a.Resource)
+	if err != nil {
+		return err
+	}
+	resource, err := find(resources, key)
+	if err != nil {
+		return err
+	}
+
+	path, err := parser.ParseJSONPath(a.Path)
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+	values, err := parser.FindValues(path, resource)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate path: %w", err)
+	}
+
+	switch {
+	case a.Exists != nil:
+		if found := len(values) > 0; found != *a.Exists {
+			if found {
+				return fmt.Errorf("got %s, want no value", format(values[0]))
+			}
+			return fmt.Errorf("no value found")
+		}
+	case a.MatchRegex != nil:
+		if len(values) == 0 {
+			return fmt.Errorf("no value found, want a match of %s", *a.MatchRegex)
+		}
+		regex := a.regex
+		if regex == nil {
+			if regex, err = regexp.Compile(*a.MatchRegex); err != nil {
+				return fmt.Errorf("invalid matchRegex: %w", err)
+			}
+		}
+		for _, v := range values {
+			s, ok := v.(string)
+			if !ok {
+				s = format(v)
+			}
+			if !regex.MatchString(s) {
+				return fmt.Errorf("got %s, want a match of %s", format(v), *a.MatchRegex)
+			}
+		}
+	default:
+		var got any
+		switch len(values) {
+		case 0:
+			return fmt.Errorf("no value found, want %s", format(a.Equal.Value))
+		case 1:
+			got = values[0]
+		default:
+			got = values
+		}
+		if !reflect.DeepEqual(normalize(got), normalize(a.Equal.Value)) {
+			return fmt.Errorf("got %s, want %s", format(got), format(a.Equal.Value))
+		}
+	}
+	return nil
+}
+
+// find returns the resource with the kind and name of key, in its namespace unless it has none
+func find(resources []map[string]any, key parser.ResourceKey) (map[string]any, error) {
+	var found []map[string]any
+	var namespaces []string
+	for _, resource := range resources {
+		other := parser.KeyOf(resource)
+		if other.Kind != key.Kind || other.Name != key.Name {
+			continue
+		}
+		if other.Namespace == key.Namespace || key.Namespace == "" {
+			found = append(found, resource)
+		}
+		namespaces = append(namespaces, strconv.Quote(other.Namespace))
+	}
+
+	switch {
+	case len(found) == 1:
+		return found[0], nil
+	case len(found) > 1:
+		return nil, fmt.Errorf("resource found in namespaces %s, select one with kind/namespace/name", strings.Join(namespaces, ", "))
+	case len(namespaces) > 0:
+		return nil, fmt.Errorf("resource not found in the output, only in namespaces %s", strings.Join(namespaces, ", "))
+	}
+	return nil, fmt.Errorf("resource not found in the output")
+}
+
+// normalize converts a value to its JSON representation, so numbers compare equal
+// whatever their Go type
+func normalize(value any) any {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized any
+	if err := json.Unmarshal(content, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+// format prints a value for failure messages
+func format(value any) string {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(content)
+}
@@ -0,0 +1,185 @@
+package testspec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.yaml.in/yaml/v4"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    tier: frontend
+spec:
+  replicas: 3
+  paused: null
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx:1.27
+      - name: sidecar
+        image: envoy:1.30
+`
+
+func writeSpec(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "prod.yaml")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestRead(t *testing.T) {
+	spec, err := Read(writeSpec(t, `values: [values-prod.yaml]
+set:
+  image.tag: "1.27"
+  replicaCount: 2
+expected: expected/prod
+asserts:
+  - resource: Deployment/web
+    path: .spec.replicas
+    equal: 3
+`))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if spec.Name != "prod" || spec.Expected != "expected/prod" || len(spec.Asserts) != 1 {
+		t.Errorf("Read() = %+v", spec)
+	}
+
+	values, err := spec.JSONValues()
+	if err != nil {
+		t.Fatalf("JSONValues() error = %v", err)
+	}
+	if got := strings.Join(values, " "); got != `image.tag="1.27" replicaCount=2` {
+		t.Errorf("JSONValues() = %s", got)
+	}
+}
+
+func TestRead_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "nothing to check", content: "values: [a.yaml]\n", wantErr: "expected or asserts is required"},
+		{name: "unknown field", content: "expected: out\nexpect: out\n", wantErr: "field expect not found"},
+		{name: "invalid resource", content: "asserts:\n- resource: web\n  path: .a\n  exists: true\n", wantErr: "asserts[0]: invalid resource"},
+		{name: "missing path", content: "asserts:\n- resource: Deployment/web\n  exists: true\n", wantErr: "path is required"},
+		{name: "invalid path", content: "asserts:\n- resource: Deployment/web\n  path: .a[\n  exists: true\n", wantErr: "invalid path"},
+		{name: "two checks", content: "asserts:\n- resource: Deployment/web\n  path: .a\n  exists: true\n  equal: 1\n", wantErr: "exactly one of"},
+		{name: "invalid regex", content: "asserts:\n- resource: Deployment/web\n  path: .a\n  matchRegex: '('\n", wantErr: "invalid matchRegex"},
+		{name: "values outside the chart", content: "values: [../values.yaml]\nexpected: out\n", wantErr: `values[0] "../values.yaml" must be a relative path within the chart`},
+		{name: "absolute values", content: "values: [/etc/values.yaml]\nexpected: out\n", wantErr: "must be a relative path within the chart"},
+		{name: "expected outside the chart", content: "expected: ../out\n", wantErr: `expected "../out" must be a relative path within the chart`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(writeSpec(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Read() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAssert_Check(t *testing.T) {
+	var resource map[string]any
+	if err := yaml.Unmarshal([]byte(deployment), &resource); err != nil {
+		t.Fatal(err)
+	}
+	resources := []map[string]any{resource, {
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "settings", "namespace": "prod"},
+	}, {
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]any{"name": "token", "namespace": "prod"},
+	}, {
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]any{"name": "token", "namespace": "staging"},
+	}}
+
+	tests := []struct {
+		name    string
+		assert  string
+		wantErr string
+	}{
+		{name: "equal number", assert: "path: .spec.replicas\nequal: 3"},
+		{name: "equal with braces", assert: "path: '{.metadata.labels.tier}'\nequal: frontend"},
+		{name: "equal null", assert: "path: .spec.paused\nequal: null"},
+		{name: "equal map", assert: "path: .metadata.labels\nequal: {tier: frontend}"},
+		{name: "equal several values", assert: "path: .spec.template.spec.containers[*].name\nequal: [app, sidecar]"},
+		{name: "not equal", assert: "path: .spec.replicas\nequal: 5", wantErr: "got 3, want 5"},
+		{name: "equal missing", assert: "path: .spec.missing\nequal: 5", wantErr: "no value found, want 5"},
+		{name: "exists", assert: "path: .metadata.labels.tier\nexists: true"},
+		{name: "not exists", assert: "path: .metadata.annotations\nexists: false"},
+		{name: "exists missing", assert: "path: .metadata.annotations\nexists: true", wantErr: "no value found"},
+		{name: "unexpected", assert: "path: .spec.replicas\nexists: false", wantErr: "got 3, want no value"},
+		{name: "match", assert: "path: .spec.template.spec.containers[*].image\nmatchRegex: ':1\\.\\d+$'"},
+		{name: "no match", assert: "path: .spec.template.spec.containers[0].image\nmatchRegex: ^envoy", wantErr: `got "nginx:1.27", want a match of ^envoy`},
+		{name: "unknown resource", assert: "resource: Deployment/api\npath: .spec\nexists: true", wantErr: "resource not found in the output"},
+		{name: "any namespace", assert: "resource: ConfigMap/settings\npath: .metadata.namespace\nequal: prod"},
+		{name: "namespace", assert: "resource: Secret/staging/token\npath: .metadata.namespace\nequal: staging"},
+		{name: "other namespace", assert: "resource: ConfigMap/dev/settings\npath: .metadata\nexists: true", wantErr: `resource not found in the output, only in namespaces "prod"`},
+		{name: "several namespaces", assert: "resource: Secret/token\npath: .metadata\nexists: true", wantErr: `resource found in namespaces "prod", "staging", select one with kind/namespace/name`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := tt.assert
+			if !strings.Contains(content, "resource:") {
+				content = "resource: Deployment/web\n" + content
+			}
+			var a Assert
+			if err := yaml.Unmarshal([]byte(content), &a); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.validate(); err != nil {
+				t.Fatalf("validate() error = %v", err)
+			}
+
+			err := a.Check(resources)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Check() error = %v, want nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Check() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	chart := t.TempDir()
+	if specs, err := Discover(chart); err != nil || specs != nil {
+		t.Errorf("Discover() without specs = %v, %v, want none", specs, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(chart, Dir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"b.yaml": "name: second\nexpected: out\n", "a.yml": "expected: out\n", "README.md": "# tests\n"} {
+		if err := os.WriteFile(filepath.Join(chart, Dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	specs, err := Discover(chart)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if len(specs) != 2 || specs[0].Name != "a" || specs[1].Name != "second" {
+		t.Errorf("Discover() = %+v, want a and second", specs)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/owhelm/helm-kustomize/internal/lint"
+	"github.com/owhelm/helm-kustomize/internal/parser"
+	"github.com/owhelm/helm-kustomize/internal/render"
+	"github.com/owhelm/helm-kustomize/internal/scaffold"
+)
+
+// Lint output formats
+const (
+	lintText  = "text"
+	lintJSON  = "json"
+	lintSARIF = "sarif"
+)
+
+// runLint implements the lint command. It reports common mistakes in the embedded
+// kustomization of a rendered manifest or a chart and exits with an error if any issue
+// is an error.
+func runLint(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("lint", stderr)
+	renderer, err := postRendererFlags(flags, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	opts := chartFlags(flags)
+	format := flags.String("format", lintText, "output format: text, json or sarif")
+	if !parseFlags(flags, args, 1) {
+		return 2
+	}
+	if *format != lintText && *format != lintJSON && *format != lintSARIF {
+		fmt.Fprintf(stderr, "Error: invalid format %q, must be %s, %s or %s\n", *format, lintText, lintJSON, lintSARIF)
+		return 2
+	}
+	renderer.Stderr = stderr
+
+	manifests, err := readChartOrManifests(flags.Arg(0), stdin, *opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	result, err := parseManifests(manifests)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if result.KustomizePluginData == nil {
+		fmt.Fprintf(stderr, "Error: no %s resource found\n", parser.Kind)
+		return 1
+	}
+
+	resolved, log, err := renderer.options(result.KustomizePluginData)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	// Resources the chart excludes aren't built, so they can't be targeted
+	excludeResources(result, manifests, log)
+	issues := lint.Lint(result, lint.Options{Overlay: resolved.Overlay, Namespace: releaseNamespace(flags, opts.Namespace)})
+
+	switch *format {
+	case lintJSON:
+		err = writeLintJSON(stdout, issues)
+	case lintSARIF:
+		err = writeLintSARIF(stdout, issues, embeddedDir(flags.Args()))
+	default:
+		for _, issue := range issues {
+			fmt.Fprintln(stdout, issue)
+		}
+		if len(issues) == 0 {
+			fmt.Fprintln(stdout, "No issues found")
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to write output: %v\n", err)
+		return 1
+	}
+
+	if lint.HasErrors(issues) {
+		return 1
+	}
+	return 0
+}
+
+// releaseNamespace returns the namespace of the release being linted. A rendered manifest
+// doesn't say which namespace it was rendered for, so it's only known when given.
+func releaseNamespace(flags *flag.FlagSet, namespace string) string {
+	if flags.NArg() == 1 && render.IsChart(flags.Arg(0)) {
+		return namespace
+	}
+
+	given := os.Getenv("HELM_NAMESPACE") != ""
+	flags.Visit(func(f *flag.Flag) {
+		given = given || f.Name == "namespace"
+	})
+	if !given {
+		return ""
+	}
+	return namespace
+}
+
+// writeLintJSON writes the issues as a JSON array
+func writeLintJSON(w io.Writer, issues []lint.Issue) error {
+	if issues == nil {
+		issues = []lint.Issue{}
+	}
+	output, err := json.MarshalIndent(issues, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", output)
+	return err
+}
+
+// writeLintSARIF writes the issues as a SARIF log
+func writeLintSARIF(w io.Writer, issues []lint.Issue, baseDir string) error {
+	output, err := lint.SARIF(issues, version, baseDir)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(output)
+	return err
+}
+
+// embeddedDir returns the folder the files are embedded from when linting a chart that
+// follows the layout of init, so SARIF locations point to the files in the repository
+func embeddedDir(args []string) string {
+	if len(args) != 1 || !render.IsChart(args[0]) {
+		return ""
+	}
+	dir := filepath.Join(args[0], scaffold.KustomizationDir)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return ""
+	}
+	return filepath.ToSlash(dir)
+}
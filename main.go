@@ -2,49 +2,127 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
+	"maps"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
 
+	"go.yaml.in/yaml/v4"
+
+	"github.com/owhelm/helm-kustomize/internal/config"
 	"github.com/owhelm/helm-kustomize/internal/extractor"
 	"github.com/owhelm/helm-kustomize/internal/kustomize"
 	"github.com/owhelm/helm-kustomize/internal/parser"
+	"github.com/owhelm/helm-kustomize/internal/report"
 )
 
 // KustomizePostRenderer processes Helm manifests through kustomize transformations.
 // It implements Helm's post-renderer protocol by reading from stdin and writing to stdout.
-type KustomizePostRenderer struct{}
+type KustomizePostRenderer struct {
+	// Config holds the options given by arguments, environment variables and the config
+	// file. Its settings take precedence over the options declared in KustomizePluginData.
+	Config config.Layer
+	// Stderr receives warnings and debug output; os.Stderr is used when nil
+	Stderr io.Writer
+	// lookupEnv reads the variables charts substitute; os.LookupEnv is used when nil
+	lookupEnv func(string) (string, bool)
+}
+
+// newPostRenderer creates a KustomizePostRenderer configured from post-renderer
+// arguments, environment variables and the config file, in that order of precedence.
+func newPostRenderer(args []string, getenv func(string) string) (*KustomizePostRenderer, error) {
+	flags := flag.NewFlagSet("helm-kustomize", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	renderer, err := postRendererFlags(flags, getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	return renderer, nil
+}
+
+// postRendererFlags registers the post-renderer options on flags, starting from the
+// config file and the environment. The returned renderer is configured once flags are parsed.
+func postRendererFlags(flags *flag.FlagSet, getenv func(string) string) (*KustomizePostRenderer, error) {
+	defaults, err := configDefaults(getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer := &KustomizePostRenderer{Config: defaults}
+	renderer.Config.RegisterFlags(flags)
+	return renderer, nil
+}
+
+// configDefaults reads the options of the config file and the environment, which takes precedence
+func configDefaults(getenv func(string) string) (config.Layer, error) {
+	file, err := config.FromFile(getenv)
+	if err != nil {
+		return config.Layer{}, err
+	}
+	env, err := config.FromEnv(getenv)
+	if err != nil {
+		return config.Layer{}, err
+	}
+	return file.Merge(env), nil
+}
 
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// runPostRender implements the post-render command: it reads rendered manifests from stdin,
+// applies the embedded kustomization and writes the result to stdout.
+func runPostRender(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 	// Create the post-renderer
-	renderer := &KustomizePostRenderer{}
+	renderer, err := newPostRenderer(args, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: invalid arguments: %v\n", err)
+		return 1
+	}
+	renderer.Stderr = stderr
 
 	// Read input from stdin into a buffer
 	input := &bytes.Buffer{}
-	if _, err := io.Copy(input, os.Stdin); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: failed to read input: %v\n", err)
-		os.Exit(1)
+	if _, err := io.Copy(input, stdin); err != nil {
+		fmt.Fprintf(stderr, "Error: failed to read input: %v\n", err)
+		return 1
 	}
 
 	// Process manifests using the PostRenderer interface
 	output, err := renderer.Run(input)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
 	}
 
 	// Write output to stdout
-	if _, err := io.Copy(os.Stdout, output); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
-		os.Exit(1)
+	if _, err := io.Copy(stdout, output); err != nil {
+		fmt.Fprintf(stderr, "Error: failed to write output: %v\n", err)
+		return 1
 	}
+
+	return 0
 }
 
 // Run implements the Helm PostRenderer interface.
 // It processes rendered manifests through kustomize transformations.
 func (k *KustomizePostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
 	// Parse input manifests
-	result, err := parser.ParseManifests(renderedManifests.Bytes())
+	result, err := parseManifests(renderedManifests.Bytes())
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse input: %w", err)
 	}
@@ -54,57 +132,425 @@ func (k *KustomizePostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buf
 		return renderedManifests, nil
 	}
 
+	opts, log, err := k.options(result.KustomizePluginData)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create temporary directory for kustomize files
 	tempDir, err := extractor.NewTempDir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
 	defer tempDir.Cleanup()
+	log.debugf("extracting %d files to %s", len(result.KustomizePluginData.FileNames()), tempDir.Path)
+
+	excludeResources(result, renderedManifests.Bytes(), log)
+	if err := k.prepare(tempDir, result, opts, log); err != nil {
+		return nil, err
+	}
+
+	// Run kustomize on the overlay, which is the root unless configured otherwise
+	buildDir := filepath.Join(tempDir.Path, filepath.FromSlash(opts.Overlay))
+	log.debugf("building %s with the %s backend", buildDir, opts.Backend)
+	output, warnings, err := kustomize.BuildWith(buildDir, kustomize.BuildOptions{Backend: opts.Backend, Timeout: opts.Timeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run kustomize: %w", err)
+	}
+	log.kustomizeWarnings(warnings)
+	if err := log.check(opts.WarningsAsErrors); err != nil {
+		return nil, err
+	}
+
+	if opts.Report != "" {
+		if err := writeReport(result.OtherResources, output, opts, log.output()); err != nil {
+			return nil, err
+		}
+	}
+
+	// Resources that bypass kustomize are added to its output unchanged
+	if len(result.Skipped) > 0 {
+		output, err = mergeSkipped(output, result, log)
+		if err != nil {
+			return nil, err
+		}
+		if err := log.check(opts.WarningsAsErrors); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Output == config.OutputJSON {
+		output, err = toJSONList(output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert output to JSON: %w", err)
+		}
+	}
+
+	return bytes.NewBuffer(output), nil
+}
+
+// options resolves the options of a run: the chart's options are the defaults for
+// everything not configured by the user
+func (k *KustomizePostRenderer) options(data *parser.KustomizePluginData) (config.Options, *runLog, error) {
+	chartDefaults, err := config.FromMap(data.Options)
+	if err != nil {
+		return config.Options{}, nil, fmt.Errorf("invalid KustomizePluginData options: %w", err)
+	}
+	// Charts can't choose where files are written on the user's machine
+	if chartDefaults.Report != nil {
+		return config.Options{}, nil, fmt.Errorf("invalid KustomizePluginData options: report can only be set by the user")
+	}
+	opts := config.Resolve(chartDefaults, k.Config)
+	// The generated kustomization is the root, so there is no overlay to build
+	if data.Conventional && opts.Overlay != "." {
+		return config.Options{}, nil, fmt.Errorf("overlay %q can't be used without a root kustomization, since the files follow the conventional layout", opts.Overlay)
+	}
+	log := &runLog{stderr: k.Stderr, debug: opts.Debug}
+	log.debugf("options: backend=%s timeout=%s overlay=%s warnings-as-errors=%t output=%s fix-deprecated=%t normalize=%t report=%s",
+		opts.Backend, opts.Timeout, opts.Overlay, opts.WarningsAsErrors, opts.Output, opts.FixDeprecated, opts.Normalize, opts.Report)
+
+	return opts, log, nil
+}
+
+// helmVars are the environment variables Helm passes to plugins, which charts can always
+// substitute. Credentials such as HELM_KUBETOKEN are left out on purpose.
+var helmVars = []string{
+	"HELM_NAMESPACE", "HELM_KUBECONTEXT", "HELM_BIN", "HELM_DEBUG", "HELM_PLUGIN_NAME", "HELM_PLUGIN_DIR",
+	"HELM_PLUGINS", "HELM_REGISTRY_CONFIG", "HELM_REPOSITORY_CACHE", "HELM_REPOSITORY_CONFIG",
+}
+
+// excludeResources removes the resources the chart excludes from result, which was parsed
+// from manifests, and logs what every exclude entry removed. The templates of the resources
+// are only read from manifests when an entry selects them by source.
+func excludeResources(result *parser.ParseResult, manifests []byte, log *runLog) {
+	data := result.KustomizePluginData
+	var sources map[parser.ResourceKey]string
+	if slices.ContainsFunc(data.Exclude, func(e parser.Exclude) bool { return e.Source != "" }) {
+		sources = parser.Sources(manifests)
+	}
+
+	resources, exclusions := data.ExcludeResources(result.OtherResources, sources)
+	for _, e := range exclusions {
+		if len(e.Matched) == 0 {
+			log.debugf("exclude %s matched no resource", e.Exclude)
+		}
+		for _, key := range e.Matched {
+			log.debugf("excluding %s, matched by %s", key, e.Exclude)
+		}
+	}
+	result.OtherResources = resources
+}
+
+// prepare writes the kustomization the post-renderer builds to dir: the embedded files,
+// all.yaml with the Helm manifests, and the overlay's kustomization.yaml updated to
+// include them and the enabled components
+func (k *KustomizePostRenderer) prepare(dir *extractor.TempDir, result *parser.ParseResult, opts config.Options, log *runLog) error {
+	data := result.KustomizePluginData
+	if data.Conventional {
+		log.debugf("no root kustomization in files, generated kustomization.yaml from their layout")
+	}
+	if len(data.UnusedFiles) > 0 {
+		log.notef("files outside of %s/, %s/ and %s/ are not used by the generated kustomization.yaml: %s",
+			parser.PatchesDir, parser.ResourcesDir, parser.ComponentsDir, strings.Join(data.UnusedFiles, ", "))
+	}
+
+	// Resolve which components to enable before touching the filesystem
+	components, err := data.SelectComponents(opts.Components)
+	if err != nil {
+		return err
+	}
+	for _, c := range components {
+		log.debugf("enabling component %s (%s)", c.Name, c.Path)
+	}
 
 	// Check if files contain all.yaml - we need to reserve this name
-	if _, exists := result.KustomizePluginData.Files["all.yaml"]; exists {
-		return nil, fmt.Errorf("KustomizePluginData.files cannot contain 'all.yaml' - this file is reserved for Helm manifests")
+	if err := checkReservedFiles(data, opts.Overlay); err != nil {
+		return err
+	}
+
+	if err := checkOverlay(data.Files, opts.Overlay); err != nil {
+		return err
+	}
+
+	// Fix the line endings, BOMs and indentation of files written on other platforms
+	files := data.Files
+	if opts.Normalize {
+		var fixed map[string][]string
+		files, fixed = extractor.NormalizeFiles(data.Files)
+		for _, name := range slices.Sorted(maps.Keys(fixed)) {
+			log.notef("%s: %s", name, strings.Join(fixed[name], ", "))
+		}
+	}
+
+	// Substitute the environment variables the chart allows
+	if data.Substitute != nil {
+		allowed := append(slices.Clone(helmVars), data.Substitute.AllowedVars...)
+		lookupEnv := k.lookupEnv
+		if lookupEnv == nil {
+			lookupEnv = os.LookupEnv
+		}
+		files, err = extractor.SubstituteFiles(files, allowed, lookupEnv)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Report, and on request migrate, deprecated kustomization fields
+	files, err = checkDeprecatedFields(files, opts.FixDeprecated, log)
+	if err != nil {
+		return err
+	}
+	if err := log.check(opts.WarningsAsErrors); err != nil {
+		return err
 	}
 
 	// Extract files from KustomizePluginData resource
-	if err := tempDir.ExtractFiles(result.KustomizePluginData.Files); err != nil {
-		return nil, fmt.Errorf("failed to extract files: %w", err)
+	if err := dir.ExtractFiles(files); err != nil {
+		return fmt.Errorf("failed to extract files: %w", err)
+	}
+	if err := dir.ExtractBinaryFiles(data.BinaryFiles); err != nil {
+		return fmt.Errorf("failed to extract files: %w", err)
 	}
 
 	// Write other resources to all.yaml
 	allYamlContent, err := parser.MarshalResources(result.OtherResources)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal resources for all.yaml: %w", err)
+		return fmt.Errorf("failed to marshal resources for all.yaml: %w", err)
 	}
 
-	if err := tempDir.WriteFile("all.yaml", allYamlContent); err != nil {
-		return nil, fmt.Errorf("failed to write all.yaml: %w", err)
+	// The Helm output lives next to the kustomization being built, because kustomize
+	// can't load files from outside of it or build on an ancestor directory
+	if err := dir.WriteFile(path.Join(opts.Overlay, "all.yaml"), allYamlContent); err != nil {
+		return fmt.Errorf("failed to write all.yaml: %w", err)
 	}
 
 	// Check if kustomization.yaml exists and update it if needed
-	kustomizationPath := "kustomization.yaml"
-	kustomizationContent, err := tempDir.ReadFile(kustomizationPath)
+	kustomizationPath := path.Join(opts.Overlay, "kustomization.yaml")
+	kustomizationContent, err := dir.ReadFile(kustomizationPath)
 	if err == nil {
 		// kustomization.yaml exists, ensure all.yaml is in resources
-		updated, changed, err := kustomize.EnsureAllYamlInKustomization(kustomizationContent)
+		// and the enabled components are listed
+		kustomization, err := kustomize.ParseKustomization(kustomizationContent)
 		if err != nil {
-			return nil, fmt.Errorf("failed to update kustomization.yaml: %w", err)
+			return fmt.Errorf("failed to update %s: %w", kustomizationPath, err)
+		}
+
+		changed := kustomization.AddResource("all.yaml")
+		for _, c := range components {
+			componentPath, err := filepath.Rel(opts.Overlay, c.Path)
+			if err != nil {
+				return fmt.Errorf("failed to add component %s: %w", c.Name, err)
+			}
+			if kustomization.AddComponent(filepath.ToSlash(componentPath)) {
+				changed = true
+			}
 		}
 
 		if changed {
+			updated, err := kustomization.Marshal()
+			if err != nil {
+				return fmt.Errorf("failed to update %s: %w", kustomizationPath, err)
+			}
+
 			// Write updated kustomization.yaml back
-			if err := tempDir.WriteFile(kustomizationPath, updated); err != nil {
-				return nil, fmt.Errorf("failed to write updated kustomization.yaml: %w", err)
+			if err := dir.WriteFile(kustomizationPath, updated); err != nil {
+				return fmt.Errorf("failed to write updated %s: %w", kustomizationPath, err)
 			}
 		}
+	} else if len(components) > 0 {
+		return fmt.Errorf("components can only be enabled when files contain a kustomization.yaml")
 	}
 	// If kustomization.yaml doesn't exist, that's fine - kustomize will handle it
 
-	// Run kubectl kustomize
-	output, err := kustomize.Build(tempDir.Path)
+	return nil
+}
+
+// checkDeprecatedFields warns about deprecated fields in the embedded kustomization files.
+// When fix is set, it returns a copy of files with those fields migrated.
+func checkDeprecatedFields(files map[string]string, fix bool, log *runLog) (map[string]string, error) {
+	checked := maps.Clone(files)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if !kustomize.IsKustomizationFile(name) {
+			continue
+		}
+
+		// Invalid files are reported when kustomization.yaml is updated or by kustomize itself
+		kustomization, err := kustomize.ParseKustomization([]byte(files[name]))
+		if err != nil {
+			continue
+		}
+
+		deprecated := kustomization.DeprecatedFields()
+		if len(deprecated) == 0 {
+			continue
+		}
+
+		if !fix {
+			log.warnf("%s uses deprecated fields %s; pass --fix-deprecated to migrate them", name, strings.Join(deprecated, ", "))
+			continue
+		}
+
+		fixed, err := kustomization.FixDeprecatedFields()
+		if err != nil {
+			return nil, fmt.Errorf("failed to fix deprecated fields in %s: %w", name, err)
+		}
+
+		if len(fixed) > 0 {
+			content, err := kustomization.Marshal()
+			if err != nil {
+				return nil, fmt.Errorf("failed to fix deprecated fields in %s: %w", name, err)
+			}
+			checked[name] = string(content)
+			log.warnf("%s: migrated deprecated fields %s", name, strings.Join(fixed, ", "))
+		}
+
+		if remaining := kustomization.DeprecatedFields(); len(remaining) > 0 {
+			log.warnf("%s uses deprecated fields %s, which can't be migrated automatically", name, strings.Join(remaining, ", "))
+		}
+	}
+
+	return checked, nil
+}
+
+// checkReservedFiles fails if files contain a name the post-renderer writes itself
+// in the overlay directory
+func checkReservedFiles(data *parser.KustomizePluginData, overlay string) error {
+	name := path.Join(overlay, "all.yaml")
+	if data.HasFile(name) {
+		return fmt.Errorf("KustomizePluginData.files cannot contain '%s' - this file is reserved for Helm manifests", name)
+	}
+	return nil
+}
+
+// checkOverlay fails if the overlay directory has no kustomization.yaml in files.
+// The root is exempt, because kustomize handles a missing kustomization.yaml there.
+func checkOverlay(files map[string]string, overlay string) error {
+	if overlay == "." {
+		return nil
+	}
+	for name := range files {
+		if name == path.Join(overlay, "kustomization.yaml") {
+			return nil
+		}
+	}
+	return fmt.Errorf("overlay %q has no kustomization.yaml in KustomizePluginData.files", overlay)
+}
+
+// mergeSkipped appends the skipped resources of result to the kustomize output. A skipped
+// resource named like an output resource fails the run, and one that refers to a resource
+// kustomize renamed, by a string equal to its previous name, is reported.
+func mergeSkipped(output []byte, result *parser.ParseResult, log *runLog) ([]byte, error) {
+	built, err := parser.ParseManifests(output)
 	if err != nil {
-		return nil, fmt.Errorf("failed to run kustomize: %w", err)
+		return nil, fmt.Errorf("failed to parse kustomize output: %w", err)
+	}
+	builtKeys := map[parser.ResourceKey]bool{}
+	for _, resource := range built.OtherResources {
+		builtKeys[parser.KeyOf(resource)] = true
 	}
 
-	return bytes.NewBuffer(output), nil
+	renames := report.Renames(result.OtherResources, built.OtherResources)
+
+	for _, resource := range result.Skipped {
+		key := parser.KeyOf(resource)
+		if builtKeys[key] {
+			return nil, fmt.Errorf("skipped resource %s conflicts with the resource of the kustomize output with the same name", key)
+		}
+		log.debugf("adding %s to the kustomize output unchanged", key)
+
+		refs := map[string]bool{}
+		for field, value := range resource {
+			if field != "metadata" {
+				collectStrings(value, refs)
+			}
+		}
+		for _, rename := range renames {
+			if refs[rename.Previous.Name] {
+				log.warnf("skipped resource %s refers to %s, which kustomize renamed to %s", key, rename.Previous, rename.Resource)
+			}
+		}
+	}
+
+	// Indented like the kustomize output
+	merged := bytes.NewBuffer(output)
+	if len(output) > 0 && !bytes.HasSuffix(output, []byte("\n")) {
+		merged.WriteByte('\n')
+	}
+	for _, resource := range result.Skipped {
+		if merged.Len() > 0 {
+			merged.WriteString("---\n")
+		}
+		encoder := yaml.NewEncoder(merged)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(resource); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", parser.KeyOf(resource), err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", parser.KeyOf(resource), err)
+		}
+	}
+	return merged.Bytes(), nil
+}
+
+// collectStrings adds the string values found in value to found
+func collectStrings(value any, found map[string]bool) {
+	switch v := value.(type) {
+	case string:
+		found[v] = true
+	case map[string]any:
+		for _, item := range v {
+			collectStrings(item, found)
+		}
+	case []any:
+		for _, item := range v {
+			collectStrings(item, found)
+		}
+	}
+}
+
+// toJSONList converts a YAML manifest stream to a JSON List of its resources
+func toJSONList(manifests []byte) ([]byte, error) {
+	result, err := parser.ParseManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	list := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      result.OtherResources,
+	}
+	output, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(output, '\n'), nil
+}
+
+// writeReport writes the report of the changes kustomize made to the Helm resources, to
+// stderr or to the file named by the report option
+func writeReport(helm []map[string]any, output []byte, opts config.Options, stderr io.Writer) error {
+	result, err := parser.ParseManifests(output)
+	if err != nil {
+		return fmt.Errorf("failed to parse kustomize output for the change report: %w", err)
+	}
+	r := report.Compare(helm, result.OtherResources)
+
+	content := r.Text()
+	if opts.ReportFormat == config.ReportJSON {
+		if content, err = r.JSON(); err != nil {
+			return err
+		}
+	}
+
+	if opts.Report == config.ReportStderr {
+		if _, err := stderr.Write(content); err != nil {
+			return fmt.Errorf("failed to write change report: %w", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(opts.Report, content, 0644); err != nil {
+		return fmt.Errorf("failed to write change report: %w", err)
+	}
+	return nil
 }
@@ -5,6 +5,9 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/owhelm/helm-kustomize/internal/config"
+	"github.com/owhelm/helm-kustomize/internal/kustomize"
 )
 
 func TestKustomizePostRenderer_Run_PassThrough(t *testing.T) {
@@ -465,32 +468,126 @@ kind: KustomizePluginData
 }
 
 func TestKustomizePostRenderer_Run_NoKustomizationYaml(t *testing.T) {
-	// Test that files section without kustomization.yaml returns an error
+	// Without kustomization.yaml, one is generated from the layout of the files
 	input := bytes.NewBufferString(`---
-apiVersion: v1
-kind: Service
+apiVersion: apps/v1
+kind: Deployment
 metadata:
-  name: test-service
+  name: web
+spec:
+  replicas: 1
 ---
 apiVersion: helm.plugin.kustomize/v1
 kind: KustomizePluginData
 files:
-  patch.yaml: |
+  patches/labels.yaml: |
+    apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+      labels:
+        patched: "true"
+  patches/Deployment_web.yaml: |
+    - op: replace
+      path: /spec/replicas
+      value: 3
+  resources/service.yaml: |
     apiVersion: v1
     kind: Service
     metadata:
-      name: test-service
+      name: web
+  components/ha/kustomization.yaml: |
+    apiVersion: kustomize.config.k8s.io/v1alpha1
+    kind: Component
+    commonAnnotations:
+      ha: "true"
+  patch.yaml: |
+    apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
       labels:
-        patched: "true"
+        ignored: "true"
 `)
 
-	renderer := &KustomizePostRenderer{}
-	_, err := renderer.Run(input)
-	if err == nil {
-		t.Fatal("Expected error for files without kustomization.yaml, got nil")
+	var stderr bytes.Buffer
+	debug := true
+	renderer := &KustomizePostRenderer{Config: config.Layer{Debug: &debug}, Stderr: &stderr}
+	output, err := renderer.Run(input)
+	if err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	expected := `apiVersion: v1
+kind: Service
+metadata:
+  annotations:
+    ha: "true"
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    ha: "true"
+  labels:
+    patched: "true"
+  name: web
+spec:
+  replicas: 3
+  template:
+    metadata:
+      annotations:
+        ha: "true"
+`
+	if output.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\nGot:\n%s", expected, output.String())
+	}
+	if !strings.Contains(stderr.String(), "Debug: no root kustomization in files, generated kustomization.yaml from their layout") {
+		t.Errorf("Expected a debug message about the generated kustomization, got: %s", stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "Note: files outside of patches/, resources/ and components/ are not used by the generated kustomization.yaml: patch.yaml") {
+		t.Errorf("Expected a note about the misplaced patch, got: %s", stderr.String())
+	}
+}
+
+func TestKustomizePostRenderer_Run_ConventionOptions(t *testing.T) {
+	input := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  patches/settings.yaml: |
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: settings
+    data:
+      patched: "true"
+  README.md: Notes about the patches
+`
+
+	// Unused files are noted, not warned about
+	warningsAsErrors := true
+	var stderr bytes.Buffer
+	renderer := &KustomizePostRenderer{Config: config.Layer{WarningsAsErrors: &warningsAsErrors}, Stderr: &stderr}
+	if _, err := renderer.Run(bytes.NewBufferString(input)); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if !strings.Contains(stderr.String(), "Note: files outside of patches/, resources/ and components/ are not used by the generated kustomization.yaml: README.md") {
+		t.Errorf("Expected a note about README.md, got: %s", stderr.String())
 	}
-	if !strings.Contains(err.Error(), "failed to run kustomize") && !strings.Contains(err.Error(), "unable to find") {
-		t.Errorf("Expected error message about missing kustomization.yaml, got: %v", err)
+
+	// The generated kustomization has no overlays
+	overlay := "overlays/prod"
+	renderer = &KustomizePostRenderer{Config: config.Layer{Overlay: &overlay}, Stderr: &bytes.Buffer{}}
+	_, err := renderer.Run(bytes.NewBufferString(input))
+	if err == nil || !strings.Contains(err.Error(), `overlay "overlays/prod" can't be used without a root kustomization`) {
+		t.Errorf("Run() error = %v, want the overlay rejected", err)
 	}
 }
 
@@ -563,3 +660,982 @@ spec:
 		t.Errorf("Output mismatch.\nExpected:\n%s\nGot:\n%s", expected, output.String())
 	}
 }
+
+func TestKustomizePostRenderer_Run_Components(t *testing.T) {
+	input := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test-configmap
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    apiVersion: kustomize.config.k8s.io/v1beta1
+    kind: Kustomization
+  components/team/kustomization.yaml: |
+    apiVersion: kustomize.config.k8s.io/v1alpha1
+    kind: Component
+    commonAnnotations:
+      team: platform
+  components/tier/kustomization.yaml: |
+    apiVersion: kustomize.config.k8s.io/v1alpha1
+    kind: Component
+    commonAnnotations:
+      tier: backend
+components:
+  - name: team
+    path: components/team
+    enabled: true
+  - name: tier
+    path: components/tier
+`
+
+	tests := []struct {
+		name      string
+		overrides map[string]bool
+		want      string
+	}{
+		{
+			name: "defaults",
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    team: platform
+  name: test-configmap
+`,
+		},
+		{
+			name:      "overrides",
+			overrides: map[string]bool{"team": false, "tier": true},
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    tier: backend
+  name: test-configmap
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renderer := &KustomizePostRenderer{Config: config.Layer{Components: tt.overrides}}
+			output, err := renderer.Run(bytes.NewBufferString(input))
+			if err != nil {
+				t.Fatalf("Run() error = %v, want nil", err)
+			}
+
+			if output.String() != tt.want {
+				t.Errorf("Output mismatch.\nExpected:\n%s\nGot:\n%s", tt.want, output.String())
+			}
+		})
+	}
+}
+
+func TestKustomizePostRenderer_Run_UnknownComponent(t *testing.T) {
+	input := bytes.NewBufferString(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+`)
+
+	renderer := &KustomizePostRenderer{Config: config.Layer{Components: map[string]bool{"istio": true}}}
+	_, err := renderer.Run(input)
+	if err == nil {
+		t.Fatal("Expected error for unknown component, got nil")
+	}
+	if !strings.Contains(err.Error(), `unknown component "istio"`) {
+		t.Errorf("Expected error message about unknown component, got: %v", err)
+	}
+}
+
+func TestNewPostRenderer_ComponentOverrides(t *testing.T) {
+	env := map[string]string{
+		config.EnableComponentsEnv:  "istio, pdb",
+		config.DisableComponentsEnv: "hpa",
+	}
+
+	renderer, err := newPostRenderer(
+		[]string{"--disable-component", "istio", "--enable-component=hpa,tracing"},
+		func(key string) string { return env[key] },
+	)
+	if err != nil {
+		t.Fatalf("newPostRenderer() error = %v, want nil", err)
+	}
+
+	want := map[string]bool{"istio": false, "pdb": true, "hpa": true, "tracing": true}
+	if len(renderer.Config.Components) != len(want) {
+		t.Fatalf("Components = %v, want %v", renderer.Config.Components, want)
+	}
+	for name, enabled := range want {
+		if got, ok := renderer.Config.Components[name]; !ok || got != enabled {
+			t.Errorf("Components[%q] = %v, want %v", name, got, enabled)
+		}
+	}
+}
+
+func TestNewPostRenderer_InvalidArguments(t *testing.T) {
+	noEnv := func(string) string { return "" }
+
+	if _, err := newPostRenderer([]string{"--unknown-flag"}, noEnv); err == nil {
+		t.Error("Expected error for unknown flag, got nil")
+	}
+	if _, err := newPostRenderer([]string{"extra"}, noEnv); err == nil {
+		t.Error("Expected error for unexpected positional argument, got nil")
+	}
+}
+
+func TestKustomizePostRenderer_Run_DeprecatedFields(t *testing.T) {
+	input := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test-configmap
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+    commonLabels:
+      app: test-app
+`
+
+	tests := []struct {
+		name          string
+		fixDeprecated bool
+		wantWarning   string
+	}{
+		{
+			name:        "warn only",
+			wantWarning: "Warning: kustomization.yaml uses deprecated fields commonLabels; pass --fix-deprecated to migrate them",
+		},
+		{
+			name:          "fix",
+			fixDeprecated: true,
+			wantWarning:   "Warning: kustomization.yaml: migrated deprecated fields commonLabels",
+		},
+	}
+
+	expected := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  labels:
+    app: test-app
+  name: test-configmap
+`
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			renderer := &KustomizePostRenderer{Config: config.Layer{FixDeprecated: &tt.fixDeprecated}, Stderr: &stderr}
+			output, err := renderer.Run(bytes.NewBufferString(input))
+			if err != nil {
+				t.Fatalf("Run() error = %v, want nil", err)
+			}
+
+			if output.String() != expected {
+				t.Errorf("Output mismatch.\nExpected:\n%s\nGot:\n%s", expected, output.String())
+			}
+			if !strings.Contains(stderr.String(), tt.wantWarning) {
+				t.Errorf("Expected warning %q, got: %s", tt.wantWarning, stderr.String())
+			}
+		})
+	}
+}
+
+func TestKustomizePostRenderer_Run_Normalize(t *testing.T) {
+	input := "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n---\n" +
+		"apiVersion: helm.plugin.kustomize/v1\nkind: KustomizePluginData\nfiles:\n" +
+		"  kustomization.yaml: \"\\ufeffpatches:\\r\\n- path: patch.yaml\\r\\n\"\n" +
+		"  patch.yaml: \"apiVersion: v1\\nkind: ConfigMap\\nmetadata:\\n\\tname: settings\\ndata:\\n\\tpatched: 'true'\\n\"\n"
+
+	var stderr bytes.Buffer
+	renderer := &KustomizePostRenderer{Stderr: &stderr}
+	output, err := renderer.Run(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if !strings.Contains(output.String(), "patched: \"true\"") {
+		t.Errorf("Expected the patch to be applied, got:\n%s", output.String())
+	}
+	for _, want := range []string{
+		"Note: kustomization.yaml: removed the UTF-8 BOM, converted CRLF line endings to LF",
+		"Note: patch.yaml: replaced tab indentation with spaces",
+	} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Expected note %q, got: %s", want, stderr.String())
+		}
+	}
+
+	// Repaired files aren't warnings
+	warningsAsErrors := true
+	renderer = &KustomizePostRenderer{Config: config.Layer{WarningsAsErrors: &warningsAsErrors}, Stderr: &bytes.Buffer{}}
+	if _, err := renderer.Run(bytes.NewBufferString(input)); err != nil {
+		t.Errorf("Run() with warnings as errors error = %v, want nil", err)
+	}
+
+	// Without normalization, kustomize can't parse the patch
+	disabled := false
+	renderer = &KustomizePostRenderer{Config: config.Layer{Normalize: &disabled}, Stderr: &bytes.Buffer{}}
+	if _, err := renderer.Run(bytes.NewBufferString(input)); err == nil {
+		t.Error("Run() without normalization error = nil, want error")
+	}
+}
+
+func TestKustomizePostRenderer_Run_Substitute(t *testing.T) {
+	t.Setenv("REGISTRY", "mirror.example.com")
+	t.Setenv("HELM_NAMESPACE", "prod")
+	t.Setenv("HOME", "/home/ops")
+
+	input := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+substitute:
+  allowedVars: [REGISTRY, CLUSTER]
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+    patches:
+      - path: patch.yaml
+  patch.yaml: |
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: settings
+    data:
+      registry: ${REGISTRY}
+      cluster: ${CLUSTER:-dev}
+      namespace: ${HELM_NAMESPACE}
+      home: ${HOME}
+`
+
+	renderer := &KustomizePostRenderer{}
+	output, err := renderer.Run(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	expected := `apiVersion: v1
+data:
+  cluster: dev
+  home: ${HOME}
+  namespace: prod
+  registry: mirror.example.com
+kind: ConfigMap
+metadata:
+  name: settings
+`
+	if output.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\nGot:\n%s", expected, output.String())
+	}
+
+	// Unresolved variables fail the render
+	_, err = renderer.Run(bytes.NewBufferString(strings.ReplaceAll(input, "${CLUSTER:-dev}", "${CLUSTER}")))
+	if err == nil || !strings.Contains(err.Error(), "failed to substitute variables in patch.yaml: line 7: ${CLUSTER} is not set") {
+		t.Errorf("Run() error = %v, want the unset variable", err)
+	}
+
+	// The variables can come from elsewhere than the environment, as for test specs
+	env := map[string]string{"REGISTRY": "registry.test", "CLUSTER": "test", "HELM_NAMESPACE": "tests"}
+	renderer = &KustomizePostRenderer{lookupEnv: func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}}
+	output, err = renderer.Run(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	for _, want := range []string{"cluster: test\n", "namespace: tests\n", "registry: registry.test\n"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("Expected the output to contain %q, got:\n%s", want, output.String())
+		}
+	}
+}
+
+func TestNewPostRenderer_FixDeprecated(t *testing.T) {
+	env := map[string]string{"HELM_KUSTOMIZE_FIX_DEPRECATED": "true"}
+	getenv := func(key string) string { return env[key] }
+
+	renderer, err := newPostRenderer(nil, getenv)
+	if err != nil {
+		t.Fatalf("newPostRenderer() error = %v, want nil", err)
+	}
+	if opts := config.Resolve(renderer.Config); !opts.FixDeprecated {
+		t.Error("Expected FixDeprecated to be enabled from the environment")
+	}
+
+	renderer, err = newPostRenderer([]string{"--fix-deprecated=false"}, getenv)
+	if err != nil {
+		t.Fatalf("newPostRenderer() error = %v, want nil", err)
+	}
+	if opts := config.Resolve(renderer.Config); opts.FixDeprecated {
+		t.Error("Expected --fix-deprecated=false to take precedence over the environment")
+	}
+
+	env["HELM_KUSTOMIZE_FIX_DEPRECATED"] = "sometimes"
+	if _, err := newPostRenderer(nil, getenv); err == nil {
+		t.Error("Expected error for invalid environment value, got nil")
+	}
+}
+
+func TestKustomizePostRenderer_Run_Options(t *testing.T) {
+	input := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test-configmap
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+options:
+  overlay: overlays/prod
+components:
+  - name: team
+    path: components/team
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+  overlays/prod/kustomization.yaml: |
+    namePrefix: prod-
+  overlays/dev/kustomization.yaml: |
+    namePrefix: dev-
+  components/team/kustomization.yaml: |
+    apiVersion: kustomize.config.k8s.io/v1alpha1
+    kind: Component
+    commonAnnotations:
+      team: platform
+`
+
+	dev := "overlays/dev"
+	builtin := kustomize.Builtin
+	json := config.OutputJSON
+
+	tests := []struct {
+		name   string
+		config config.Layer
+		want   string
+	}{
+		{
+			name: "chart defaults",
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: prod-test-configmap
+`,
+		},
+		{
+			name:   "config overrides chart defaults",
+			config: config.Layer{Overlay: &dev, Backend: &builtin, Components: map[string]bool{"team": true}},
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    team: platform
+  name: dev-test-configmap
+`,
+		},
+		{
+			name:   "json output",
+			config: config.Layer{Output: &json},
+			want: `{
+  "apiVersion": "v1",
+  "items": [
+    {
+      "apiVersion": "v1",
+      "kind": "ConfigMap",
+      "metadata": {
+        "name": "prod-test-configmap"
+      }
+    }
+  ],
+  "kind": "List"
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renderer := &KustomizePostRenderer{Config: tt.config}
+			output, err := renderer.Run(bytes.NewBufferString(input))
+			if err != nil {
+				t.Fatalf("Run() error = %v, want nil", err)
+			}
+
+			if output.String() != tt.want {
+				t.Errorf("Output mismatch.\nExpected:\n%s\nGot:\n%s", tt.want, output.String())
+			}
+		})
+	}
+}
+
+func TestKustomizePostRenderer_Run_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		wantErr string
+	}{
+		{
+			name:    "unknown option",
+			options: "color: true",
+			wantErr: `invalid KustomizePluginData options: unknown option "color"`,
+		},
+		{
+			name:    "missing overlay",
+			options: "overlay: overlays/staging",
+			wantErr: `overlay "overlays/staging" has no kustomization.yaml in KustomizePluginData.files`,
+		},
+		{
+			name:    "report",
+			options: "report: /tmp/report.txt",
+			wantErr: "invalid KustomizePluginData options: report can only be set by the user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := bytes.NewBufferString(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+options:
+  ` + tt.options + `
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+`)
+
+			renderer := &KustomizePostRenderer{}
+			_, err := renderer.Run(input)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Run() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKustomizePostRenderer_Run_WarningsAsErrors(t *testing.T) {
+	input := `---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+    commonLabels:
+      app: test-app
+`
+
+	enabled := true
+	var stderr bytes.Buffer
+	renderer := &KustomizePostRenderer{Config: config.Layer{WarningsAsErrors: &enabled}, Stderr: &stderr}
+	_, err := renderer.Run(bytes.NewBufferString(input))
+	if err == nil || !strings.Contains(err.Error(), "1 warning(s) reported and warnings are treated as errors") {
+		t.Errorf("Run() error = %v, want warnings treated as errors", err)
+	}
+	if !strings.Contains(stderr.String(), "Warning: kustomization.yaml uses deprecated fields commonLabels") {
+		t.Errorf("Expected the warning to be printed, got: %s", stderr.String())
+	}
+}
+
+func TestKustomizePostRenderer_Run_Debug(t *testing.T) {
+	input := `---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+`
+
+	enabled := true
+	var stderr bytes.Buffer
+	renderer := &KustomizePostRenderer{Config: config.Layer{Debug: &enabled}, Stderr: &stderr}
+	if _, err := renderer.Run(bytes.NewBufferString(input)); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	for _, want := range []string{"Debug: options: backend=kubectl", "Debug: extracting 1 files to ", "with the kubectl backend"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Expected debug output %q, got: %s", want, stderr.String())
+		}
+	}
+}
+
+func TestNewPostRenderer_ConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	configFile := dir + "/config.yaml"
+	if err := os.WriteFile(configFile, []byte("backend: kustomize\noverlay: overlays/file\ntimeout: 1m\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		config.ConfigEnv:         configFile,
+		"HELM_KUSTOMIZE_OVERLAY": "overlays/env",
+		"HELM_KUSTOMIZE_TIMEOUT": "30s",
+	}
+
+	renderer, err := newPostRenderer([]string{"--timeout=10s"}, func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatalf("newPostRenderer() error = %v, want nil", err)
+	}
+
+	opts := config.Resolve(renderer.Config)
+	if opts.Backend != kustomize.Kustomize || opts.Overlay != "overlays/env" || opts.Timeout.String() != "10s" {
+		t.Errorf("options = %+v, want backend from file, overlay from env and timeout from args", opts)
+	}
+
+	env[config.ConfigEnv] = dir + "/missing.yaml"
+	if _, err := newPostRenderer(nil, func(key string) string { return env[key] }); err == nil {
+		t.Error("Expected error for a missing config file, got nil")
+	}
+}
+
+func TestKustomizePostRenderer_Run_BinaryFiles(t *testing.T) {
+	input := bytes.NewBufferString(`---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    configMapGenerator:
+      - name: assets
+        files:
+          - logo.png
+    generatorOptions:
+      disableNameSuffixHash: true
+binaryFiles:
+  logo.png: iVBORwD/
+`)
+
+	renderer := &KustomizePostRenderer{}
+	output, err := renderer.Run(input)
+	if err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	if !strings.Contains(output.String(), "binaryData:\n  logo.png: iVBORwD/\n") {
+		t.Errorf("Expected the binary file in binaryData, got:\n%s", output.String())
+	}
+}
+
+func TestKustomizePostRenderer_Run_StructuredKustomization(t *testing.T) {
+	input := bytes.NewBufferString(`---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.25
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  replicas: "3"
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+kustomization:
+  images:
+  - name: nginx
+    newTag: "1.27"
+  labels:
+  - pairs:
+      team: web
+  patches:
+  - target:
+      kind: ConfigMap
+    patch: |-
+      - op: add
+        path: /data/tier
+        value: frontend
+  replacements:
+  - source:
+      kind: ConfigMap
+      name: settings
+      fieldPath: data.tier
+    targets:
+    - select:
+        kind: Deployment
+      fieldPaths:
+      - spec.template.spec.containers.0.name
+`)
+
+	renderer := &KustomizePostRenderer{}
+	output, err := renderer.Run(input)
+	if err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	for _, want := range []string{"image: nginx:1.27", "team: web", "tier: frontend", "name: frontend"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("Expected %q in the output, got:\n%s", want, output.String())
+		}
+	}
+}
+
+func TestKustomizePostRenderer_Run_ConfigMapCarrier(t *testing.T) {
+	input := bytes.NewBufferString(`---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kustomize-files
+  labels:
+    helm-kustomize.owhelm.io/data: "true"
+data:
+  kustomization.yaml: |
+    namePrefix: prod-
+    patches:
+      - path: patches/service.yaml
+  patches__service.yaml: |
+    apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+      labels:
+        patched: "true"
+`)
+
+	renderer := &KustomizePostRenderer{}
+	output, err := renderer.Run(input)
+	if err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	expected := `apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    patched: "true"
+  name: prod-web
+`
+	if output.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\nGot:\n%s", expected, output.String())
+	}
+}
+
+func TestKustomizePostRenderer_Run_FileSources(t *testing.T) {
+	input := bytes.NewBufferString(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: nginx-source
+data:
+  nginx.conf: |
+    worker_processes 4;
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+fileSources:
+  nginx.conf:
+    fromResource: {kind: ConfigMap, name: nginx-source, jsonPath: '.data.nginx\.conf', drop: true}
+files:
+  kustomization.yaml: |
+    configMapGenerator:
+      - name: nginx
+        files:
+          - nginx.conf
+    generatorOptions:
+      disableNameSuffixHash: true
+`)
+
+	renderer := &KustomizePostRenderer{}
+	output, err := renderer.Run(input)
+	if err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	expected := `apiVersion: v1
+data:
+  nginx.conf: |
+    worker_processes 4;
+kind: ConfigMap
+metadata:
+  name: nginx
+`
+	if output.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\nGot:\n%s", expected, output.String())
+	}
+}
+
+func TestKustomizePostRenderer_Run_Exclude(t *testing.T) {
+	input := `---
+# Source: app/templates/tests/test-connection.yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-test
+---
+# Source: app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+exclude:
+  - source: "*/templates/tests/*"
+  - kind: Job
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+`
+
+	var stderr bytes.Buffer
+	debug := true
+	renderer := &KustomizePostRenderer{Config: config.Layer{Debug: &debug}, Stderr: &stderr}
+	output, err := renderer.Run(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	expected := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+	if output.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\nGot:\n%s", expected, output.String())
+	}
+	for _, want := range []string{
+		"excluding Pod/web-test, matched by source=*/templates/tests/*",
+		"exclude kind=Job matched no resource",
+	} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr = %q, want it to contain %q", stderr.String(), want)
+		}
+	}
+
+	// Excluded resources aren't reported as removed by kustomize
+	layer := config.Layer{}
+	if err := layer.Set("report", config.ReportStderr); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	renderer = &KustomizePostRenderer{Config: layer, Stderr: &stderr}
+	if _, err := renderer.Run(bytes.NewBufferString(input)); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if want := "Change report: 0 added, 0 removed, 0 renamed, 0 modified, 1 unchanged\n"; !strings.Contains(stderr.String(), want) {
+		t.Errorf("Expected report to contain %q, got:\n%s", want, stderr.String())
+	}
+}
+
+func TestKustomizePostRenderer_Run_Skip(t *testing.T) {
+	input := bytes.NewBufferString(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  key: value
+---
+apiVersion: example.com/v1
+kind: Dashboard
+metadata:
+  name: web
+  annotations:
+    helm-kustomize.owhelm.io/skip: "true"
+spec:
+  json: '{"panels": []}'
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+    commonLabels:
+      app: web
+`)
+
+	renderer := &KustomizePostRenderer{Stderr: &bytes.Buffer{}}
+	output, err := renderer.Run(input)
+	if err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	expected := `apiVersion: v1
+data:
+  key: value
+kind: ConfigMap
+metadata:
+  labels:
+    app: web
+  name: settings
+---
+apiVersion: example.com/v1
+kind: Dashboard
+metadata:
+  name: web
+spec:
+  json: '{"panels": []}'
+`
+	if output.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\nGot:\n%s", expected, output.String())
+	}
+}
+
+func TestKustomizePostRenderer_Run_SkipConflicts(t *testing.T) {
+	tests := []struct {
+		name        string
+		skipped     string
+		wantErr     string
+		wantWarning string
+	}{
+		{
+			name:    "same name",
+			skipped: `{apiVersion: v1, kind: ConfigMap, metadata: {name: prod-settings, annotations: {helm-kustomize.owhelm.io/skip: "true"}}}`,
+			wantErr: "skipped resource ConfigMap/prod-settings conflicts with the resource of the kustomize output with the same name",
+		},
+		{
+			name:        "renamed reference",
+			skipped:     `{apiVersion: v1, kind: Pod, metadata: {name: web, annotations: {helm-kustomize.owhelm.io/skip: "true"}}, spec: {volumes: [{name: config, configMap: {name: settings}}]}}`,
+			wantWarning: "Warning: skipped resource Pod/web refers to ConfigMap/settings, which kustomize renamed to ConfigMap/prod-settings",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := bytes.NewBufferString(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+` + tt.skipped + `
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+    namePrefix: prod-
+`)
+
+			var stderr bytes.Buffer
+			renderer := &KustomizePostRenderer{Stderr: &stderr}
+			_, err := renderer.Run(input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Run() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error = %v, want nil", err)
+			}
+			if !strings.Contains(stderr.String(), tt.wantWarning) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestKustomizePostRenderer_Run_Report(t *testing.T) {
+	input := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  key: value
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: token
+---
+apiVersion: helm.plugin.kustomize/v1
+kind: KustomizePluginData
+files:
+  kustomization.yaml: |
+    resources:
+      - all.yaml
+    namePrefix: pre-
+    patches:
+      - patch: |
+          $patch: delete
+          apiVersion: v1
+          kind: Secret
+          metadata:
+            name: token
+`
+
+	t.Run("stderr", func(t *testing.T) {
+		layer := config.Layer{}
+		if err := layer.Set("report", config.ReportStderr); err != nil {
+			t.Fatal(err)
+		}
+		var stderr bytes.Buffer
+		renderer := &KustomizePostRenderer{Config: layer, Stderr: &stderr}
+		if _, err := renderer.Run(bytes.NewBufferString(input)); err != nil {
+			t.Fatalf("Run() error = %v, want nil", err)
+		}
+
+		for _, want := range []string{
+			"Change report: 0 added, 1 removed, 1 renamed, 0 modified, 0 unchanged\n",
+			"> ConfigMap/settings -> ConfigMap/pre-settings\n",
+			"-  name: settings\n+  name: pre-settings\n",
+			"- Secret/token\n",
+		} {
+			if !strings.Contains(stderr.String(), want) {
+				t.Errorf("Expected report to contain %q, got:\n%s", want, stderr.String())
+			}
+		}
+	})
+
+	t.Run("json file", func(t *testing.T) {
+		path := t.TempDir() + "/report.json"
+		layer := config.Layer{}
+		for name, value := range map[string]string{"report": path, "report-format": config.ReportJSON} {
+			if err := layer.Set(name, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var stderr bytes.Buffer
+		renderer := &KustomizePostRenderer{Config: layer, Stderr: &stderr}
+		if _, err := renderer.Run(bytes.NewBufferString(input)); err != nil {
+			t.Fatalf("Run() error = %v, want nil", err)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Expected the report to be written: %v", err)
+		}
+		if !strings.Contains(string(content), `"renamed": 1`) || !strings.Contains(string(content), `"previous": "ConfigMap/settings"`) {
+			t.Errorf("Unexpected report:\n%s", content)
+		}
+		if stderr.Len() != 0 {
+			t.Errorf("Expected nothing on stderr, got: %s", stderr.String())
+		}
+	})
+}
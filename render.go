@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/owhelm/helm-kustomize/internal/config"
+	"github.com/owhelm/helm-kustomize/internal/render"
+)
+
+// runRender implements the render command. Given a chart directory or archive, it renders
+// the chart in-process with the Helm SDK, without the helm CLI or a cluster, and applies
+// the embedded kustomization as a post-renderer. Given anything else, it applies the
+// embedded kustomization to a manifest rendered earlier, for example with helm template.
+func runRender(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("render", stderr)
+	renderer, err := postRendererFlags(flags, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	opts := chartFlags(flags)
+
+	if !parseFlags(flags, args, 1) {
+		return 2
+	}
+	renderer.Stderr = stderr
+
+	var output *bytes.Buffer
+	if flags.NArg() == 1 && render.IsChart(flags.Arg(0)) {
+		output, err = renderChart(flags.Arg(0), renderer, *opts)
+	} else {
+		var input *bytes.Buffer
+		if input, err = readManifests(flags, stdin); err == nil {
+			output, err = renderer.Run(input)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if _, err := io.Copy(stdout, output); err != nil {
+		fmt.Fprintf(stderr, "Error: failed to write output: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// chartFlags registers the flags configuring how a chart is rendered on flags
+func chartFlags(flags *flag.FlagSet) *render.Options {
+	opts := &render.Options{}
+	appendTo := func(list *[]string) func(string) error {
+		return func(value string) error {
+			*list = append(*list, value)
+			return nil
+		}
+	}
+	flags.Func("f", "chart mode: values file (repeatable)", appendTo(&opts.Values.ValueFiles))
+	flags.Func("values", "chart mode: values file (repeatable)", appendTo(&opts.Values.ValueFiles))
+	flags.Func("set", "chart mode: set a value, e.g. key=value (repeatable)", appendTo(&opts.Values.Values))
+	flags.Func("set-string", "chart mode: set a string value (repeatable)", appendTo(&opts.Values.StringValues))
+	flags.Func("set-file", "chart mode: set a value from a file, e.g. key=path (repeatable)", appendTo(&opts.Values.FileValues))
+	flags.Func("set-json", "chart mode: set a JSON value (repeatable)", appendTo(&opts.Values.JSONValues))
+	flags.StringVar(&opts.ReleaseName, "name", render.DefaultReleaseName, "chart mode: release name")
+	flags.StringVar(&opts.Namespace, "namespace", namespaceDefault(os.Getenv), "chart mode: release namespace")
+	flags.StringVar(&opts.KubeVersion, "kube-version", "", "chart mode: Kubernetes version reported to templates")
+	flags.BoolVar(&opts.IncludeCRDs, "include-crds", false, "chart mode: include CRDs in the output")
+	flags.BoolVar(&opts.SkipHooks, "no-hooks", false, "chart mode: leave hooks out of the output")
+	return opts
+}
+
+// renderChart renders a chart with renderer as its post-renderer
+func renderChart(chartPath string, renderer *KustomizePostRenderer, opts render.Options) (*bytes.Buffer, error) {
+	// Helm splits the post-rendered stream back into YAML documents, so JSON is only
+	// produced once the chart is rendered
+	post := &chartPostRenderer{renderer: renderer, config: renderer.Config}
+	yamlOutput := config.OutputYAML
+	renderer.Config.Output = &yamlOutput
+
+	opts.PostRenderer = post
+	manifests, err := render.Chart(context.Background(), chartPath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if post.output == config.OutputJSON {
+		if manifests, err = toJSONList(manifests); err != nil {
+			return nil, fmt.Errorf("failed to convert output to JSON: %w", err)
+		}
+	}
+	return bytes.NewBuffer(manifests), nil
+}
+
+// chartPostRenderer runs renderer, which produces YAML, as the post-renderer of a chart and
+// records the output format config resolves to with the chart's options
+type chartPostRenderer struct {
+	renderer *KustomizePostRenderer
+	config   config.Layer
+	output   string
+}
+
+// Run implements the Helm PostRenderer interface
+func (c *chartPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	c.output = config.Resolve(c.config).Output
+	// Invalid options are reported by the renderer
+	if result, err := parseManifests(renderedManifests.Bytes()); err == nil && result.KustomizePluginData != nil {
+		if chartDefaults, err := config.FromMap(result.KustomizePluginData.Options); err == nil {
+			c.output = config.Resolve(chartDefaults, c.config).Output
+		}
+	}
+	return c.renderer.Run(renderedManifests)
+}
+
+// namespaceDefault returns the namespace Helm passes to plugins, falling back to the default
+func namespaceDefault(getenv func(string) string) string {
+	if namespace := getenv("HELM_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	return render.DefaultNamespace
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// runLog prints the warnings, notes and debug messages of a single post-render run and
+// keeps count of the warnings, so they can be treated as errors
+type runLog struct {
+	stderr   io.Writer
+	debug    bool
+	warnings int
+}
+
+// warnf prints a warning
+func (l *runLog) warnf(format string, args ...any) {
+	l.warnings++
+	fmt.Fprintf(l.output(), "Warning: "+format+"\n", args...)
+}
+
+// notef prints a message about something the run handled, which isn't a warning
+func (l *runLog) notef(format string, args ...any) {
+	fmt.Fprintf(l.output(), "Note: "+format+"\n", args...)
+}
+
+// debugf prints a message when debug output is enabled
+func (l *runLog) debugf(format string, args ...any) {
+	if l.debug {
+		fmt.Fprintf(l.output(), "Debug: "+format+"\n", args...)
+	}
+}
+
+// kustomizeWarnings prints the warnings kustomize wrote to stderr, one per line
+func (l *runLog) kustomizeWarnings(output []byte) {
+	for line := range strings.Lines(string(output)) {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "#"), " Warning:"))
+		if line != "" {
+			l.warnf("kustomize: %s", line)
+		}
+	}
+}
+
+// check fails when warnings were printed and they are treated as errors
+func (l *runLog) check(warningsAsErrors bool) error {
+	if warningsAsErrors && l.warnings > 0 {
+		return fmt.Errorf("%d warning(s) reported and warnings are treated as errors", l.warnings)
+	}
+	return nil
+}
+
+// output returns the writer messages are printed to
+func (l *runLog) output() io.Writer {
+	if l.stderr == nil {
+		return os.Stderr
+	}
+	return l.stderr
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/owhelm/helm-kustomize/internal/parser"
+)
+
+// runSchema implements the schema command. It prints the JSON Schema of the
+// KustomizePluginData resource, for editors and validators such as kubeconform.
+func runSchema(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("schema", stderr)
+	if !parseFlags(flags, args, 0) {
+		return 2
+	}
+
+	output, err := json.MarshalIndent(parser.Schema(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to marshal schema: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s\n", output)
+	return 0
+}
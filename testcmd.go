@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+	"helm.sh/helm/v4/pkg/cli/values"
+
+	"github.com/owhelm/helm-kustomize/internal/config"
+	"github.com/owhelm/helm-kustomize/internal/diff"
+	"github.com/owhelm/helm-kustomize/internal/parser"
+	"github.com/owhelm/helm-kustomize/internal/render"
+	"github.com/owhelm/helm-kustomize/internal/testspec"
+)
+
+// runTest implements the test command. Given a chart without -expected, it runs the test
+// specs of the chart. Otherwise it renders a chart or a manifest through the post-renderer
+// and compares the result with the manifests in an expected output directory.
+func runTest(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("test", stderr)
+	expectedDir := flags.String("expected", "", "directory containing the expected manifests (required unless a chart has test specs)")
+	update := flags.Bool("update-snapshots", false, "write the output to the expected directories instead of comparing it")
+	// The config file and the environment only apply without test specs, which are hermetic
+	renderer := &KustomizePostRenderer{}
+	renderer.Config.RegisterFlags(flags)
+	opts := chartFlags(flags)
+	if !parseFlags(flags, args, 1) {
+		return 2
+	}
+	isChart := flags.NArg() == 1 && render.IsChart(flags.Arg(0))
+	if *expectedDir == "" && !isChart {
+		fmt.Fprintf(stderr, "Error: -expected is required unless a chart with test specs is given\n\n")
+		flags.Usage()
+		return 2
+	}
+	renderer.Stderr = stderr
+
+	if *expectedDir == "" {
+		return runTestSpecs(flags.Arg(0), renderer, *update, stdout, stderr)
+	}
+	defaults, err := configDefaults(os.Getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	renderer.Config = defaults.Merge(renderer.Config)
+
+	var output *bytes.Buffer
+	if isChart {
+		output, err = renderChart(flags.Arg(0), renderer, *opts)
+	} else {
+		var input *bytes.Buffer
+		if input, err = readManifests(flags, stdin); err == nil {
+			output, err = renderer.Run(input)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	actual, err := parser.ParseManifests(output.Bytes())
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to parse output: %v\n", err)
+		return 1
+	}
+
+	if *update {
+		if err := testspec.WriteSnapshot(*expectedDir, actual.OtherResources); err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "UPDATED %d resources written to %s\n", len(actual.OtherResources), *expectedDir)
+		return 0
+	}
+
+	expected, err := testspec.ReadSnapshot(*expectedDir)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	failures := compareResources(expected.Resources, actual.OtherResources)
+	for _, failure := range failures {
+		fmt.Fprintf(stdout, "FAIL %s\n", failure)
+	}
+	if len(failures) > 0 {
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "PASS %d resources match %s\n", len(expected.Resources), *expectedDir)
+	return 0
+}
+
+// runTestSpecs runs the test specs of the chart and reports each one
+func runTestSpecs(chart string, renderer *KustomizePostRenderer, update bool, stdout, stderr io.Writer) int {
+	specs, err := testspec.Discover(chart)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(specs) == 0 {
+		fmt.Fprintf(stderr, "Error: no test specs found in %s\n", filepath.Join(chart, testspec.Dir))
+		return 1
+	}
+
+	failed := 0
+	for _, spec := range specs {
+		summary, failures, err := runTestSpec(chart, spec, renderer, update)
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+		if len(failures) > 0 {
+			failed++
+			fmt.Fprintf(stdout, "FAIL %s\n", spec.Name)
+			for _, failure := range failures {
+				fmt.Fprintf(stdout, "    %s\n", strings.ReplaceAll(failure, "\n", "\n    "))
+			}
+			continue
+		}
+		fmt.Fprintf(stdout, "PASS %s (%s)\n", spec.Name, summary)
+	}
+
+	fmt.Fprintf(stdout, "%d tests, %d failed\n", len(specs), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runTestSpec renders the chart with the values and options of spec, and compares the
+// output with its snapshot, or updates the snapshot, and checks its assertions. Only the
+// spec's values and environment are used, so tests don't depend on the command line or
+// the machine they run on.
+func runTestSpec(chart string, spec *testspec.Spec, base *KustomizePostRenderer, update bool) (string, []string, error) {
+	options, err := config.FromMap(spec.Options)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid options: %w", err)
+	}
+	renderer := &KustomizePostRenderer{
+		Config: base.Config.Merge(options),
+		Stderr: base.Stderr,
+		lookupEnv: func(name string) (string, bool) {
+			value, ok := spec.Env[name]
+			return value, ok
+		},
+	}
+
+	jsonValues, err := spec.JSONValues()
+	if err != nil {
+		return "", nil, err
+	}
+	opts := render.Options{
+		Values:      values.Options{JSONValues: jsonValues},
+		ReleaseName: spec.Release,
+		Namespace:   spec.Namespace,
+	}
+	for _, file := range spec.Values {
+		opts.Values.ValueFiles = append(opts.Values.ValueFiles, filepath.Join(chart, file))
+	}
+
+	output, err := renderChart(chart, renderer, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	actual, err := parser.ParseManifests(output.Bytes())
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse output: %w", err)
+	}
+
+	var summary []string
+	var failures []string
+	if spec.Expected != "" {
+		dir := filepath.Join(chart, spec.Expected)
+		if update {
+			if err := testspec.WriteSnapshot(dir, actual.OtherResources); err != nil {
+				return "", nil, err
+			}
+			summary = append(summary, fmt.Sprintf("updated %s", spec.Expected))
+		} else {
+			expected, err := testspec.ReadSnapshot(dir)
+			if err != nil {
+				return "", nil, err
+			}
+			failures = compareResources(expected.Resources, actual.OtherResources)
+			summary = append(summary, fmt.Sprintf("%d resources match %s", len(expected.Resources), spec.Expected))
+		}
+	}
+
+	for i, a := range spec.Asserts {
+		if err := a.Check(actual.OtherResources); err != nil {
+			failures = append(failures, fmt.Sprintf("asserts[%d] %s: %v", i, a, err))
+		}
+	}
+	if len(spec.Asserts) > 0 {
+		summary = append(summary, fmt.Sprintf("%d assertions", len(spec.Asserts)))
+	}
+
+	return strings.Join(summary, ", "), failures, nil
+}
+
+// compareResources matches expected and actual resources by key and describes every
+// difference, with a unified diff of the resources that differ
+func compareResources(expected, actual []map[string]any) []string {
+	var failures []string
+
+	actualByKey := make(map[parser.ResourceKey]map[string]any, len(actual))
+	for _, resource := range actual {
+		actualByKey[parser.KeyOf(resource)] = resource
+	}
+
+	for _, want := range expected {
+		key := parser.KeyOf(want)
+		got, ok := actualByKey[key]
+		switch {
+		case !ok:
+			failures = append(failures, fmt.Sprintf("%s: missing from output", key))
+		case !reflect.DeepEqual(want, got):
+			failure := fmt.Sprintf("%s: differs from expected output", key)
+			if d := diff.Unified("expected", "actual", toYAML(want), toYAML(got)); d != "" {
+				failure += "\n" + strings.TrimSuffix(d, "\n")
+			}
+			failures = append(failures, failure)
+		}
+		delete(actualByKey, key)
+	}
+
+	for key := range actualByKey {
+		failures = append(failures, fmt.Sprintf("%s: not in expected output", key))
+	}
+
+	slices.SortFunc(failures, strings.Compare)
+	return failures
+}
+
+// toYAML formats a resource for diffs
+func toYAML(resource map[string]any) string {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(resource); err != nil {
+		return fmt.Sprint(resource)
+	}
+	_ = encoder.Close()
+	return buf.String()
+}
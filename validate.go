@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/owhelm/helm-kustomize/internal/config"
+	"github.com/owhelm/helm-kustomize/internal/kustomize"
+	"github.com/owhelm/helm-kustomize/internal/parser"
+)
+
+// runValidate implements the validate command. It checks that a rendered manifest contains
+// a well-formed KustomizePluginData resource without running kustomize.
+func runValidate(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("validate", stderr)
+	if !parseFlags(flags, args, 1) {
+		return 2
+	}
+
+	input, err := readManifests(flags, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	result, err := parseManifests(input.Bytes())
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := validatePluginData(result.KustomizePluginData); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "KustomizePluginData is valid: %d embedded files, %d resources\n",
+		len(result.KustomizePluginData.FileNames()), len(result.OtherResources))
+	return 0
+}
+
+// validatePluginData checks the parts of a KustomizePluginData resource the post-renderer
+// relies on: valid options, a root kustomization.yaml, no reserved file names and parseable kustomization files
+func validatePluginData(data *parser.KustomizePluginData) error {
+	if data == nil {
+		return fmt.Errorf("no %s resource found", parser.Kind)
+	}
+
+	chartDefaults, err := config.FromMap(data.Options)
+	if err != nil {
+		return fmt.Errorf("invalid KustomizePluginData options: %w", err)
+	}
+	overlay := config.Resolve(chartDefaults).Overlay
+
+	if err := checkReservedFiles(data, overlay); err != nil {
+		return err
+	}
+	if err := checkOverlay(data.Files, overlay); err != nil {
+		return err
+	}
+
+	if _, ok := data.Files["kustomization.yaml"]; !ok {
+		return fmt.Errorf("KustomizePluginData.files must contain a root kustomization.yaml")
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(data.Files)) {
+		if !kustomize.IsKustomizationFile(name) {
+			continue
+		}
+		if _, err := kustomize.ParseKustomization([]byte(data.Files[name])); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
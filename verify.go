@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/owhelm/helm-kustomize/internal/config"
+	"github.com/owhelm/helm-kustomize/internal/lint"
+	"github.com/owhelm/helm-kustomize/internal/parser"
+	"github.com/owhelm/helm-kustomize/internal/render"
+)
+
+// combination is the outcome of rendering a chart with one values file of the matrix
+type combination struct {
+	values     string
+	built      bool
+	duplicates []string
+	unmatched  []string
+	// errors explains why the chart failed to render or build
+	errors []string
+	// log holds the warnings and debug output of the post-renderer
+	log bytes.Buffer
+}
+
+// failed reports whether the combination has any problem
+func (c *combination) failed() bool {
+	return !c.built || len(c.duplicates) > 0 || len(c.unmatched) > 0
+}
+
+// runVerify implements the verify command. It renders a chart through the post-renderer
+// once per values file of a directory, in parallel, and reports the combinations that
+// fail to build, produce duplicate resources or leave patch targets unmatched.
+func runVerify(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("verify", stderr)
+	matrix := flags.String("values-matrix", "", "directory of values files, each rendered on its own after the -f files (required)")
+	parallel := flags.Int("parallel", runtime.NumCPU(), "number of combinations rendered at the same time")
+	renderer, err := postRendererFlags(flags, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	opts := chartFlags(flags)
+	if !parseFlags(flags, args, 1) {
+		return 2
+	}
+	if *matrix == "" {
+		fmt.Fprintln(stderr, "Error: --values-matrix is required, e.g. --values-matrix ci/")
+		return 2
+	}
+	if flags.NArg() == 0 || !render.IsChart(flags.Arg(0)) {
+		fmt.Fprintln(stderr, "Error: a chart directory or archive is required")
+		return 2
+	}
+	if *parallel < 1 {
+		fmt.Fprintf(stderr, "Error: invalid parallel %d, must be at least 1\n", *parallel)
+		return 2
+	}
+
+	files, err := valuesFiles(*matrix)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(stderr, "Error: no values files found in %s\n", *matrix)
+		return 1
+	}
+
+	// Outputs are only parsed, and runs must not write to the same report
+	yamlOutput := config.OutputYAML
+	renderer.Config.Output = &yamlOutput
+	renderer.Config.Report = nil
+
+	combinations := make([]*combination, len(files))
+	sem := make(chan struct{}, *parallel)
+	var wg sync.WaitGroup
+	for i, file := range files {
+		combinations[i] = &combination{values: filepath.Base(file)}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			chartOpts := *opts
+			chartOpts.Values.ValueFiles = append(slices.Clone(opts.Values.ValueFiles), file)
+			verifyCombination(combinations[i], flags.Arg(0), renderer, chartOpts)
+		}()
+	}
+	wg.Wait()
+
+	for _, c := range combinations {
+		if c.log.Len() > 0 {
+			fmt.Fprintf(stderr, "%s:\n%s", c.values, c.log.String())
+		}
+	}
+	if err := printMatrix(stdout, combinations); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if slices.ContainsFunc(combinations, (*combination).failed) {
+		return 1
+	}
+	return 0
+}
+
+// valuesFiles returns the YAML files of dir, sorted by name
+func valuesFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values matrix: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// verifyCombination renders the chart with opts and records the problems of the output in c
+func verifyCombination(c *combination, chart string, base *KustomizePostRenderer, opts render.Options) {
+	renderer := &KustomizePostRenderer{Config: base.Config, Stderr: &c.log}
+
+	manifests, err := render.Chart(context.Background(), chart, opts)
+	if err != nil {
+		c.errors = append(c.errors, err.Error())
+		return
+	}
+	result, err := parseManifests(manifests)
+	if err != nil {
+		c.errors = append(c.errors, err.Error())
+		return
+	}
+	if result.KustomizePluginData == nil {
+		c.errors = append(c.errors, fmt.Sprintf("no %s resource found", parser.Kind))
+		return
+	}
+
+	resolved, log, err := renderer.options(result.KustomizePluginData)
+	if err != nil {
+		c.errors = append(c.errors, err.Error())
+		return
+	}
+	excludeResources(result, manifests, log)
+
+	// Duplicates in the Helm output fail the build with an error that doesn't say which
+	// values caused them
+	c.duplicates = duplicateKeys(result.OtherResources)
+	for _, issue := range lint.Lint(result, lint.Options{Overlay: resolved.Overlay}) {
+		if issue.Rule == lint.RuleUnmatchedTarget {
+			c.unmatched = append(c.unmatched, fmt.Sprintf("%s: %s", issue.File, issue.Message))
+		}
+	}
+
+	output, err := renderer.Run(bytes.NewBuffer(manifests))
+	if err != nil {
+		c.errors = append(c.errors, err.Error())
+		return
+	}
+	outputResult, err := parser.ParseManifests(output.Bytes())
+	if err != nil {
+		c.errors = append(c.errors, fmt.Sprintf("failed to parse output: %v", err))
+		return
+	}
+	c.built = true
+	for _, key := range duplicateKeys(outputResult.OtherResources) {
+		if !slices.Contains(c.duplicates, key) {
+			c.duplicates = append(c.duplicates, key)
+		}
+	}
+}
+
+// duplicateKeys returns the resources appearing more than once in resources
+func duplicateKeys(resources []map[string]any) []string {
+	seen := map[parser.ResourceKey]int{}
+	var duplicates []string
+	for _, resource := range resources {
+		key := parser.KeyOf(resource)
+		seen[key]++
+		if seen[key] == 2 {
+			duplicates = append(duplicates, key.String())
+		}
+	}
+	return duplicates
+}
+
+// printMatrix prints one row per combination, then the problems of the failed ones
+func printMatrix(w io.Writer, combinations []*combination) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "VALUES\tBUILD\tDUPLICATES\tUNMATCHED\tRESULT")
+	failed := 0
+	for _, c := range combinations {
+		build, result := "ok", "PASS"
+		if !c.built {
+			build = "failed"
+		}
+		if c.failed() {
+			result = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(table, "%s\t%s\t%d\t%d\t%s\n", c.values, build, len(c.duplicates), len(c.unmatched), result)
+	}
+	if err := table.Flush(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	for _, c := range combinations {
+		if !c.failed() {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", c.values)
+		for _, e := range c.errors {
+			fmt.Fprintf(w, "  %s\n", strings.ReplaceAll(strings.TrimSpace(e), "\n", "\n  "))
+		}
+		for _, key := range c.duplicates {
+			fmt.Fprintf(w, "  duplicate resource %s\n", key)
+		}
+		for _, u := range c.unmatched {
+			fmt.Fprintf(w, "  %s\n", u)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d combinations, %d failed\n", len(combinations), failed)
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+
+	"go.yaml.in/yaml/v4"
+
+	"github.com/owhelm/helm-kustomize/internal/parser"
+)
+
+//go:embed plugin.yaml
+var pluginManifest []byte
+
+// version is the plugin version, read from plugin.yaml so the two can't disagree
+var version = pluginVersion(pluginManifest)
+
+// parseManifests parses manifests like parser.ParseManifests, failing for charts that
+// require a newer plugin
+func parseManifests(data []byte) (*parser.ParseResult, error) {
+	return parser.ParseManifestsWith(data, parser.ParseOptions{PluginVersion: version})
+}
+
+// pluginVersion returns the version declared in a plugin.yaml
+func pluginVersion(manifest []byte) string {
+	var metadata struct {
+		Version string `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(manifest, &metadata); err != nil || metadata.Version == "" {
+		panic(fmt.Sprintf("plugin.yaml has no valid version: %v", err))
+	}
+	return metadata.Version
+}
+
+// runVersion implements the version command
+func runVersion(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("version", stderr)
+	if !parseFlags(flags, args, 0) {
+		return 2
+	}
+
+	fmt.Fprintf(stdout, "helm-kustomize %s\n", version)
+	return 0
+}